@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/repo"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateAPIKeyRequest
+type CreateAPIKeyRequest struct {
+	Name       string        `json:"name"`
+	Scopes     []model.Scope `json:"scopes"`
+	AllowedIPs []string      `json:"allowed_ips,omitempty"`
+	ExpiresAt  *time.Time    `json:"expires_at,omitempty"`
+}
+
+// CreatedAPIKey holds a new key; Key is not shown again.
+type CreatedAPIKey struct {
+	APIKey *model.APIKey `json:"api_key"`
+	Key    string        `json:"key"`
+}
+
+// @Summary Create API key
+// @Description Issues a key for server-to-server clients, sent as "Authorization: Bearer <key>". The key is only returned here. Scopes: accounts:read, accounts:write, transfers:read, transfers:write.
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body CreateAPIKeyRequest true "name, scopes, optional IP allowlist and expiry"
+// @Success 201 {object} CreatedAPIKey
+// @Failure 400 {string} string
+// @Router /auth/api-keys [post]
+func (h *AuthHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" || len(req.Scopes) == 0 {
+		http.Error(w, "name and scopes required", http.StatusBadRequest)
+		return
+	}
+	for _, s := range req.Scopes {
+		if !s.Valid() {
+			http.Error(w, "unknown scope "+strconv.Quote(string(s)), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+	userID, _ := r.Context().Value("user_id").(string)
+	k, key, err := h.Repo.CreateAPIKey(r.Context(), userID, req.Name, req.Scopes, req.AllowedIPs, req.ExpiresAt)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(CreatedAPIKey{APIKey: k, Key: key})
+	case repo.ErrNotFound:
+		http.Error(w, "not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// @Summary List API keys
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} model.APIKey
+// @Router /auth/api-keys [get]
+func (h *AuthHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(string)
+	json.NewEncoder(w).Encode(h.Repo.ListAPIKeys(r.Context(), userID))
+}
+
+// @Summary Revoke API key
+// @Tags auth
+// @Security BearerAuth
+// @Param id path string true "API key id"
+// @Success 204
+// @Failure 404 {string} string
+// @Router /auth/api-keys/{id} [delete]
+func (h *AuthHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(string)
+	if err := h.Repo.RevokeAPIKey(r.Context(), userID, mux.Vars(r)["id"]); err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,36 +1,221 @@
 package auth
 
 import (
+	"BankingAPI/internal/model"
 	"errors"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-var jwtSecret = []byte("secret")
+var (
+	keysMu     sync.RWMutex
+	tenantKeys = map[string]*keySet{}
+)
+
+// keySet holds a tenant's keys by kid and the one new tokens are signed
+// with.
+type keySet struct {
+	signing *Key
+	byID    map[string]*Key
+}
+
+// Claims are the identity carried by a verified token.
+type Claims struct {
+	TenantID  string
+	UserID    string
+	SessionID string
+	// TokenID is the token's jti, under which it can be revoked.
+	TokenID   string
+	ExpiresAt time.Time
+	// Roles are the user's staff roles when the token was issued.
+	Roles []model.Role
+	// APIKeyID is set when the caller authenticated with an API key,
+	// ClientID when an OAuth client calls on the user's behalf.
+	APIKeyID string
+	ClientID string
+	// Scoped credentials may only use routes whose scope is in Scopes; a
+	// user's own session may use every route.
+	Scoped bool
+	Scopes []model.Scope
+}
+
+// APIKeyClaims are the claims of a request made with k.
+func APIKeyClaims(k *model.APIKey) *Claims {
+	return &Claims{
+		TenantID: k.TenantID,
+		UserID:   k.UserID,
+		APIKeyID: k.ID,
+		Scoped:   true,
+		Scopes:   k.Scopes,
+	}
+}
+
+// JoinScopes renders scopes as an OAuth scope string.
+func JoinScopes(scopes []model.Scope) string {
+	parts := make([]string, len(scopes))
+	for i, s := range scopes {
+		parts[i] = string(s)
+	}
+	return strings.Join(parts, " ")
+}
+
+// SplitScopes parses a space-separated OAuth scope string.
+func SplitScopes(v string) []model.Scope {
+	out := []model.Scope{}
+	for _, s := range strings.Fields(v) {
+		out = append(out, model.Scope(s))
+	}
+	return out
+}
+
+// Allows reports whether the credential may use a route needing scope.
+func (c *Claims) Allows(scope model.Scope) bool {
+	if !c.Scoped {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole reports whether the token carries any of roles.
+func (c *Claims) HasRole(roles ...model.Role) bool {
+	for _, have := range c.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
 
-func GenerateToken(userID string) (string, error) {
+// SetTenantKeys replaces the keys used to sign and verify the tokens of a
+// tenant. New tokens are signed with signingKID, or with the first key
+// that can sign when it is empty; every key keeps verifying.
+func SetTenantKeys(tenantID string, keys []*Key, signingKID string) error {
+	set := &keySet{byID: make(map[string]*Key, len(keys))}
+	for _, k := range keys {
+		if _, dup := set.byID[k.ID]; dup {
+			return errors.New("duplicate kid " + k.ID)
+		}
+		set.byID[k.ID] = k
+		if set.signing == nil && signingKID == "" && k.CanSign() {
+			set.signing = k
+		}
+	}
+	if signingKID != "" {
+		set.signing = set.byID[signingKID]
+	}
+	if set.signing == nil || !set.signing.CanSign() {
+		return errors.New("no signing key")
+	}
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	tenantKeys[tenantID] = set
+	return nil
+}
+
+func keysOf(tenantID string) (*keySet, error) {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	set, ok := tenantKeys[tenantID]
+	if !ok {
+		return nil, errors.New("unknown tenant")
+	}
+	return set, nil
+}
+
+// GenerateToken issues an access token with the identity in c that is
+// valid for ttl. TokenID and ExpiresAt are filled in.
+func GenerateToken(c Claims, ttl time.Duration) (string, *Claims, error) {
+	set, err := keysOf(c.TenantID)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	c.TokenID = uuid.NewString()
+	c.ExpiresAt = now.Add(ttl)
 	claims := jwt.MapClaims{}
-	claims["sub"] = userID
-	claims["exp"] = time.Now().Add(24 * time.Hour).Unix()
-	claims["iat"] = time.Now().Unix()
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	claims["sub"] = c.UserID
+	claims["tid"] = c.TenantID
+	claims["sid"] = c.SessionID
+	claims["jti"] = c.TokenID
+	claims["exp"] = c.ExpiresAt.Unix()
+	claims["iat"] = now.Unix()
+	if len(c.Roles) > 0 {
+		claims["roles"] = c.Roles
+	}
+	if c.ClientID != "" {
+		claims["cid"] = c.ClientID
+		claims["scope"] = JoinScopes(c.Scopes)
+	}
+	token := jwt.NewWithClaims(set.signing.method, claims)
+	token.Header["kid"] = set.signing.ID
+	signed, err := token.SignedString(set.signing.private)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, &c, nil
 }
 
-func ParseToken(tokenStr string) (string, error) {
+func ParseToken(tokenStr string) (*Claims, error) {
 	tkn, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+		// the tenant claim is only trusted once the signature made with
+		// one of that tenant's keys has been verified
+		claims, _ := t.Claims.(jwt.MapClaims)
+		tid, _ := claims["tid"].(string)
+		set, err := keysOf(tid)
+		if err != nil {
+			return nil, err
+		}
+		kid, _ := t.Header["kid"].(string)
+		k, ok := set.byID[kid]
+		if !ok {
+			return nil, errors.New("unknown kid")
+		}
+		if t.Method.Alg() != k.method.Alg() {
 			return nil, errors.New("unexpected signing method")
 		}
-		return jwtSecret, nil
+		return k.public, nil
 	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if claims, ok := tkn.Claims.(jwt.MapClaims); ok && tkn.Valid {
-		sub, _ := claims["sub"].(string)
-		return sub, nil
+		c := &Claims{}
+		c.UserID, _ = claims["sub"].(string)
+		c.TenantID, _ = claims["tid"].(string)
+		c.SessionID, _ = claims["sid"].(string)
+		c.TokenID, _ = claims["jti"].(string)
+		roles, _ := claims["roles"].([]interface{})
+		for _, v := range roles {
+			if role, ok := v.(string); ok {
+				c.Roles = append(c.Roles, model.Role(role))
+			}
+		}
+		// tokens issued to OAuth clients only carry the scopes the user
+		// consented to
+		if cid, _ := claims["cid"].(string); cid != "" {
+			scope, _ := claims["scope"].(string)
+			c.ClientID = cid
+			c.Scoped = true
+			c.Scopes = SplitScopes(scope)
+		}
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			c.ExpiresAt = exp.Time
+		}
+		if c.TokenID == "" || c.ExpiresAt.IsZero() {
+			return nil, errors.New("token has no jti or exp")
+		}
+		return c, nil
 	}
-	return "", errors.New("invalid token")
+	return nil, errors.New("invalid token")
 }
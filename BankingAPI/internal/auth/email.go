@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"BankingAPI/internal/mailer"
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/repo"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ForgotPasswordRequest
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// VerifyEmailRequest
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// sendVerification emails the user a link that verifies their address.
+// Delivery failures are only logged; the user can ask for another email.
+func (h *AuthHandler) sendVerification(ctx context.Context, userID string) error {
+	token, u, err := h.Repo.IssueUserToken(ctx, userID, model.TokenEmailVerification, h.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	h.send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nplease confirm this is your email address by opening the link below.\n\n%s\n\nThe link expires in %s.\n",
+			u.Name, h.link("/verify-email", token), humanDuration(h.EmailVerificationTTL)),
+	})
+	return nil
+}
+
+func (h *AuthHandler) send(ctx context.Context, m mailer.Message) {
+	if err := h.Mailer.Send(ctx, m); err != nil {
+		log.Printf("mail to %s failed: %v", m.To, err)
+	}
+}
+
+func (h *AuthHandler) link(path, token string) string {
+	return h.PublicURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// @Summary Request password reset
+// @Description Emails a single-use reset link if the address is registered and was not sent one recently. The response is the same either way.
+// @Tags auth
+// @Accept json
+// @Param body body ForgotPasswordRequest true "email"
+// @Success 202
+// @Router /auth/password/forgot [post]
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	// the mail is queued, so known and unknown addresses answer alike
+	token, u, err := h.Repo.PasswordResetToken(r.Context(), req.Email, h.PasswordResetTTL, h.PasswordResetInterval)
+	if err == nil {
+		h.send(r.Context(), mailer.Message{
+			To:      u.Email,
+			Subject: "Reset your password",
+			Body: fmt.Sprintf("Hello %s,\n\nsomeone asked to reset the password of your account. If it was you, open the link below to choose a new one.\n\n%s\n\nThe link expires in %s. If you did not ask for this, ignore this email.\n",
+				u.Name, h.link("/reset-password", token), humanDuration(h.PasswordResetTTL)),
+		})
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// @Summary Reset password
+// @Description Sets a new password with a token from the reset email, signs the user out everywhere and revokes their API keys.
+// @Tags auth
+// @Accept json
+// @Param body body ResetPasswordRequest true "token and new password"
+// @Success 204
+// @Failure 400 {string} string
+// @Router /auth/password/reset [post]
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.NewPassword == "" {
+		http.Error(w, "new_password required", http.StatusBadRequest)
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.Repo.ResetPassword(r.Context(), req.Token, string(hash)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Verify email address
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body VerifyEmailRequest true "token from the verification email"
+// @Success 200 {object} model.User
+// @Failure 400 {string} string
+// @Router /auth/email/verify [post]
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	u, err := h.Repo.VerifyEmail(r.Context(), req.Token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(u)
+}
+
+// @Summary Resend verification email
+// @Tags auth
+// @Security BearerAuth
+// @Success 202
+// @Failure 409 {string} string
+// @Router /auth/email/verification [post]
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(string)
+	switch err := h.sendVerification(r.Context(), userID); err {
+	case nil:
+		w.WriteHeader(http.StatusAccepted)
+	case repo.ErrAlreadyVerified:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+// humanDuration renders a link lifetime for an email, e.g. "48 hours".
+func humanDuration(d time.Duration) string {
+	switch {
+	case d == time.Hour:
+		return "1 hour"
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	default:
+		return fmt.Sprintf("%d minutes", d/time.Minute)
+	}
+}
@@ -1,11 +1,15 @@
 package auth
 
 import (
+	"BankingAPI/internal/mailer"
 	"BankingAPI/internal/model"
 	"BankingAPI/internal/repo"
-	"context"
 	"encoding/json"
+	"log"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -13,7 +17,34 @@ import (
 
 // AuthHandler handles registration, login and me endpoints
 type AuthHandler struct {
-	Repo *repo.Repo
+	Repo            *repo.Repo
+	AccessTTL       time.Duration
+	RefreshTTL      time.Duration
+	MFAChallengeTTL time.Duration
+	// OAuthCodeTTL is how long an OAuth authorization code can be
+	// exchanged for tokens.
+	OAuthCodeTTL time.Duration
+
+	LoginPolicy repo.LoginPolicy
+
+	Mailer                mailer.Mailer
+	PublicURL             string // base of links in emails
+	PasswordResetTTL      time.Duration
+	PasswordResetInterval time.Duration
+	EmailVerificationTTL  time.Duration
+}
+
+// dummyHash is compared against when a login names no registered user.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+
+// ClientIP returns the address the request came from. Proxy headers are
+// not trusted.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
 
 // RegisterRequest
@@ -29,6 +60,25 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// RefreshRequest
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ChangePasswordRequest
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// TokenResponse is returned by login and refresh.
+type TokenResponse struct {
+	Token        string      `json:"token"`
+	RefreshToken string      `json:"refresh_token"`
+	ExpiresIn    int         `json:"expires_in"` // seconds until token expires
+	User         *model.User `json:"user,omitempty"`
+}
+
 // @Summary Register user
 // @Tags auth
 // @Accept json
@@ -53,11 +103,14 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
-	created, err := h.Repo.CreateUser(context.Background(), u)
+	created, err := h.Repo.CreateUser(r.Context(), u)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if err := h.sendVerification(r.Context(), created.ID); err != nil {
+		log.Printf("verification email for %s: %v", created.ID, err)
+	}
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(created)
 }
@@ -67,31 +120,180 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Produce json
 // @Param body body LoginRequest true "login"
-// @Success 200 {object} map[string]interface{}
+// @Description Returns tokens, or an MFA token to complete at /auth/login/mfa when two-factor authentication is enabled.
+// @Success 200 {object} TokenResponse
+// @Success 202 {object} MFAChallengeResponse
 // @Failure 401 {string} string
+// @Failure 429 {string} string
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 	_ = json.NewDecoder(r.Body).Decode(&req)
-	u, err := h.Repo.GetUserByEmail(context.Background(), req.Email)
-	if err != nil {
-		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+	ip, now := ClientIP(r), time.Now()
+	if wait := h.Repo.ReserveLoginAttempt(r.Context(), req.Email, ip, h.LoginPolicy, now); wait > 0 {
+		writeLoginWait(w, wait)
 		return
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(req.Password)); err != nil {
+	// unknown addresses are checked against a dummy hash so they take as
+	// long as a wrong password
+	u, err := h.Repo.GetUserByEmail(r.Context(), req.Email)
+	hash := dummyHash
+	if err == nil {
+		hash = []byte(u.PasswordHash)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(req.Password)) != nil || err != nil {
+		h.Repo.RecordLoginFailure(r.Context(), req.Email, ip, h.LoginPolicy, now)
 		http.Error(w, "invalid credentials", http.StatusUnauthorized)
 		return
 	}
 	if !u.IsActive {
+		h.Repo.ReleaseLoginAttempt(r.Context(), req.Email, ip)
 		http.Error(w, "user inactive", http.StatusForbidden)
 		return
 	}
-	token, err := GenerateToken(u.ID)
+	// the throttle is only cleared once the second factor is in too, or
+	// knowing the password would buy unlimited fresh MFA challenges
+	if u.TOTPEnabled {
+		h.Repo.ReleaseLoginAttempt(r.Context(), req.Email, ip)
+		h.writeMFAChallenge(w, r, u)
+		return
+	}
+	h.Repo.RecordLoginSuccess(r.Context(), req.Email, ip)
+	h.startSession(w, r, u)
+}
+
+// writeLoginWait refuses a login attempt that must wait for the throttle.
+func writeLoginWait(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, "too many failed login attempts, try again later", http.StatusTooManyRequests)
+}
+
+// startSession opens a session for a fully authenticated user and writes
+// its first tokens.
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, u *model.User) {
+	session, refresh, err := h.Repo.NewSession(r.Context(), u.ID, h.RefreshTTL)
+	if err != nil {
+		http.Error(w, "could not start session", http.StatusInternalServerError)
+		return
+	}
+	h.writeTokens(w, r, session, refresh, u)
+}
+
+// writeTokens issues an access token for session and writes it with the
+// session's new refresh token. u is only echoed in the response.
+func (h *AuthHandler) writeTokens(w http.ResponseWriter, r *http.Request, session *model.Session, refresh string, u *model.User) {
+	token, err := h.accessToken(r, session)
 	if err != nil {
 		http.Error(w, "could not generate token", http.StatusInternalServerError)
 		return
 	}
-	json.NewEncoder(w).Encode(map[string]interface{}{"token": token, "user": u})
+	json.NewEncoder(w).Encode(TokenResponse{
+		Token:        token,
+		RefreshToken: refresh,
+		ExpiresIn:    int(h.AccessTTL.Seconds()),
+		User:         u,
+	})
+}
+
+// accessToken issues an access token in session. The user is looked up
+// afresh so the token carries their current roles; tokens of OAuth
+// clients carry the consented scopes and no roles.
+func (h *AuthHandler) accessToken(r *http.Request, session *model.Session) (string, error) {
+	u, err := h.Repo.GetUserByID(r.Context(), session.UserID)
+	if err != nil {
+		return "", err
+	}
+	c := Claims{TenantID: session.TenantID, UserID: session.UserID, SessionID: session.ID}
+	if session.ClientID != "" {
+		c.ClientID = session.ClientID
+		c.Scoped = true
+		c.Scopes = session.Scopes
+	} else {
+		c.Roles = u.Roles
+	}
+	token, claims, err := GenerateToken(c, h.AccessTTL)
+	if err != nil {
+		return "", err
+	}
+	if err := h.Repo.TrackAccessToken(r.Context(), session.ID, claims.TokenID, claims.ExpiresAt); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// @Summary Refresh tokens
+// @Description Exchanges a refresh token for a new access token and a new refresh token. Each refresh token works once; presenting a used one revokes the whole session.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body RefreshRequest true "refresh token"
+// @Success 200 {object} TokenResponse
+// @Failure 401 {string} string
+// @Router /auth/refresh [post]
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	session, refresh, err := h.Repo.RotateRefreshToken(r.Context(), req.RefreshToken, "", h.RefreshTTL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	h.writeTokens(w, r, session, refresh, nil)
+}
+
+// @Summary Logout
+// @Description Ends the session of the presented token, revoking its refresh token and every access token issued in it.
+// @Tags auth
+// @Security BearerAuth
+// @Success 204
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, _ := r.Context().Value("claims").(*Claims)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	_ = h.Repo.RevokeSession(r.Context(), claims.SessionID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Change password
+// @Description Sets a new password, signs the user out of every session, including the current one, and revokes their API keys.
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Param body body ChangePasswordRequest true "passwords"
+// @Success 204
+// @Failure 400 {string} string
+// @Failure 401 {string} string
+// @Router /auth/me/password [post]
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var req ChangePasswordRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.NewPassword == "" {
+		http.Error(w, "new_password required", http.StatusBadRequest)
+		return
+	}
+	userID, _ := r.Context().Value("user_id").(string)
+	u, err := h.Repo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.Repo.ChangePassword(r.Context(), u.ID, string(hash)); err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // @Summary Get current user
@@ -107,10 +309,27 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
-	u, err := h.Repo.GetUserByID(context.Background(), userID)
+	u, err := h.Repo.GetUserByID(r.Context(), userID)
 	if err != nil {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
 	json.NewEncoder(w).Encode(u)
 }
+
+// @Summary Token verification keys
+// @Description Public keys of the tenant's RS256 and EdDSA signing keys, including retired ones still valid for verification.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} JWKSet
+// @Router /.well-known/jwks.json [get]
+func (h *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	set, err := PublicKeys(repo.TenantFrom(r.Context()))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(set)
+}
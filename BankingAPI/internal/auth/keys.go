@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is a token key. For HS256 both sides are the shared secret; for
+// RS256 and EdDSA private is nil on keys that only verify.
+type Key struct {
+	ID      string
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// CanSign reports whether the key can sign new tokens.
+func (k *Key) CanSign() bool { return k.private != nil }
+
+// MinHS256SecretLen is the shortest HS256 secret accepted, matching the
+// 256-bit output of the hash so the secret is not the weak point.
+const MinHS256SecretLen = 32
+
+// NewKey builds a key for alg (HS256, RS256 or EdDSA) from a shared
+// secret or from PEM-encoded private or public keys. A private key is
+// enough on its own; the public half is derived from it.
+func NewKey(kid, alg, secret, privatePEM, publicPEM string) (*Key, error) {
+	k := &Key{ID: kid}
+	switch alg {
+	case "HS256":
+		if len(secret) < MinHS256SecretLen {
+			return nil, fmt.Errorf("key %q: HS256 needs a secret of at least %d bytes", kid, MinHS256SecretLen)
+		}
+		k.method = jwt.SigningMethodHS256
+		k.private, k.public = []byte(secret), []byte(secret)
+		return k, nil
+	case "RS256":
+		k.method = jwt.SigningMethodRS256
+	case "EdDSA":
+		k.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("key %q: unsupported alg %q", kid, alg)
+	}
+
+	if privatePEM != "" {
+		priv, err := parsePrivateKey(privatePEM)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", kid, err)
+		}
+		signer, ok := priv.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("key %q: unsupported key type %T", kid, priv)
+		}
+		k.private, k.public = signer, signer.Public()
+	} else if publicPEM != "" {
+		pub, err := parsePublicKey(publicPEM)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", kid, err)
+		}
+		k.public = pub
+	} else {
+		return nil, fmt.Errorf("key %q: a private or public key is required", kid)
+	}
+
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			return nil, fmt.Errorf("key %q: RSA key given for %s", kid, alg)
+		}
+		if pub.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("key %q: RSA keys must be at least 2048 bits", kid)
+		}
+	case ed25519.PublicKey:
+		if alg != "EdDSA" {
+			return nil, fmt.Errorf("key %q: Ed25519 key given for %s", kid, alg)
+		}
+	default:
+		return nil, fmt.Errorf("key %q: unsupported key type %T", kid, pub)
+	}
+	return k, nil
+}
+
+func parsePrivateKey(data string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("private key is not PEM")
+	}
+	if k, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	return nil, errors.New("private key is neither PKCS#8 nor PKCS#1")
+}
+
+func parsePublicKey(data string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("public key is not PEM")
+	}
+	if k, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	return nil, errors.New("public key is neither PKIX nor PKCS#1")
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicKeys returns the tenant's asymmetric verification keys, ordered
+// by kid. HS256 secrets are never published.
+func PublicKeys(tenantID string) (JWKSet, error) {
+	set, err := keysOf(tenantID)
+	if err != nil {
+		return JWKSet{}, err
+	}
+	out := JWKSet{Keys: []JWK{}}
+	b64 := base64.RawURLEncoding
+	for _, k := range set.byID {
+		jwk := JWK{Use: "sig", Alg: k.method.Alg(), Kid: k.ID}
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = b64.EncodeToString(pub.N.Bytes())
+			jwk.E = b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty, jwk.Crv = "OKP", "Ed25519"
+			jwk.X = b64.EncodeToString(pub)
+		default:
+			continue
+		}
+		out.Keys = append(out.Keys, jwk)
+	}
+	sort.Slice(out.Keys, func(i, j int) bool { return out.Keys[i].Kid < out.Keys[j].Kid })
+	return out, nil
+}
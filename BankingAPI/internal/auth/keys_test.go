@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func privatePEM(t *testing.T, key interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+func publicPEM(t *testing.T, key interface{}) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func mustKey(t *testing.T, kid, alg, secret, priv, pub string) *Key {
+	t.Helper()
+	k, err := NewKey(kid, alg, secret, priv, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestNewKey(t *testing.T) {
+	_, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	smallRSA, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		alg     string
+		secret  string
+		priv    string
+		pub     string
+		wantErr bool
+	}{
+		{name: "HS256 without a secret", alg: "HS256", wantErr: true},
+		{name: "HS256 secret of 31 bytes", alg: "HS256", secret: strings.Repeat("s", 31), wantErr: true},
+		{name: "HS256 secret of 32 bytes", alg: "HS256", secret: strings.Repeat("s", 32)},
+		{name: "EdDSA private key", alg: "EdDSA", priv: privatePEM(t, edPriv)},
+		{name: "EdDSA public key only", alg: "EdDSA", pub: publicPEM(t, edPriv.Public())},
+		{name: "EdDSA without a key", alg: "EdDSA", wantErr: true},
+		{name: "Ed25519 key as RS256", alg: "RS256", priv: privatePEM(t, edPriv), wantErr: true},
+		{name: "RSA key under 2048 bits", alg: "RS256", priv: privatePEM(t, smallRSA), wantErr: true},
+		{name: "unsupported alg", alg: "HS512", secret: strings.Repeat("s", 64), wantErr: true},
+		{name: "not PEM", alg: "EdDSA", priv: "not a key", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKey("k", tt.alg, tt.secret, tt.priv, tt.pub)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewKey = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	const tenant = "rotation"
+	_, oldPriv, _ := ed25519.GenerateKey(rand.Reader)
+	_, newPriv, _ := ed25519.GenerateKey(rand.Reader)
+	oldKey := mustKey(t, "2025", "EdDSA", "", privatePEM(t, oldPriv), "")
+	retired := mustKey(t, "2025", "EdDSA", "", "", publicPEM(t, oldPriv.Public()))
+	newKey := mustKey(t, "2026", "EdDSA", "", privatePEM(t, newPriv), "")
+
+	if err := SetTenantKeys(tenant, []*Key{oldKey}, ""); err != nil {
+		t.Fatal(err)
+	}
+	before, _, err := GenerateToken(Claims{TenantID: tenant, UserID: "u"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rotate: sign with the new key, keep only the public half of the old
+	if err := SetTenantKeys(tenant, []*Key{retired, newKey}, "2026"); err != nil {
+		t.Fatal(err)
+	}
+	after, _, err := GenerateToken(Claims{TenantID: tenant, UserID: "u"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := tokenKid(t, after); kid != "2026" {
+		t.Errorf("token signed with kid %q after rotation, want 2026", kid)
+	}
+	for name, tok := range map[string]string{"issued before rotation": before, "issued after rotation": after} {
+		if _, err := ParseToken(tok); err != nil {
+			t.Errorf("%s: ParseToken = %v", name, err)
+		}
+	}
+
+	// a kid the tenant does not have is refused, even if the signature
+	// would verify with one of its keys
+	unknown := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"tid": tenant, "sub": "u", "jti": "j", "exp": time.Now().Add(time.Minute).Unix()})
+	unknown.Header["kid"] = "2024"
+	forged, err := unknown.SignedString(newPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseToken(forged); err == nil {
+		t.Error("ParseToken accepted a token with an unknown kid")
+	}
+
+	// once the old key is dropped its tokens stop verifying
+	if err := SetTenantKeys(tenant, []*Key{newKey}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseToken(before); err == nil {
+		t.Error("ParseToken accepted a token whose key was removed")
+	}
+
+	// a retired key cannot sign
+	if err := SetTenantKeys(tenant, []*Key{retired, newKey}, "2025"); err == nil {
+		t.Error("SetTenantKeys made a public-only key the signing key")
+	}
+}
+
+func tokenKid(t *testing.T, tok string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(tok, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestPublicKeys(t *testing.T) {
+	const tenant = "jwks"
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	keys := []*Key{
+		mustKey(t, "c-hmac", "HS256", strings.Repeat("s", 32), "", ""),
+		mustKey(t, "b-rsa", "RS256", "", privatePEM(t, rsaPriv), ""),
+		mustKey(t, "a-ed", "EdDSA", "", "", publicPEM(t, edPub)),
+	}
+	if err := SetTenantKeys(tenant, keys, "b-rsa"); err != nil {
+		t.Fatal(err)
+	}
+	set, err := PublicKeys(tenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 2 || set.Keys[0].Kid != "a-ed" || set.Keys[1].Kid != "b-rsa" {
+		t.Fatalf("PublicKeys = %+v, want a-ed and b-rsa only", set.Keys)
+	}
+	b64 := base64.RawURLEncoding
+
+	ed := set.Keys[0]
+	if ed.Kty != "OKP" || ed.Crv != "Ed25519" || ed.Alg != "EdDSA" || ed.Use != "sig" || ed.X != b64.EncodeToString(edPriv.Public().(ed25519.PublicKey)) {
+		t.Errorf("Ed25519 JWK = %+v", ed)
+	}
+
+	// a verifier rebuilding the RSA key from the JWK accepts our tokens
+	jwk := set.Keys[1]
+	if jwk.Kty != "RSA" || jwk.Alg != "RS256" || jwk.Use != "sig" || jwk.E != "AQAB" {
+		t.Fatalf("RSA JWK = %+v", jwk)
+	}
+	n, err := b64.DecodeString(jwk.N)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}
+	tok, _, err := GenerateToken(Claims{TenantID: tenant, UserID: "u"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(tok, func(*jwt.Token) (interface{}, error) { return pub, nil }, jwt.WithValidMethods([]string{"RS256"})); err != nil {
+		t.Errorf("token does not verify with the published key: %v", err)
+	}
+
+	if _, err := PublicKeys("no-such-tenant"); err == nil {
+		t.Error("PublicKeys of an unknown tenant succeeded")
+	}
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/repo"
+	"BankingAPI/internal/totp"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// MFAChallengeResponse is returned by login when a second factor is due.
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// LoginMFARequest completes a login with a TOTP code or a recovery code.
+type LoginMFARequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+// MFACodeRequest carries a TOTP code, or a recovery code where accepted.
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+// TOTPEnrolment is the secret to load into an authenticator app.
+type TOTPEnrolment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// RecoveryCodes are one-time codes that stand in for a TOTP code.
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (h *AuthHandler) writeMFAChallenge(w http.ResponseWriter, r *http.Request, u *model.User) {
+	token, err := h.Repo.NewMFAChallenge(r.Context(), u.ID, h.MFAChallengeTTL)
+	if err != nil {
+		http.Error(w, "could not start login", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(MFAChallengeResponse{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   int(h.MFAChallengeTTL.Seconds()),
+	})
+}
+
+// writeMFAError maps MFA errors to HTTP status codes.
+func writeMFAError(w http.ResponseWriter, err error) {
+	switch err {
+	case repo.ErrInvalidMFACode, repo.ErrInvalidMFAToken:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case repo.ErrMFAEnabled, repo.ErrMFANotEnabled, repo.ErrMFANotEnrolled:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	}
+}
+
+// checkCodeThrottled runs check, which verifies a second-factor code of
+// the user with email, as a login attempt: it waits for the email's and
+// client's login throttle and a wrong code counts as a failed login, so
+// codes cannot be guessed faster than passwords. A right code completes a
+// login when login is set. Errors are written to w.
+func (h *AuthHandler) checkCodeThrottled(w http.ResponseWriter, r *http.Request, email string, login bool, check func() error) bool {
+	ip, now := ClientIP(r), time.Now()
+	if wait := h.Repo.ReserveLoginAttempt(r.Context(), email, ip, h.LoginPolicy, now); wait > 0 {
+		writeLoginWait(w, wait)
+		return false
+	}
+	err := check()
+	switch {
+	case err == nil && login:
+		h.Repo.RecordLoginSuccess(r.Context(), email, ip)
+		return true
+	case err == nil:
+		h.Repo.ReleaseLoginAttempt(r.Context(), email, ip)
+		return true
+	case err == repo.ErrInvalidMFACode:
+		h.Repo.RecordLoginFailure(r.Context(), email, ip, h.LoginPolicy, now)
+	default:
+		h.Repo.ReleaseLoginAttempt(r.Context(), email, ip)
+	}
+	writeMFAError(w, err)
+	return false
+}
+
+// @Summary Complete MFA login
+// @Description Second login step: exchanges the MFA token from /auth/login and a TOTP or recovery code for tokens.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body LoginMFARequest true "mfa token and code"
+// @Success 200 {object} TokenResponse
+// @Failure 401 {string} string
+// @Failure 429 {string} string
+// @Router /auth/login/mfa [post]
+func (h *AuthHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req LoginMFARequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	u, err := h.Repo.MFAChallengeUser(r.Context(), req.MFAToken)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	if !h.checkCodeThrottled(w, r, u.Email, true, func() error {
+		_, err := h.Repo.CompleteMFAChallenge(r.Context(), req.MFAToken, req.Code)
+		return err
+	}) {
+		return
+	}
+	h.startSession(w, r, u)
+}
+
+// currentUser returns the authenticated user, writing a 404 when they
+// are gone.
+func (h *AuthHandler) currentUser(w http.ResponseWriter, r *http.Request) (*model.User, bool) {
+	userID, _ := r.Context().Value("user_id").(string)
+	u, err := h.Repo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, false
+	}
+	return u, true
+}
+
+// @Summary Start TOTP enrolment
+// @Description Generates a TOTP secret. Two-factor authentication is enabled once /auth/mfa/totp/confirm sees a valid code.
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 201 {object} TOTPEnrolment
+// @Failure 409 {string} string
+// @Router /auth/mfa/totp [post]
+func (h *AuthHandler) EnrolTOTP(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	secret, err := h.Repo.BeginTOTPEnrolment(r.Context(), u.ID)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	issuer := "Banking API"
+	if t, err := h.Repo.GetTenant(r.Context(), u.TenantID); err == nil && t.Name != "" {
+		issuer = t.Name
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(TOTPEnrolment{Secret: secret, URI: totp.URI(issuer, u.Email, secret)})
+}
+
+// @Summary Confirm TOTP enrolment
+// @Description Enables two-factor authentication and returns recovery codes, which are not shown again.
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body MFACodeRequest true "code from the authenticator app"
+// @Success 200 {object} RecoveryCodes
+// @Failure 401 {string} string
+// @Failure 409 {string} string
+// @Failure 429 {string} string
+// @Router /auth/mfa/totp/confirm [post]
+func (h *AuthHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	var req MFACodeRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var codes []string
+	if !h.checkCodeThrottled(w, r, u.Email, false, func() (err error) {
+		codes, err = h.Repo.ConfirmTOTP(r.Context(), u.ID, req.Code)
+		return err
+	}) {
+		return
+	}
+	json.NewEncoder(w).Encode(RecoveryCodes{RecoveryCodes: codes})
+}
+
+// @Summary Disable TOTP
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Param body body MFACodeRequest true "TOTP or recovery code"
+// @Success 204
+// @Failure 401 {string} string
+// @Failure 409 {string} string
+// @Failure 429 {string} string
+// @Router /auth/mfa/totp [delete]
+func (h *AuthHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	var req MFACodeRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if !h.checkCodeThrottled(w, r, u.Email, false, func() error {
+		return h.Repo.DisableTOTP(r.Context(), u.ID, req.Code)
+	}) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Regenerate recovery codes
+// @Description Replaces all recovery codes; the old ones stop working.
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body MFACodeRequest true "TOTP or recovery code"
+// @Success 200 {object} RecoveryCodes
+// @Failure 401 {string} string
+// @Failure 409 {string} string
+// @Failure 429 {string} string
+// @Router /auth/mfa/recovery-codes [post]
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var req MFACodeRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var codes []string
+	if !h.checkCodeThrottled(w, r, u.Email, false, func() (err error) {
+		codes, err = h.Repo.RegenerateRecoveryCodes(r.Context(), u.ID, req.Code)
+		return err
+	}) {
+		return
+	}
+	json.NewEncoder(w).Encode(RecoveryCodes{RecoveryCodes: codes})
+}
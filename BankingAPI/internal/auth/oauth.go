@@ -0,0 +1,510 @@
+package auth
+
+import (
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/repo"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// scopeDescriptions are shown to the user on the consent screen.
+var scopeDescriptions = map[model.Scope]string{
+	model.ScopeAccountsRead:   "See your accounts, balances and transactions",
+	model.ScopeAccountsWrite:  "Open and manage your accounts, pots and cards",
+	model.ScopeTransfersRead:  "See your transfer and payment requests",
+	model.ScopeTransfersWrite: "Send money and pay requests from your accounts",
+}
+
+// RegisterClientRequest
+type RegisterClientRequest struct {
+	Name         string        `json:"name"`
+	RedirectURIs []string      `json:"redirect_uris"`
+	Scopes       []model.Scope `json:"scopes"`
+	Public       bool          `json:"public"`
+}
+
+// RegisteredClient holds a new client; ClientSecret is not shown again.
+type RegisteredClient struct {
+	Client       *model.OAuthClient `json:"client"`
+	ClientSecret string             `json:"client_secret,omitempty"`
+}
+
+// AuthorizeRequest carries the parameters of an authorization request
+// (RFC 6749 section 4.1.1 with PKCE, RFC 7636). Approve is the user's
+// answer on the consent screen.
+type AuthorizeRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+	Approve             bool   `json:"approve"`
+}
+
+// ScopeDescription
+type ScopeDescription struct {
+	Scope       model.Scope `json:"scope"`
+	Description string      `json:"description"`
+}
+
+// ConsentScreen is what the user is asked to approve.
+type ConsentScreen struct {
+	ClientID    string             `json:"client_id"`
+	ClientName  string             `json:"client_name"`
+	Scopes      []ScopeDescription `json:"scopes"`
+	RedirectURI string             `json:"redirect_uri"`
+	State       string             `json:"state,omitempty"`
+}
+
+// AuthorizeResponse tells the front end where to send the user's
+// browser: the client's redirect URI with a code or an error.
+type AuthorizeResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+// OAuthTokenResponse is the token endpoint's answer (RFC 6749 section 5.1).
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// IntrospectionResponse describes a token (RFC 7662).
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+}
+
+// OAuthError is an error of the token, introspection and revocation
+// endpoints, which OAuth clients expect as JSON (RFC 6749 section 5.2).
+type OAuthError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(OAuthError{Error: code, Description: description})
+}
+
+// validRedirectURI accepts absolute URIs without a fragment. Plain http
+// is only allowed to the loopback interface, for native apps.
+func validRedirectURI(v string) bool {
+	u, err := url.Parse(v)
+	if err != nil || !u.IsAbs() || u.Fragment != "" || u.Host == "" && (u.Scheme == "http" || u.Scheme == "https") {
+		return false
+	}
+	if u.Scheme == "http" {
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	}
+	return true
+}
+
+// @Summary Register OAuth client
+// @Description Registers a third-party app. Confidential clients receive a client_secret, shown only here; public clients (mobile or browser apps) get none. Requires the admin role.
+// @Tags oauth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body RegisterClientRequest true "client"
+// @Success 201 {object} RegisteredClient
+// @Failure 400 {string} string
+// @Router /admin/oauth/clients [post]
+func (h *AuthHandler) RegisterClient(w http.ResponseWriter, r *http.Request) {
+	var req RegisterClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" || len(req.RedirectURIs) == 0 || len(req.Scopes) == 0 {
+		http.Error(w, "name, redirect_uris and scopes required", http.StatusBadRequest)
+		return
+	}
+	for _, uri := range req.RedirectURIs {
+		if !validRedirectURI(uri) {
+			http.Error(w, "invalid redirect URI "+strconv.Quote(uri), http.StatusBadRequest)
+			return
+		}
+	}
+	for _, s := range req.Scopes {
+		if !s.Valid() {
+			http.Error(w, "unknown scope "+strconv.Quote(string(s)), http.StatusBadRequest)
+			return
+		}
+	}
+	userID, _ := r.Context().Value("user_id").(string)
+	c, secret, err := h.Repo.RegisterOAuthClient(r.Context(), req.Name, req.RedirectURIs, req.Scopes, req.Public, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(RegisteredClient{Client: c, ClientSecret: secret})
+}
+
+// @Summary List OAuth clients
+// @Description Requires the admin role.
+// @Tags oauth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} model.OAuthClient
+// @Router /admin/oauth/clients [get]
+func (h *AuthHandler) ListClients(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(h.Repo.ListOAuthClients(r.Context()))
+}
+
+// @Summary Revoke OAuth client
+// @Description Disables the client and revokes every token issued to it. Requires the admin role.
+// @Tags oauth
+// @Security BearerAuth
+// @Param id path string true "client id"
+// @Success 204
+// @Failure 404 {string} string
+// @Router /admin/oauth/clients/{id} [delete]
+func (h *AuthHandler) RevokeClient(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(string)
+	if err := h.Repo.RevokeOAuthClient(r.Context(), mux.Vars(r)["id"], userID); err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkAuthorizeRequest validates an authorization request and returns
+// its client and the scopes asked for, which default to all the client
+// may have. Problems are reported to the user rather than the client,
+// since the redirect URI cannot be trusted until it has been checked.
+func (h *AuthHandler) checkAuthorizeRequest(r *http.Request, req *AuthorizeRequest) (*model.OAuthClient, []model.Scope, string) {
+	c, err := h.Repo.OAuthClient(r.Context(), req.ClientID)
+	if err != nil {
+		return nil, nil, "unknown client_id"
+	}
+	if req.RedirectURI == "" && len(c.RedirectURIs) == 1 {
+		req.RedirectURI = c.RedirectURIs[0]
+	}
+	registered := false
+	for _, uri := range c.RedirectURIs {
+		if uri == req.RedirectURI {
+			registered = true
+		}
+	}
+	if !registered {
+		return nil, nil, "redirect_uri is not registered for this client"
+	}
+	if req.ResponseType != "code" {
+		return nil, nil, "response_type must be code"
+	}
+	if req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) != 43 {
+		return nil, nil, "a PKCE code_challenge with code_challenge_method S256 is required"
+	}
+	scopes := SplitScopes(req.Scope)
+	if len(scopes) == 0 {
+		scopes = c.Scopes
+	}
+	for _, s := range scopes {
+		allowed := false
+		for _, cs := range c.Scopes {
+			if s == cs {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return nil, nil, "scope " + strconv.Quote(string(s)) + " is not available to this client"
+		}
+	}
+	return c, scopes, ""
+}
+
+// redirectWith returns uri with params added to its query.
+func redirectWith(uri string, params url.Values) string {
+	u, _ := url.Parse(uri)
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// @Summary OAuth consent screen
+// @Description Validates an authorization request and describes what the client asks for, for the signed-in user to approve with POST /oauth/authorize.
+// @Tags oauth
+// @Security BearerAuth
+// @Produce json
+// @Param response_type query string true "code"
+// @Param client_id query string true "client id"
+// @Param redirect_uri query string false "registered redirect URI"
+// @Param scope query string false "space-separated scopes"
+// @Param state query string false "opaque client state"
+// @Param code_challenge query string true "PKCE challenge"
+// @Param code_challenge_method query string true "S256"
+// @Success 200 {object} ConsentScreen
+// @Failure 400 {string} string
+// @Router /oauth/authorize [get]
+func (h *AuthHandler) AuthorizeConsent(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := AuthorizeRequest{
+		ResponseType:        q.Get("response_type"),
+		ClientID:            q.Get("client_id"),
+		RedirectURI:         q.Get("redirect_uri"),
+		Scope:               q.Get("scope"),
+		State:               q.Get("state"),
+		CodeChallenge:       q.Get("code_challenge"),
+		CodeChallengeMethod: q.Get("code_challenge_method"),
+	}
+	c, scopes, problem := h.checkAuthorizeRequest(r, &req)
+	if problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+	screen := ConsentScreen{ClientID: c.ID, ClientName: c.Name, RedirectURI: req.RedirectURI, State: req.State}
+	for _, s := range scopes {
+		screen.Scopes = append(screen.Scopes, ScopeDescription{Scope: s, Description: scopeDescriptions[s]})
+	}
+	json.NewEncoder(w).Encode(screen)
+}
+
+// @Summary Approve or deny OAuth client
+// @Description Records the signed-in user's answer to the consent screen. Returns the client redirect URI to send the browser to, carrying an authorization code or error=access_denied.
+// @Tags oauth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body AuthorizeRequest true "the authorization request and the user's answer"
+// @Success 200 {object} AuthorizeResponse
+// @Failure 400 {string} string
+// @Router /oauth/authorize [post]
+func (h *AuthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	var req AuthorizeRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	c, scopes, problem := h.checkAuthorizeRequest(r, &req)
+	if problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+	params := url.Values{}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	if !req.Approve {
+		params.Set("error", "access_denied")
+		json.NewEncoder(w).Encode(AuthorizeResponse{RedirectTo: redirectWith(req.RedirectURI, params)})
+		return
+	}
+	userID, _ := r.Context().Value("user_id").(string)
+	code, err := h.Repo.NewOAuthCode(r.Context(), c.ID, userID, req.RedirectURI, scopes, req.CodeChallenge, h.OAuthCodeTTL)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	params.Set("code", code)
+	json.NewEncoder(w).Encode(AuthorizeResponse{RedirectTo: redirectWith(req.RedirectURI, params)})
+}
+
+// oauthClient authenticates the client calling the token, introspection
+// or revocation endpoint, from HTTP Basic credentials or client_id and
+// client_secret form fields. On failure it writes the error and returns
+// nil.
+func (h *AuthHandler) oauthClient(w http.ResponseWriter, r *http.Request) *model.OAuthClient {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed form body")
+		return nil
+	}
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		// RFC 6749 section 2.3.1 form-encodes Basic credentials
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	c, err := h.Repo.AuthenticateOAuthClient(r.Context(), id, secret)
+	if err != nil {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return nil
+	}
+	return c
+}
+
+// @Summary OAuth token endpoint
+// @Description Exchanges an authorization code (with its PKCE code_verifier) or a refresh token for an access token limited to the consented scopes. Clients authenticate with HTTP Basic or client_id and client_secret; public clients send client_id only.
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "authorization_code or refresh_token"
+// @Param code formData string false "authorization code"
+// @Param redirect_uri formData string false "redirect URI the code was sent to"
+// @Param code_verifier formData string false "PKCE verifier"
+// @Param refresh_token formData string false "refresh token"
+// @Param client_id formData string false "client id"
+// @Param client_secret formData string false "client secret"
+// @Success 200 {object} OAuthTokenResponse
+// @Failure 400 {object} OAuthError
+// @Failure 401 {object} OAuthError
+// @Router /oauth/token [post]
+func (h *AuthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	c := h.oauthClient(w, r)
+	if c == nil {
+		return
+	}
+	var (
+		session *model.Session
+		refresh string
+		err     error
+	)
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		session, refresh, err = h.Repo.ExchangeOAuthCode(r.Context(), c.ID, r.PostForm.Get("code"), r.PostForm.Get("redirect_uri"), r.PostForm.Get("code_verifier"), h.RefreshTTL)
+	case "refresh_token":
+		session, refresh, err = h.Repo.RotateRefreshToken(r.Context(), r.PostForm.Get("refresh_token"), c.ID, h.RefreshTTL)
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "grant_type must be authorization_code or refresh_token")
+		return
+	}
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	}
+	token, err := h.accessToken(r, session)
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "the grant is no longer valid")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(OAuthTokenResponse{
+		AccessToken:  token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(h.AccessTTL.Seconds()),
+		RefreshToken: refresh,
+		Scope:        JoinScopes(session.Scopes),
+	})
+}
+
+// tokenInfo looks up an access or refresh token issued to client c,
+// returning ok false for anything else, including other clients' tokens.
+func (h *AuthHandler) tokenInfo(r *http.Request, c *model.OAuthClient, token string) (claims *Claims, session *model.Session, ok bool) {
+	if claims, err := ParseToken(token); err == nil {
+		if claims.ClientID != c.ID || claims.TenantID != repo.TenantFrom(r.Context()) || h.Repo.TokenRevoked(claims.TokenID) {
+			return nil, nil, false
+		}
+		return claims, nil, true
+	}
+	session, err := h.Repo.RefreshTokenSession(r.Context(), token)
+	if err != nil || session.ClientID != c.ID {
+		return nil, nil, false
+	}
+	return nil, session, true
+}
+
+// @Summary OAuth token introspection
+// @Description Reports whether an access or refresh token issued to the calling client is active (RFC 7662). Tokens of other clients are reported inactive.
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "access or refresh token"
+// @Param token_type_hint formData string false "access_token or refresh_token"
+// @Success 200 {object} IntrospectionResponse
+// @Failure 401 {object} OAuthError
+// @Router /oauth/introspect [post]
+func (h *AuthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	c := h.oauthClient(w, r)
+	if c == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	claims, session, ok := h.tokenInfo(r, c, r.PostForm.Get("token"))
+	switch {
+	case !ok:
+		json.NewEncoder(w).Encode(IntrospectionResponse{Active: false})
+	case claims != nil:
+		json.NewEncoder(w).Encode(IntrospectionResponse{
+			Active:    true,
+			Scope:     JoinScopes(claims.Scopes),
+			ClientID:  claims.ClientID,
+			Subject:   claims.UserID,
+			ExpiresAt: claims.ExpiresAt.Unix(),
+			TokenType: "access_token",
+		})
+	default:
+		json.NewEncoder(w).Encode(IntrospectionResponse{
+			Active:    true,
+			Scope:     JoinScopes(session.Scopes),
+			ClientID:  session.ClientID,
+			Subject:   session.UserID,
+			TokenType: "refresh_token",
+		})
+	}
+}
+
+// @Summary OAuth token revocation
+// @Description Revokes an access token, or a refresh token together with every token of its grant (RFC 7009). Unknown tokens are not an error.
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Param token formData string true "access or refresh token"
+// @Param token_type_hint formData string false "access_token or refresh_token"
+// @Success 200
+// @Failure 401 {object} OAuthError
+// @Router /oauth/revoke [post]
+func (h *AuthHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	c := h.oauthClient(w, r)
+	if c == nil {
+		return
+	}
+	claims, session, ok := h.tokenInfo(r, c, r.PostForm.Get("token"))
+	switch {
+	case !ok:
+	case claims != nil:
+		h.Repo.RevokeAccessToken(claims.TokenID, claims.ExpiresAt)
+	default:
+		_ = h.Repo.RevokeSession(r.Context(), session.ID)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// @Summary List authorized apps
+// @Description The OAuth grants the user has given, one per client session.
+// @Tags oauth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} model.Session
+// @Router /oauth/grants [get]
+func (h *AuthHandler) ListGrants(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(string)
+	json.NewEncoder(w).Encode(h.Repo.ListOAuthGrants(r.Context(), userID))
+}
+
+// @Summary Revoke app access
+// @Description Withdraws consent from a client, revoking its tokens for the user.
+// @Tags oauth
+// @Security BearerAuth
+// @Param client_id path string true "client id"
+// @Success 204
+// @Failure 404 {string} string
+// @Router /oauth/grants/{client_id} [delete]
+func (h *AuthHandler) RevokeGrant(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("user_id").(string)
+	if err := h.Repo.RevokeOAuthGrant(r.Context(), userID, mux.Vars(r)["client_id"]); err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,58 @@
+// Package card implements payment card numbers (ISO/IEC 7812, Luhn check digit).
+package card
+
+import "strings"
+
+// TestBIN is the issuer prefix used for virtual cards. It lies in a range
+// card networks reserve for testing, so numbers never reach a real issuer.
+const TestBIN = "400000"
+
+// checkDigit returns the Luhn digit that completes payload.
+func checkDigit(payload string) byte {
+	sum := 0
+	double := true // the digit left of the check digit is doubled
+	for i := len(payload) - 1; i >= 0; i-- {
+		d := int(payload[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// PAN appends the Luhn check digit to a BIN followed by the account
+// identifier digits.
+func PAN(payload string) string {
+	return payload + string(checkDigit(payload))
+}
+
+// Valid reports whether pan is 12 to 19 digits with a correct check digit.
+func Valid(pan string) bool {
+	if len(pan) < 12 || len(pan) > 19 {
+		return false
+	}
+	for _, c := range pan {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return checkDigit(pan[:len(pan)-1]) == pan[len(pan)-1]
+}
+
+// Normalize strips spaces and dashes from a card number as typed.
+func Normalize(pan string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(pan)
+}
+
+// Mask hides all but the BIN and the last four digits.
+func Mask(pan string) string {
+	if len(pan) < 10 {
+		return pan
+	}
+	return pan[:6] + strings.Repeat("*", len(pan)-10) + pan[len(pan)-4:]
+}
@@ -0,0 +1,326 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TenantConfig describes one bank or brand served by the deployment.
+type TenantConfig struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Hosts are the Host header values that select this tenant.
+	Hosts []string `json:"hosts"`
+	// JWTSecret is shorthand for a single HS256 key of at least 32 bytes;
+	// JWTKeys takes precedence when both are set.
+	JWTSecret string `json:"jwt_secret"`
+	// JWTKeys are the keys the tenant's tokens are signed and verified
+	// with. Tokens are signed with JWTSigningKey, or the first key that
+	// can sign; the others keep verifying tokens issued before a rotation.
+	JWTKeys       []JWTKeyConfig `json:"jwt_keys"`
+	JWTSigningKey string         `json:"jwt_signing_kid"`
+	// Currencies lists the currencies accounts may be opened in; empty
+	// allows any.
+	Currencies []string `json:"currencies"`
+	// IBANCountry and BankCode form the IBANs of the tenant's accounts.
+	IBANCountry string `json:"iban_country"`
+	BankCode    string `json:"bank_code"`
+	// AdminEmails become admins once they register and verify their
+	// address.
+	AdminEmails []string `json:"admin_emails"`
+}
+
+// JWTKeyConfig is one token key. HS256 keys need a Secret of at least 32
+// bytes; RS256 and EdDSA keys need a PEM private key to sign, or only a
+// public key to keep verifying tokens after they have been retired. PEM
+// data may be given inline or as a file path.
+type JWTKeyConfig struct {
+	ID             string `json:"kid"`
+	Alg            string `json:"alg"`
+	Secret         string `json:"secret,omitempty"`
+	PrivateKey     string `json:"private_key,omitempty"`
+	PrivateKeyFile string `json:"private_key_file,omitempty"`
+	PublicKey      string `json:"public_key,omitempty"`
+	PublicKeyFile  string `json:"public_key_file,omitempty"`
+}
+
+// Config holds runtime settings read from the environment.
+type Config struct {
+	// Tenants are the banks served. Requests whose Host matches no tenant
+	// use DefaultTenant.
+	Tenants       []TenantConfig
+	DefaultTenant string
+
+	// AccessTokenTTL is the lifetime of an access token; RefreshTokenTTL
+	// is how long a session may go unused before its refresh token expires.
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// MFAChallengeTTL is how long the second step of a login stays open.
+	MFAChallengeTTL time.Duration
+	// OAuthCodeTTL is how long an OAuth authorization code stays valid.
+	OAuthCodeTTL time.Duration
+	// LoginMaxFailures failed logins in a row lock an email address for
+	// LoginLockout; LoginIPMaxFailures do the same for a client IP. The
+	// wait between failed attempts doubles up to LoginMaxDelay.
+	LoginMaxFailures   int
+	LoginIPMaxFailures int
+	LoginLockout       time.Duration
+	LoginMaxDelay      time.Duration
+
+	// PasswordResetTTL and EmailVerificationTTL are the lifetimes of the
+	// links sent by email.
+	PasswordResetTTL     time.Duration
+	EmailVerificationTTL time.Duration
+	// PasswordResetInterval is how long an address waits between reset
+	// emails.
+	PasswordResetInterval time.Duration
+	// RequireVerifiedEmail stops users who have not verified their email
+	// from moving money out, issuing cards or inviting members.
+	RequireVerifiedEmail bool
+
+	// Mailer selects how email is sent: "smtp" (the default) sends it
+	// through SMTPAddr; "log", for development only, logs messages or,
+	// with MailDir set, writes them there as .eml files.
+	Mailer       string
+	MailFrom     string
+	MailDir      string
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	// PublicURL is the base of links in emails.
+	PublicURL string
+
+	// DormancyPeriod is how long an active account may go without
+	// customer activity before it is marked dormant.
+	DormancyPeriod time.Duration
+	// JobInterval is how often background jobs run.
+	JobInterval time.Duration
+	// PayeeCoolingOff is how long a newly saved beneficiary is subject to
+	// PayeeCoolingOffLimit.
+	PayeeCoolingOff time.Duration
+	// PayeeCoolingOffLimit caps the total sent to a beneficiary while it
+	// is still in its cooling-off window (minor units).
+	PayeeCoolingOffLimit int64
+	// ApprovalThreshold is the amount (minor units) above which a transfer
+	// from a business account needs a second user's approval. Withdrawals
+	// above it are refused.
+	ApprovalThreshold int64
+	// ApprovalTTL is how long a transfer request waits for approval.
+	ApprovalTTL time.Duration
+	// PaymentRequestTTL is the default lifetime of a payment request.
+	PaymentRequestTTL time.Duration
+	// CardHoldTTL is how long an uncaptured card authorization holds funds.
+	CardHoldTTL time.Duration
+	// CardSimulator mounts the unauthenticated /card-network endpoints a
+	// local card network simulator uses. Only enable it in development.
+	CardSimulator bool
+	// ReconciliationWindow is how far apart an external record's date and
+	// a transaction's booking time may be for them to auto-match.
+	ReconciliationWindow time.Duration
+	// DeletedRetention is how long a deleted account can be restored
+	// before the retention job archives it.
+	DeletedRetention time.Duration
+}
+
+// Load reads the configuration from BANKING_* environment variables,
+// falling back to defaults for anything unset or invalid. Tenants are
+// given as a JSON array in BANKING_TENANTS; without it a single "default"
+// tenant is served. A job interval or dormancy period that is set but not
+// positive is an error.
+func Load() (Config, error) {
+	tenants, err := loadTenants()
+	if err != nil {
+		return Config{}, err
+	}
+	def := os.Getenv("BANKING_DEFAULT_TENANT")
+	if def == "" {
+		def = tenants[0].ID
+	}
+	mailerName := envString("BANKING_MAILER", "smtp")
+	if mailerName != "smtp" && mailerName != "log" {
+		return Config{}, fmt.Errorf("BANKING_MAILER: unknown mailer %q", mailerName)
+	}
+	// time.NewTicker panics on a non-positive interval
+	jobInterval := envDuration("BANKING_JOB_INTERVAL", time.Hour)
+	if jobInterval <= 0 {
+		return Config{}, fmt.Errorf("BANKING_JOB_INTERVAL: must be positive, got %s", jobInterval)
+	}
+	dormancy := envDays("BANKING_DORMANCY_DAYS", 365)
+	if dormancy <= 0 {
+		return Config{}, fmt.Errorf("BANKING_DORMANCY_DAYS: must be positive, got %d", dormancy/(24*time.Hour))
+	}
+	return Config{
+		Tenants:       tenants,
+		DefaultTenant: def,
+
+		AccessTokenTTL:  envDuration("BANKING_ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: envDuration("BANKING_REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		MFAChallengeTTL: envDuration("BANKING_MFA_CHALLENGE_TTL", 5*time.Minute),
+		OAuthCodeTTL:    envDuration("BANKING_OAUTH_CODE_TTL", time.Minute),
+
+		LoginMaxFailures:   envInt("BANKING_LOGIN_MAX_FAILURES", 5),
+		LoginIPMaxFailures: envInt("BANKING_LOGIN_IP_MAX_FAILURES", 50),
+		LoginLockout:       envDuration("BANKING_LOGIN_LOCKOUT", 15*time.Minute),
+		LoginMaxDelay:      envDuration("BANKING_LOGIN_MAX_DELAY", 30*time.Second),
+
+		PasswordResetTTL:      envDuration("BANKING_PASSWORD_RESET_TTL", time.Hour),
+		EmailVerificationTTL:  envDuration("BANKING_EMAIL_VERIFICATION_TTL", 48*time.Hour),
+		PasswordResetInterval: envDuration("BANKING_PASSWORD_RESET_INTERVAL", 5*time.Minute),
+		RequireVerifiedEmail:  envBool("BANKING_REQUIRE_VERIFIED_EMAIL", true),
+
+		Mailer:       mailerName,
+		MailFrom:     envString("BANKING_MAIL_FROM", "no-reply@localhost"),
+		MailDir:      os.Getenv("BANKING_MAIL_DIR"),
+		SMTPAddr:     envString("BANKING_SMTP_ADDR", "localhost:1025"),
+		SMTPUsername: os.Getenv("BANKING_SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("BANKING_SMTP_PASSWORD"),
+		PublicURL:    envString("BANKING_PUBLIC_URL", "http://localhost:8080"),
+
+		DormancyPeriod: dormancy,
+		JobInterval:    jobInterval,
+
+		PayeeCoolingOff:      envDuration("BANKING_PAYEE_COOLING_OFF", 24*time.Hour),
+		PayeeCoolingOffLimit: int64(envInt("BANKING_PAYEE_COOLING_OFF_LIMIT", 10000)),
+
+		ApprovalThreshold: int64(envInt("BANKING_APPROVAL_THRESHOLD", 100000)),
+		ApprovalTTL:       envDuration("BANKING_APPROVAL_TTL", 48*time.Hour),
+
+		PaymentRequestTTL: envDuration("BANKING_PAYMENT_REQUEST_TTL", 7*24*time.Hour),
+
+		CardHoldTTL:   envDuration("BANKING_CARD_HOLD_TTL", 7*24*time.Hour),
+		CardSimulator: envBool("BANKING_CARD_SIMULATOR", false),
+
+		ReconciliationWindow: envDuration("BANKING_RECONCILIATION_WINDOW", 72*time.Hour),
+
+		DeletedRetention: envDays("BANKING_DELETED_RETENTION_DAYS", 30),
+	}, nil
+}
+
+func loadTenants() ([]TenantConfig, error) {
+	raw := os.Getenv("BANKING_TENANTS")
+	if raw == "" {
+		t := TenantConfig{
+			ID:            "default",
+			Name:          "Banking API",
+			JWTSecret:     os.Getenv("BANKING_JWT_SECRET"),
+			JWTSigningKey: os.Getenv("BANKING_JWT_SIGNING_KID"),
+			IBANCountry:   envString("BANKING_IBAN_COUNTRY", "DE"),
+			BankCode:      envString("BANKING_BANK_CODE", "10010010"),
+			AdminEmails:   envList("BANKING_ADMIN_EMAILS"),
+		}
+		if keys := os.Getenv("BANKING_JWT_KEYS"); keys != "" {
+			if err := json.Unmarshal([]byte(keys), &t.JWTKeys); err != nil {
+				return nil, fmt.Errorf("BANKING_JWT_KEYS: %w", err)
+			}
+		}
+		if t.JWTSecret == "" && len(t.JWTKeys) == 0 {
+			return nil, errors.New("set BANKING_JWT_SECRET or BANKING_JWT_KEYS")
+		}
+		if err := t.resolveKeys(); err != nil {
+			return nil, fmt.Errorf("BANKING_JWT_KEYS: %w", err)
+		}
+		return []TenantConfig{t}, nil
+	}
+	var tenants []TenantConfig
+	if err := json.Unmarshal([]byte(raw), &tenants); err != nil {
+		return nil, fmt.Errorf("BANKING_TENANTS: %w", err)
+	}
+	if len(tenants) == 0 {
+		return nil, errors.New("BANKING_TENANTS: no tenants configured")
+	}
+	for i := range tenants {
+		t := &tenants[i]
+		if t.ID == "" || (t.JWTSecret == "" && len(t.JWTKeys) == 0) {
+			return nil, errors.New("BANKING_TENANTS: every tenant needs an id and a jwt_secret or jwt_keys")
+		}
+		if len(t.IBANCountry) != 2 || t.BankCode == "" {
+			return nil, fmt.Errorf("BANKING_TENANTS: tenant %q needs an iban_country and a bank_code", t.ID)
+		}
+		if err := t.resolveKeys(); err != nil {
+			return nil, fmt.Errorf("BANKING_TENANTS: tenant %q: %w", t.ID, err)
+		}
+	}
+	return tenants, nil
+}
+
+// resolveKeys turns a bare JWTSecret into an HS256 key and reads PEM
+// files, so the keys are complete once loading succeeds.
+func (t *TenantConfig) resolveKeys() error {
+	if len(t.JWTKeys) == 0 {
+		t.JWTKeys = []JWTKeyConfig{{ID: t.ID + "-hs256", Alg: "HS256", Secret: t.JWTSecret}}
+	}
+	for i := range t.JWTKeys {
+		k := &t.JWTKeys[i]
+		if k.ID == "" {
+			return errors.New("every jwt key needs a kid")
+		}
+		if err := readFile(k.PrivateKeyFile, &k.PrivateKey); err != nil {
+			return fmt.Errorf("key %q: %w", k.ID, err)
+		}
+		if err := readFile(k.PublicKeyFile, &k.PublicKey); err != nil {
+			return fmt.Errorf("key %q: %w", k.ID, err)
+		}
+	}
+	return nil
+}
+
+// readFile sets dst to the contents of path, if path is set.
+func readFile(path string, dst *string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	*dst = string(data)
+	return nil
+}
+
+// envList splits a comma-separated variable, dropping empty items.
+func envList(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func envDays(key string, def int) time.Duration {
+	return time.Duration(envInt(key, def)) * 24 * time.Hour
+}
+
+func envString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+func envBool(key string, def bool) bool {
+	if v, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,301 @@
+package httpservers
+
+import (
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/repo"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxUserSearch caps the results of one user search.
+const maxUserSearch = 200
+
+type adminReasonReq struct {
+	Reason string `json:"reason"`
+}
+
+type adminUserView struct {
+	User *model.User `json:"user"`
+	// Accounts are the user's open accounts, including shared ones.
+	Accounts []*model.Account `json:"accounts"`
+}
+
+type setRolesReq struct {
+	Roles []model.Role `json:"roles"`
+}
+
+type adjustmentReq struct {
+	Amount int64  `json:"amount"` // minor units; negative debits the account
+	Reason string `json:"reason"`
+}
+
+// decodeReason reads an adminReasonReq body, writing a 400 and returning
+// false when the reason is missing.
+func decodeReason(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req adminReasonReq
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.Reason == "" {
+		http.Error(w, "reason required", http.StatusBadRequest)
+		return "", false
+	}
+	return req.Reason, true
+}
+
+// @Summary Search users
+// @Description Matches the user ID exactly, or part of the email or name. Requires the support or admin role.
+// @Tags admin
+// @Security BearerAuth
+// @Param q query string false "search text"
+// @Param limit query int false "maximum results (default 50, at most 200)"
+// @Produce json
+// @Success 200 {array} model.User
+// @Router /admin/users [get]
+func (s *Server) searchUsers(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = min(v, maxUserSearch)
+	}
+	json.NewEncoder(w).Encode(s.repo.SearchUsers(r.Context(), r.URL.Query().Get("q"), limit))
+}
+
+// @Summary Get user
+// @Description The user with their accounts. Requires the support or admin role.
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "user id"
+// @Produce json
+// @Success 200 {object} adminUserView
+// @Failure 404 {string} string
+// @Router /admin/users/{id} [get]
+func (s *Server) getUserAdmin(w http.ResponseWriter, r *http.Request) {
+	u, err := s.repo.GetUserByID(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	accounts, _ := s.repo.ListAccountsByUser(r.Context(), u.ID, "", nil)
+	json.NewEncoder(w).Encode(adminUserView{User: u, Accounts: accounts})
+}
+
+// @Summary Deactivate user
+// @Description Signs the user out everywhere and refuses their logins until reactivated. Requires the admin role.
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "user id"
+// @Param body body adminReasonReq true "reason"
+// @Produce json
+// @Success 200 {object} model.User
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 409 {string} string
+// @Router /admin/users/{id}/deactivate [post]
+func (s *Server) deactivateUser(w http.ResponseWriter, r *http.Request) {
+	s.setUserActive(w, r, false)
+}
+
+// @Summary Reactivate user
+// @Description Requires the admin role.
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "user id"
+// @Param body body adminReasonReq true "reason"
+// @Produce json
+// @Success 200 {object} model.User
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 409 {string} string
+// @Router /admin/users/{id}/activate [post]
+func (s *Server) activateUser(w http.ResponseWriter, r *http.Request) {
+	s.setUserActive(w, r, true)
+}
+
+func (s *Server) setUserActive(w http.ResponseWriter, r *http.Request, active bool) {
+	reason, ok := decodeReason(w, r)
+	if !ok {
+		return
+	}
+	u, err := s.repo.SetUserActive(r.Context(), mux.Vars(r)["id"], active, getUserID(r), reason)
+	switch err {
+	case nil:
+		json.NewEncoder(w).Encode(u)
+	case repo.ErrNotFound:
+		http.Error(w, "not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusConflict)
+	}
+}
+
+// @Summary Set user roles
+// @Description Replaces the user's roles (support, admin). Removing a role signs the user out. Requires the admin role.
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "user id"
+// @Param body body setRolesReq true "roles"
+// @Produce json
+// @Success 200 {object} model.User
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 409 {string} string
+// @Router /admin/users/{id}/roles [put]
+func (s *Server) setUserRoles(w http.ResponseWriter, r *http.Request) {
+	var req setRolesReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	for _, role := range req.Roles {
+		if !role.Valid() {
+			http.Error(w, "unknown role "+strconv.Quote(string(role)), http.StatusBadRequest)
+			return
+		}
+	}
+	u, err := s.repo.SetUserRoles(r.Context(), mux.Vars(r)["id"], req.Roles, getUserID(r))
+	switch err {
+	case nil:
+		json.NewEncoder(w).Encode(u)
+	case repo.ErrNotFound:
+		http.Error(w, "not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusConflict)
+	}
+}
+
+// @Summary Freeze account
+// @Description Blocks payments out of the account. The owner cannot lift the freeze or close the account. Requires the admin role.
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "account id"
+// @Param body body adminReasonReq true "reason"
+// @Produce json
+// @Success 200 {object} model.Account
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 409 {string} string
+// @Router /admin/accounts/{id}/freeze [post]
+func (s *Server) adminFreezeAccount(w http.ResponseWriter, r *http.Request) {
+	reason, ok := decodeReason(w, r)
+	if !ok {
+		return
+	}
+	a, err := s.repo.FreezeAccount(r.Context(), mux.Vars(r)["id"], getUserID(r), reason)
+	switch err {
+	case nil:
+		json.NewEncoder(w).Encode(a)
+	case repo.ErrNotFound:
+		http.Error(w, "not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusConflict)
+	}
+}
+
+// @Summary Unfreeze account
+// @Description Lifts a freeze by the bank or the owner and reactivates the account. Requires the admin role.
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "account id"
+// @Param body body adminReasonReq true "reason"
+// @Produce json
+// @Success 200 {object} model.Account
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 409 {string} string
+// @Router /admin/accounts/{id}/unfreeze [post]
+func (s *Server) adminUnfreezeAccount(w http.ResponseWriter, r *http.Request) {
+	reason, ok := decodeReason(w, r)
+	if !ok {
+		return
+	}
+	a, err := s.repo.UnfreezeAccount(r.Context(), mux.Vars(r)["id"], getUserID(r), reason)
+	switch err {
+	case nil:
+		json.NewEncoder(w).Encode(a)
+	case repo.ErrNotFound:
+		http.Error(w, "not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusConflict)
+	}
+}
+
+// @Summary Adjust balance
+// @Description Books a manual correction: a positive amount credits the account, a negative one debits it. Works on frozen and dormant accounts. Requires the admin role.
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "account id"
+// @Param body body adjustmentReq true "signed amount and reason"
+// @Produce json
+// @Success 201 {object} model.Transaction
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 409 {string} string
+// @Router /admin/accounts/{id}/adjustments [post]
+func (s *Server) adjustBalance(w http.ResponseWriter, r *http.Request) {
+	var req adjustmentReq
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.Amount == 0 || req.Reason == "" {
+		http.Error(w, "non-zero amount and reason required", http.StatusBadRequest)
+		return
+	}
+	t, err := s.repo.AdjustBalance(r.Context(), mux.Vars(r)["id"], req.Amount, getUserID(r), req.Reason)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(t)
+	case repo.ErrNotFound:
+		http.Error(w, "not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusConflict)
+	}
+}
+
+// @Summary List login lockouts
+// @Description Email addresses and client IPs currently locked out after failed logins. Requires the support or admin role.
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} model.LoginThrottle
+// @Router /admin/lockouts [get]
+func (s *Server) listLockouts(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(s.repo.ListLockouts(r.Context(), time.Now()))
+}
+
+// @Summary Unlock user login
+// @Description Requires the support or admin role.
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "user id"
+// @Success 204
+// @Failure 404 {string} string
+// @Router /admin/users/{id}/unlock [post]
+func (s *Server) unlockUser(w http.ResponseWriter, r *http.Request) {
+	if err := s.repo.UnlockUser(r.Context(), mux.Vars(r)["id"], getUserID(r)); err != nil {
+		http.Error(w, "no lockout for this user", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Unlock client IP
+// @Description Requires the support or admin role.
+// @Tags admin
+// @Security BearerAuth
+// @Param ip path string true "client IP"
+// @Success 204
+// @Failure 404 {string} string
+// @Router /admin/ips/{ip}/unlock [post]
+func (s *Server) unlockIP(w http.ResponseWriter, r *http.Request) {
+	if err := s.repo.UnlockIP(r.Context(), mux.Vars(r)["ip"], getUserID(r)); err != nil {
+		http.Error(w, "no lockout for this IP", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,103 @@
+package httpservers
+
+import (
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/repo"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type decisionReq struct {
+	Note string `json:"note,omitempty"`
+}
+
+// @Summary List transfer requests
+// @Tags transfers
+// @Security BearerAuth
+// @Param status query string false "PENDING|APPROVED|EXECUTED|REJECTED|WITHDRAWN|EXPIRED|FAILED"
+// @Produce json
+// @Success 200 {array} model.TransferRequest
+// @Router /transfer-requests [get]
+func (s *Server) listTransferRequests(w http.ResponseWriter, r *http.Request) {
+	status := model.TransferRequestStatus(r.URL.Query().Get("status"))
+	list, _ := s.repo.ListTransferRequests(r.Context(), getUserID(r), status)
+	json.NewEncoder(w).Encode(list)
+}
+
+// @Summary Get transfer request
+// @Tags transfers
+// @Security BearerAuth
+// @Param id path string true "transfer request id"
+// @Produce json
+// @Success 200 {object} model.TransferRequest
+// @Failure 404 {string} string
+// @Router /transfer-requests/{id} [get]
+func (s *Server) getTransferRequest(w http.ResponseWriter, r *http.Request) {
+	tr, err := s.repo.GetTransferRequest(r.Context(), mux.Vars(r)["id"], getUserID(r))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(tr)
+}
+
+// @Summary Approve transfer request
+// @Description Executes the transfer. The approver must be an owner or co-owner of the source account other than the requester. A transfer that fails at execution is returned with status FAILED.
+// @Tags transfers
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "transfer request id"
+// @Param body body decisionReq false "note"
+// @Produce json
+// @Success 200 {object} model.TransferRequest
+// @Failure 403 {string} string
+// @Failure 409 {string} string
+// @Router /transfer-requests/{id}/approve [post]
+func (s *Server) approveTransferRequest(w http.ResponseWriter, r *http.Request) {
+	var req decisionReq
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	tr, err := s.repo.ApproveTransferRequest(r.Context(), mux.Vars(r)["id"], getUserID(r), req.Note)
+	if err != nil {
+		writeDecisionError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(tr)
+}
+
+// @Summary Reject transfer request
+// @Description Owners and co-owners may reject; the requester may reject their own request whatever their role, which marks it WITHDRAWN.
+// @Tags transfers
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "transfer request id"
+// @Param body body decisionReq false "note"
+// @Produce json
+// @Success 200 {object} model.TransferRequest
+// @Failure 403 {string} string
+// @Failure 409 {string} string
+// @Router /transfer-requests/{id}/reject [post]
+func (s *Server) rejectTransferRequest(w http.ResponseWriter, r *http.Request) {
+	var req decisionReq
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	tr, err := s.repo.RejectTransferRequest(r.Context(), mux.Vars(r)["id"], getUserID(r), req.Note)
+	if err != nil {
+		writeDecisionError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(tr)
+}
+
+func writeDecisionError(w http.ResponseWriter, err error) {
+	switch err {
+	case repo.ErrRequestNotFound:
+		http.Error(w, "not found", http.StatusNotFound)
+	case repo.ErrForbidden, repo.ErrSelfApproval:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case repo.ErrRequestSettled, repo.ErrRequestExpired:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,111 @@
+package httpservers
+
+import (
+	"BankingAPI/internal/repo"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxHistoryPoints bounds the size of a balance-history response.
+const maxHistoryPoints = 1000
+
+// historyIntervals maps the interval query parameter to a step function.
+var historyIntervals = map[string]func(time.Time) time.Time{
+	"hour":  func(t time.Time) time.Time { return t.Add(time.Hour) },
+	"day":   func(t time.Time) time.Time { return t.AddDate(0, 0, 1) },
+	"week":  func(t time.Time) time.Time { return t.AddDate(0, 0, 7) },
+	"month": func(t time.Time) time.Time { return t.AddDate(0, 1, 0) },
+}
+
+// @Summary Balance at a point in time
+// @Description Reconstructs the ledger balance from the latest end-of-day snapshot and the transactions after it.
+// @Tags accounts
+// @Security BearerAuth
+// @Param id path string true "account id"
+// @Param as_of query string false "RFC 3339 time or YYYY-MM-DD (default now)"
+// @Produce json
+// @Success 200 {object} model.BalancePoint
+// @Failure 400 {string} string
+// @Router /accounts/{id}/balance [get]
+func (s *Server) accountBalance(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermView, 0); !ok {
+		return
+	}
+	at := time.Now()
+	if v := r.URL.Query().Get("as_of"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			http.Error(w, "invalid as_of", http.StatusBadRequest)
+			return
+		}
+		at = t
+	}
+	p, err := s.repo.BalanceAt(r.Context(), id, at)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(p)
+}
+
+// @Summary Balance history
+// @Description Balance at from and at the end of every interval up to to.
+// @Tags accounts
+// @Security BearerAuth
+// @Param id path string true "account id"
+// @Param from query string true "RFC 3339 time or YYYY-MM-DD"
+// @Param to query string false "RFC 3339 time or YYYY-MM-DD (default now)"
+// @Param interval query string false "hour, day, week or month (default day)"
+// @Produce json
+// @Success 200 {array} model.BalancePoint
+// @Failure 400 {string} string
+// @Router /accounts/{id}/balance-history [get]
+func (s *Server) accountBalanceHistory(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermView, 0); !ok {
+		return
+	}
+	q := r.URL.Query()
+	from, err := parseTimeParam(q.Get("from"))
+	if err != nil {
+		http.Error(w, "from is required", http.StatusBadRequest)
+		return
+	}
+	to := time.Now()
+	if v := q.Get("to"); v != "" {
+		if to, err = parseTimeParam(v); err != nil {
+			http.Error(w, "invalid to", http.StatusBadRequest)
+			return
+		}
+	}
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+	interval := q.Get("interval")
+	if interval == "" {
+		interval = "day"
+	}
+	next, ok := historyIntervals[interval]
+	if !ok {
+		http.Error(w, "interval must be hour, day, week or month", http.StatusBadRequest)
+		return
+	}
+	n := 0
+	for t := from; t.Before(to); t = next(t) {
+		if n++; n > maxHistoryPoints {
+			http.Error(w, "too many points; use a shorter range or a longer interval", http.StatusBadRequest)
+			return
+		}
+	}
+	points, err := s.repo.BalanceHistory(r.Context(), id, from, to, next)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(points)
+}
@@ -0,0 +1,75 @@
+package httpservers
+
+import (
+	"BankingAPI/internal/repo"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type createBeneficiaryReq struct {
+	AccountID string `json:"account_id"`
+	Nickname  string `json:"nickname"`
+}
+
+// @Summary Save beneficiary
+// @Description Until its cooling_off_until has passed, the total sent to a new beneficiary is capped at its cooling_off_limit, however the transfers address it.
+// @Tags beneficiaries
+// @Security BearerAuth
+// @Accept json
+// @Param body body createBeneficiaryReq true "beneficiary"
+// @Produce json
+// @Success 201 {object} model.Beneficiary
+// @Failure 400 {string} string
+// @Failure 409 {string} string
+// @Router /beneficiaries [post]
+func (s *Server) createBeneficiary(w http.ResponseWriter, r *http.Request) {
+	var req createBeneficiaryReq
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.AccountID == "" || req.Nickname == "" {
+		http.Error(w, "account_id and nickname required", http.StatusBadRequest)
+		return
+	}
+	b, err := s.repo.CreateBeneficiary(r.Context(), getUserID(r), req.AccountID, req.Nickname, s.cfg.PayeeCoolingOff, s.cfg.PayeeCoolingOffLimit)
+	if err != nil {
+		switch err {
+		case repo.ErrNotFound:
+			http.Error(w, "account not found", http.StatusBadRequest)
+		case repo.ErrDuplicatePayee:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(b)
+}
+
+// @Summary List beneficiaries
+// @Tags beneficiaries
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} model.Beneficiary
+// @Router /beneficiaries [get]
+func (s *Server) listBeneficiaries(w http.ResponseWriter, r *http.Request) {
+	list, _ := s.repo.ListBeneficiaries(r.Context(), getUserID(r))
+	json.NewEncoder(w).Encode(list)
+}
+
+// @Summary Delete beneficiary
+// @Description A beneficiary deleted while cooling off keeps capping transfers to its account until cooling_off_until; saving it again restores it.
+// @Tags beneficiaries
+// @Security BearerAuth
+// @Param id path string true "beneficiary id"
+// @Success 204 {string} string
+// @Failure 404 {string} string
+// @Router /beneficiaries/{id} [delete]
+func (s *Server) deleteBeneficiary(w http.ResponseWriter, r *http.Request) {
+	if err := s.repo.DeleteBeneficiary(r.Context(), getUserID(r), mux.Vars(r)["id"]); err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,250 @@
+package httpservers
+
+import (
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/repo"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type issueCardReq struct {
+	// HolderID defaults to the caller.
+	HolderID string `json:"holder_id,omitempty"`
+	repo.CardSettings
+}
+
+func writeCardError(w http.ResponseWriter, err error) {
+	switch err {
+	case repo.ErrCardNotFound, repo.ErrAuthorizationNotFound, repo.ErrNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case repo.ErrCardCancelled, repo.ErrAuthorizationSettled, repo.ErrAccountClosed, repo.ErrAccountFrozen, repo.ErrAccountInactive:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// @Summary Issue virtual card
+// @Description The full card number and CVV are only returned in this response.
+// @Tags cards
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "account id"
+// @Param body body issueCardReq false "holder and controls"
+// @Produce json
+// @Success 201 {object} repo.IssuedCard
+// @Failure 400 {string} string
+// @Failure 409 {string} string "account deleted, frozen or closed"
+// @Router /accounts/{id}/cards [post]
+func (s *Server) issueCard(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermManage, 0); !ok {
+		return
+	}
+	var req issueCardReq
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.HolderID == "" {
+		req.HolderID = getUserID(r)
+	}
+	c, err := s.repo.IssueCard(r.Context(), id, req.HolderID, getUserID(r), req.CardSettings)
+	if err != nil {
+		writeCardError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// @Summary List cards
+// @Tags cards
+// @Security BearerAuth
+// @Param id path string true "account id"
+// @Produce json
+// @Success 200 {array} model.Card
+// @Router /accounts/{id}/cards [get]
+func (s *Server) listCards(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermView, 0); !ok {
+		return
+	}
+	list, err := s.repo.ListCards(r.Context(), id)
+	if err != nil {
+		writeCardError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// @Summary Update card controls
+// @Description Changes limits and merchant category restrictions; omitted fields are kept.
+// @Tags cards
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "account id"
+// @Param card_id path string true "card id"
+// @Param body body repo.CardSettings true "controls"
+// @Produce json
+// @Success 200 {object} model.Card
+// @Failure 400 {string} string
+// @Router /accounts/{id}/cards/{card_id} [patch]
+func (s *Server) updateCard(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if _, ok := s.authorizeAccount(w, r, vars["id"], repo.PermManage, 0); !ok {
+		return
+	}
+	var req repo.CardSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	c, err := s.repo.UpdateCard(r.Context(), vars["id"], vars["card_id"], getUserID(r), req)
+	if err != nil {
+		writeCardError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(c)
+}
+
+// @Summary Freeze card
+// @Description New authorizations are declined until the card is unfrozen. Any member who can initiate payments may freeze a card; unfreezing needs manage rights.
+// @Tags cards
+// @Security BearerAuth
+// @Param id path string true "account id"
+// @Param card_id path string true "card id"
+// @Produce json
+// @Success 200 {object} model.Card
+// @Router /accounts/{id}/cards/{card_id}/freeze [post]
+func (s *Server) freezeCard(w http.ResponseWriter, r *http.Request) {
+	s.setCardStatus(w, r, model.CardFrozen, repo.PermInitiate)
+}
+
+// @Summary Unfreeze card
+// @Tags cards
+// @Security BearerAuth
+// @Param id path string true "account id"
+// @Param card_id path string true "card id"
+// @Produce json
+// @Success 200 {object} model.Card
+// @Router /accounts/{id}/cards/{card_id}/unfreeze [post]
+func (s *Server) unfreezeCard(w http.ResponseWriter, r *http.Request) {
+	s.setCardStatus(w, r, model.CardActive, repo.PermManage)
+}
+
+// @Summary Cancel card
+// @Tags cards
+// @Security BearerAuth
+// @Param id path string true "account id"
+// @Param card_id path string true "card id"
+// @Produce json
+// @Success 200 {object} model.Card
+// @Router /accounts/{id}/cards/{card_id} [delete]
+func (s *Server) cancelCard(w http.ResponseWriter, r *http.Request) {
+	s.setCardStatus(w, r, model.CardCancelled, repo.PermManage)
+}
+
+func (s *Server) setCardStatus(w http.ResponseWriter, r *http.Request, status model.CardStatus, perm repo.Permission) {
+	vars := mux.Vars(r)
+	if _, ok := s.authorizeAccount(w, r, vars["id"], perm, 0); !ok {
+		return
+	}
+	c, err := s.repo.SetCardStatus(r.Context(), vars["id"], vars["card_id"], getUserID(r), status)
+	if err != nil {
+		writeCardError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(c)
+}
+
+// @Summary List card authorizations
+// @Tags cards
+// @Security BearerAuth
+// @Param id path string true "account id"
+// @Param card_id path string true "card id"
+// @Produce json
+// @Success 200 {array} model.CardAuthorization
+// @Router /accounts/{id}/cards/{card_id}/authorizations [get]
+func (s *Server) listCardAuthorizations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if _, ok := s.authorizeAccount(w, r, vars["id"], repo.PermView, 0); !ok {
+		return
+	}
+	list, err := s.repo.ListCardAuthorizations(r.Context(), vars["id"], vars["card_id"])
+	if err != nil {
+		writeCardError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// @Summary Authorize card payment (simulator)
+// @Description Card network entry point for a local simulator, mounted when BANKING_CARD_SIMULATOR is set. Approved payments place a hold on the account; declines return 402 with the reason. Three wrong CVVs in a row freeze the card.
+// @Tags card-network
+// @Accept json
+// @Param body body repo.CardAuthRequest true "authorization request"
+// @Produce json
+// @Success 201 {object} model.CardAuthorization
+// @Failure 402 {object} model.CardAuthorization
+// @Failure 404 {string} string
+// @Router /card-network/authorizations [post]
+func (s *Server) authorizeCard(w http.ResponseWriter, r *http.Request) {
+	var req repo.CardAuthRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+	auth, err := s.repo.AuthorizeCard(r.Context(), req, s.cfg.CardHoldTTL)
+	if err != nil {
+		writeCardError(w, err)
+		return
+	}
+	if auth.Status == model.AuthorizationDeclined {
+		w.WriteHeader(http.StatusPaymentRequired)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(auth)
+}
+
+type captureReq struct {
+	// Amount defaults to the authorized amount.
+	Amount int64 `json:"amount,omitempty"`
+}
+
+// @Summary Capture card authorization (simulator)
+// @Description Settles the hold into a withdrawal. A smaller amount releases the remainder.
+// @Tags card-network
+// @Accept json
+// @Param id path string true "authorization id"
+// @Param body body captureReq false "amount"
+// @Produce json
+// @Success 200 {object} model.Transaction
+// @Failure 409 {string} string
+// @Router /card-network/authorizations/{id}/capture [post]
+func (s *Server) captureAuthorization(w http.ResponseWriter, r *http.Request) {
+	var req captureReq
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	_, t, err := s.repo.CaptureAuthorization(r.Context(), mux.Vars(r)["id"], req.Amount)
+	if err != nil {
+		writeCardError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(t)
+}
+
+// @Summary Release card authorization (simulator)
+// @Tags card-network
+// @Param id path string true "authorization id"
+// @Produce json
+// @Success 200 {object} model.CardAuthorization
+// @Failure 409 {string} string
+// @Router /card-network/authorizations/{id}/release [post]
+func (s *Server) releaseAuthorization(w http.ResponseWriter, r *http.Request) {
+	auth, err := s.repo.ReleaseAuthorization(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeCardError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(auth)
+}
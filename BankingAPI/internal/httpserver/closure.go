@@ -0,0 +1,49 @@
+package httpservers
+
+import (
+	"BankingAPI/internal/repo"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type closeAccountReq struct {
+	SweepToAccountID string `json:"sweep_to_account_id,omitempty"`
+	Reason           string `json:"reason"`
+}
+
+// @Summary Close account
+// @Description Permanently closes the account. A remaining balance is swept to sweep_to_account_id, which must belong to the same owner and use the same currency.
+// @Tags accounts
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "account id"
+// @Param body body closeAccountReq true "closure"
+// @Produce json
+// @Success 200 {object} repo.ClosureResult
+// @Failure 400 {string} string
+// @Failure 409 {string} string
+// @Router /accounts/{id}/close [post]
+func (s *Server) closeAccount(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermManage, 0); !ok {
+		return
+	}
+	var req closeAccountReq
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.Reason == "" {
+		req.Reason = "closed by owner"
+	}
+	res, err := s.repo.CloseAccount(r.Context(), id, req.SweepToAccountID, getUserID(r), req.Reason)
+	if err != nil {
+		switch err {
+		case repo.ErrAccountClosed, repo.ErrInvalidStatus, repo.ErrPendingItems, repo.ErrAdminFrozen:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,100 @@
+package httpservers
+
+import (
+	"BankingAPI/internal/repo"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultInsightsMonths    = 6
+	defaultTopCounterparties = 5
+)
+
+// @Summary Spending insights
+// @Description Spending per category per month, top counterparties, income vs outflow and the change against the previous period of equal length. Moves between the caller's own accounts are left out.
+// @Tags insights
+// @Security BearerAuth
+// @Param from query string false "period start (RFC 3339 or YYYY-MM-DD), default six months before to"
+// @Param to query string false "period end, exclusive (default now)"
+// @Param currency query string false "required when the caller's accounts use several currencies"
+// @Param top query int false "number of counterparties (default 5)"
+// @Produce json
+// @Success 200 {object} insights.Report
+// @Failure 400 {string} string
+// @Router /insights [get]
+func (s *Server) insights(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	to := time.Now()
+	if v := q.Get("to"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			http.Error(w, "invalid to", http.StatusBadRequest)
+			return
+		}
+		to = t
+	}
+	from := to.AddDate(0, -defaultInsightsMonths, 0)
+	if v := q.Get("from"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			http.Error(w, "invalid from", http.StatusBadRequest)
+			return
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+	top := defaultTopCounterparties
+	if v := q.Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid top", http.StatusBadRequest)
+			return
+		}
+		top = n
+	}
+	rep, err := s.repo.Insights(r.Context(), getUserID(r), q.Get("currency"), from, to, top)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(rep)
+}
+
+type categoryReq struct {
+	Category string `json:"category"`
+}
+
+// @Summary Override transaction category
+// @Description Sets the caller's category for a transaction. If it has a merchant, the category is remembered for that merchant's future transactions.
+// @Tags insights
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "transaction id"
+// @Param body body categoryReq true "category"
+// @Produce json
+// @Success 200 {object} model.Transaction
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Router /transactions/{id}/category [put]
+func (s *Server) setTransactionCategory(w http.ResponseWriter, r *http.Request) {
+	var req categoryReq
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	t, err := s.repo.SetTransactionCategory(r.Context(), getUserID(r), mux.Vars(r)["id"], req.Category)
+	if err != nil {
+		code := http.StatusBadRequest
+		if err == repo.ErrNotFound {
+			code = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	json.NewEncoder(w).Encode(t)
+}
@@ -0,0 +1,237 @@
+package httpservers
+
+import (
+	"BankingAPI/internal/iban"
+	"BankingAPI/internal/iso20022"
+	"BankingAPI/internal/repo"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxPain001Size bounds an uploaded pain.001 file.
+const maxPain001Size = 5 << 20
+
+// messageID returns a fresh ISO 20022 message identifier (Max35Text).
+func messageID() string {
+	return strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
+func writeXML(w http.ResponseWriter, code int, body []byte) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(code)
+	w.Write(body)
+}
+
+// @Summary camt.053 statement
+// @Description End-of-day bank-to-customer statement (camt.053.001.08) for one UTC day.
+// @Tags iso20022
+// @Security BearerAuth
+// @Param id path string true "account id"
+// @Param date query string true "statement day, YYYY-MM-DD"
+// @Produce xml
+// @Success 200 {string} string
+// @Failure 400 {string} string
+// @Router /accounts/{id}/statements/camt053 [get]
+func (s *Server) camt053Statement(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermView, 0); !ok {
+		return
+	}
+	day, err := time.Parse("2006-01-02", r.URL.Query().Get("date"))
+	if err != nil {
+		http.Error(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	l, err := s.repo.LedgerBetween(r.Context(), id, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	stmt := &iso20022.Statement{
+		MessageID: messageID(),
+		ID:        fmt.Sprintf("%s-%s", l.Account.Number, day.Format("20060102")),
+		IBAN:      l.Account.IBAN,
+		Currency:  l.Account.Currency,
+		From:      day,
+		To:        day.AddDate(0, 0, 1),
+		Opening:   l.Opening,
+		Closing:   l.Closing,
+	}
+	if u, err := s.repo.GetUserByID(r.Context(), l.Account.UserID); err == nil {
+		stmt.OwnerName = u.Name
+	}
+	for _, t := range l.Transactions {
+		e := iso20022.StatementEntry{
+			Reference: strings.ReplaceAll(t.ID, "-", ""),
+			Amount:    repo.BalanceDelta(t),
+			BookedAt:  t.CreatedAt,
+			Code:      string(t.Type),
+		}
+		e.EndToEndID, _ = t.Meta["end_to_end_id"].(string)
+		for _, k := range []string{"description", "merchant", "reason"} {
+			if v, ok := t.Meta[k].(string); ok && v != "" {
+				e.Info = v
+				break
+			}
+		}
+		stmt.Entries = append(stmt.Entries, e)
+	}
+	out, err := iso20022.MarshalCamt053(stmt, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="camt053-%s.xml"`, stmt.ID))
+	writeXML(w, http.StatusOK, out)
+}
+
+// @Summary Submit pain.001 credit transfers
+// @Description Parses and validates a pain.001 (001.03 or 001.09) file and executes its transfers immediately, regardless of the requested execution date. Transfers that need approval become transfer requests and are reported as pending. Returns a pain.002.001.10 status report; a file that fails validation is rejected as a whole.
+// @Tags iso20022
+// @Security BearerAuth
+// @Accept xml
+// @Produce xml
+// @Success 200 {string} string "pain.002 report"
+// @Failure 400 {string} string "pain.002 report rejecting the file"
+// @Failure 409 {string} string "pain.002 report rejecting a duplicate message ID"
+// @Router /payments/pain001 [post]
+func (s *Server) submitPain001(w http.ResponseWriter, r *http.Request) {
+	reject := func(code int, orig *iso20022.Pain001, reasons ...iso20022.Reason) {
+		rep := &iso20022.StatusReport{MessageID: messageID(), GroupStatus: iso20022.StatusRejected, GroupReasons: reasons}
+		if orig != nil {
+			rep.OriginalMessageID = orig.GrpHdr.MsgId
+			rep.OriginalNamespace = orig.Namespace()
+			rep.OriginalNbOfTxs = orig.GrpHdr.NbOfTxs
+		}
+		out, _ := iso20022.MarshalPain002(rep, time.Now())
+		writeXML(w, code, out)
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPain001Size))
+	if err != nil {
+		reject(http.StatusBadRequest, nil, iso20022.Reason{Code: iso20022.ReasonInvalidFile, Info: err.Error()})
+		return
+	}
+	doc, err := iso20022.ParsePain001(data)
+	if err != nil {
+		reject(http.StatusBadRequest, nil, iso20022.Reason{Code: iso20022.ReasonInvalidFile, Info: "malformed XML: " + err.Error()})
+		return
+	}
+	if errs := doc.Validate(); len(errs) > 0 {
+		reasons := make([]iso20022.Reason, 0, len(errs))
+		for _, e := range errs {
+			reasons = append(reasons, iso20022.Reason{Code: iso20022.ReasonInvalidFile, Info: e})
+		}
+		reject(http.StatusBadRequest, doc, reasons...)
+		return
+	}
+	if !s.repo.ClaimMessageID(r.Context(), doc.GrpHdr.MsgId) {
+		reject(http.StatusConflict, doc, iso20022.Reason{Code: iso20022.ReasonDuplicate, Info: "message ID already submitted"})
+		return
+	}
+
+	rep := &iso20022.StatusReport{
+		MessageID:         messageID(),
+		OriginalMessageID: doc.GrpHdr.MsgId,
+		OriginalNamespace: doc.Namespace(),
+		OriginalNbOfTxs:   doc.GrpHdr.NbOfTxs,
+	}
+	var blockStatuses []string
+	for _, pi := range doc.PmtInf {
+		ps := iso20022.PaymentStatus{OriginalPmtInfID: pi.PmtInfId}
+		var txStatuses []string
+		for _, tx := range pi.CdtTrfTxInf {
+			ts := s.executeCreditTransfer(r, doc, &pi, &tx)
+			ps.Transactions = append(ps.Transactions, ts)
+			txStatuses = append(txStatuses, ts.Status)
+		}
+		ps.Status = iso20022.Summarize(txStatuses)
+		rep.Payments = append(rep.Payments, ps)
+		blockStatuses = append(blockStatuses, ps.Status)
+	}
+	rep.GroupStatus = iso20022.Summarize(blockStatuses)
+	out, err := iso20022.MarshalPain002(rep, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeXML(w, http.StatusOK, out)
+}
+
+// executeCreditTransfer runs one pain.001 instruction as the caller,
+// following the same rules as POST /transfers.
+func (s *Server) executeCreditTransfer(r *http.Request, doc *iso20022.Pain001, pi *iso20022.PaymentInfo, tx *iso20022.CreditTransfer) iso20022.TransactionStatus {
+	ts := iso20022.TransactionStatus{OriginalInstrID: tx.InstrId, OriginalEndToEndID: tx.EndToEndId, Status: iso20022.StatusRejected}
+	fail := func(code, info string) iso20022.TransactionStatus {
+		ts.Reason = &iso20022.Reason{Code: code, Info: info}
+		return ts
+	}
+	ctx := r.Context()
+	debtor, err := s.repo.FindAccountByIBAN(ctx, iban.Normalize(pi.DbtrIBAN))
+	if err != nil {
+		return fail(iso20022.ReasonIncorrectAccount, "debtor account not found")
+	}
+	from, err := s.repo.Authorize(ctx, debtor.ID, getUserID(r), repo.PermInitiate, tx.Amount)
+	switch err {
+	case nil:
+	case repo.ErrLimitExceeded:
+		return fail(iso20022.ReasonNotAllowedAmount, err.Error())
+	default:
+		return fail(iso20022.ReasonForbidden, "not allowed to pay from the debtor account")
+	}
+	if tx.Amt.Ccy != from.Currency {
+		return fail(iso20022.ReasonWrongCurrency, "debtor account is held in "+from.Currency)
+	}
+	creditor, err := s.repo.FindAccountByIBAN(ctx, iban.Normalize(tx.CdtrIBAN))
+	if err != nil {
+		return fail(iso20022.ReasonIncorrectAccount, "creditor account is not held at this bank")
+	}
+	if tx.Amt.Ccy != creditor.Currency {
+		return fail(iso20022.ReasonWrongCurrency, "creditor account is held in "+creditor.Currency)
+	}
+	meta := map[string]interface{}{
+		"end_to_end_id":  tx.EndToEndId,
+		"pain001_msg_id": doc.GrpHdr.MsgId,
+		"pmt_inf_id":     pi.PmtInfId,
+		"creditor_name":  tx.CdtrNm,
+	}
+	if tx.Ustrd != "" {
+		meta["description"] = tx.Ustrd
+	}
+	if repo.NeedsApproval(from, tx.Amount, s.cfg.ApprovalThreshold) {
+		tr, err := s.repo.CreateTransferRequest(ctx, getUserID(r), from.ID, creditor.ID, tx.Amount, meta, s.cfg.ApprovalTTL)
+		if err != nil {
+			return fail(iso20022.ReasonNarrative, err.Error())
+		}
+		ts.Status = iso20022.StatusPending
+		ts.Reason = &iso20022.Reason{Code: iso20022.ReasonNarrative, Info: "awaiting approval, transfer request " + tr.ID}
+		return ts
+	}
+	if _, _, err := s.repo.Transfer(ctx, getUserID(r), from.ID, creditor.ID, tx.Amount, meta); err != nil {
+		return fail(transferReason(err), err.Error())
+	}
+	ts.Status = iso20022.StatusSettled
+	return ts
+}
+
+// transferReason maps a transfer error to an ISO 20022 status reason.
+func transferReason(err error) string {
+	switch err {
+	case repo.ErrInsufficient:
+		return iso20022.ReasonInsufficientFunds
+	case repo.ErrCoolingOffLimit:
+		return iso20022.ReasonNotAllowedAmount
+	case repo.ErrAccountClosed:
+		return iso20022.ReasonClosedAccount
+	case repo.ErrAccountFrozen, repo.ErrAccountDormant, repo.ErrAccountInactive:
+		return iso20022.ReasonBlockedAccount
+	case repo.ErrNotFound:
+		return iso20022.ReasonIncorrectAccount
+	}
+	return iso20022.ReasonNarrative
+}
@@ -0,0 +1,86 @@
+package httpservers
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// runJobs runs periodic maintenance until Shutdown is called.
+func (s *Server) runJobs() {
+	ticker := time.NewTicker(s.cfg.JobInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.markDormant()
+			s.expireTransferRequests()
+			s.snapshotBalances()
+			s.expireCardHolds()
+			s.archiveDeletedAccounts()
+			s.pruneTokens()
+			s.pruneBeneficiaries()
+		}
+	}
+}
+
+func (s *Server) markDormant() {
+	accs, errs := s.repo.MarkDormant(context.Background(), time.Now().Add(-s.cfg.DormancyPeriod))
+	for _, err := range errs {
+		log.Printf("dormancy job: not marked: %v", err)
+	}
+	if len(accs) > 0 {
+		log.Printf("dormancy job: %d account(s) marked dormant", len(accs))
+	}
+}
+
+func (s *Server) expireTransferRequests() {
+	if n := s.repo.ExpireTransferRequests(context.Background(), time.Now()); n > 0 {
+		log.Printf("approval job: %d transfer request(s) expired", n)
+	}
+}
+
+// snapshotBalances records end-of-day balances for every day completed
+// since the last run.
+func (s *Server) snapshotBalances() {
+	if n := s.repo.SnapshotBalances(context.Background(), time.Now()); n > 0 {
+		log.Printf("balance snapshot job: %d snapshot(s) written", n)
+	}
+}
+
+func (s *Server) expireCardHolds() {
+	if n := s.repo.ExpireAuthorizations(context.Background(), time.Now()); n > 0 {
+		log.Printf("card hold job: %d authorization(s) expired", n)
+	}
+}
+
+// archiveDeletedAccounts archives accounts whose restore window passed.
+func (s *Server) archiveDeletedAccounts() {
+	ids, errs := s.repo.ArchiveDeletedAccounts(context.Background(), time.Now().Add(-s.cfg.DeletedRetention))
+	for _, err := range errs {
+		log.Printf("retention job: not archived: %v", err)
+	}
+	if len(ids) > 0 {
+		log.Printf("retention job: %d account(s) archived", len(ids))
+	}
+}
+
+// pruneTokens drops expired revocations, refresh tokens, login
+// challenges, email tokens, failed login counts and OAuth codes.
+func (s *Server) pruneTokens() {
+	now := time.Now()
+	n := s.repo.PruneTokens(now) + s.repo.PruneMFAChallenges(now) + s.repo.PruneUserTokens(now) +
+		s.repo.PruneLoginThrottles(now, s.cfg.LoginLockout) + s.repo.PruneOAuthCodes(now)
+	if n > 0 {
+		log.Printf("token job: %d expired token record(s) pruned", n)
+	}
+}
+
+// pruneBeneficiaries drops removed payees once their cooling-off ends.
+func (s *Server) pruneBeneficiaries() {
+	if n := s.repo.PruneBeneficiaries(time.Now()); n > 0 {
+		log.Printf("beneficiary job: %d removed payee(s) pruned", n)
+	}
+}
@@ -0,0 +1,130 @@
+package httpservers
+
+import (
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/repo"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type inviteMemberReq struct {
+	Email string           `json:"email"`
+	Role  model.MemberRole `json:"role"`
+	Limit int64            `json:"limit,omitempty"`
+}
+
+// @Summary Invite account member
+// @Description Roles: CO_OWNER, VIEWER, or INITIATOR (requires limit, the maximum amount per payment).
+// @Description Co-owners move money and approve transfer requests like the owner, but only the owner may manage the account, its cards and its members.
+// @Tags members
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "account id"
+// @Param body body inviteMemberReq true "invitation"
+// @Produce json
+// @Success 201 {object} model.AccountMember
+// @Failure 400 {string} string
+// @Failure 409 {string} string
+// @Router /accounts/{id}/members [post]
+func (s *Server) inviteMember(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermManage, 0); !ok {
+		return
+	}
+	var req inviteMemberReq
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.Email == "" || req.Role == "" {
+		http.Error(w, "email and role required", http.StatusBadRequest)
+		return
+	}
+	m, err := s.repo.InviteMember(r.Context(), id, req.Email, req.Role, req.Limit, getUserID(r))
+	if err != nil {
+		switch err {
+		case repo.ErrNotFound:
+			http.Error(w, "user not found", http.StatusBadRequest)
+		case repo.ErrAlreadyMember, repo.ErrAccountClosed:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(m)
+}
+
+// @Summary List account members
+// @Tags members
+// @Security BearerAuth
+// @Param id path string true "account id"
+// @Produce json
+// @Success 200 {array} model.AccountMember
+// @Router /accounts/{id}/members [get]
+func (s *Server) listMembers(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermView, 0); !ok {
+		return
+	}
+	list, _ := s.repo.ListMembers(r.Context(), id)
+	json.NewEncoder(w).Encode(list)
+}
+
+// @Summary Revoke account member
+// @Description Owners may revoke anyone except the primary owner; members may remove themselves.
+// @Tags members
+// @Security BearerAuth
+// @Param id path string true "account id"
+// @Param user_id path string true "member user id"
+// @Success 204 {string} string
+// @Failure 409 {string} string
+// @Router /accounts/{id}/members/{user_id} [delete]
+func (s *Server) revokeMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, memberID := vars["id"], vars["user_id"]
+	perm := repo.PermManage
+	if memberID == getUserID(r) {
+		perm = repo.PermView
+	}
+	if _, ok := s.authorizeAccount(w, r, id, perm, 0); !ok {
+		return
+	}
+	if err := s.repo.RevokeMember(r.Context(), id, memberID, getUserID(r)); err != nil {
+		if err == repo.ErrPrimaryOwner {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Accept account invitation
+// @Tags members
+// @Security BearerAuth
+// @Param id path string true "account id"
+// @Produce json
+// @Success 200 {object} model.AccountMember
+// @Failure 404 {string} string
+// @Router /accounts/{id}/members/accept [post]
+func (s *Server) acceptInvitation(w http.ResponseWriter, r *http.Request) {
+	m, err := s.repo.AcceptInvitation(r.Context(), mux.Vars(r)["id"], getUserID(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(m)
+}
+
+// @Summary List my pending invitations
+// @Tags members
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} model.AccountMember
+// @Router /invitations [get]
+func (s *Server) listInvitations(w http.ResponseWriter, r *http.Request) {
+	list, _ := s.repo.ListInvitations(r.Context(), getUserID(r))
+	json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,228 @@
+package httpservers
+
+import (
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/repo"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type createPaymentRequestReq struct {
+	ToAccountID string `json:"to_account_id"`
+	Amount      int64  `json:"amount"`
+	PayerEmail  string `json:"payer_email,omitempty"`
+	Note        string `json:"note,omitempty"`
+	// ExpiresIn is a Go duration such as "72h"; defaults to the server setting.
+	ExpiresIn string `json:"expires_in,omitempty"`
+}
+
+type paymentRequestCreated struct {
+	PaymentRequest *model.PaymentRequest `json:"payment_request"`
+	// PayLink can be shared; anyone holding it can view the request and,
+	// if no payer was named, pay it.
+	PayLink string `json:"pay_link"`
+}
+
+type payReq struct {
+	FromAccountID string `json:"from_account_id"`
+}
+
+// @Summary Request money
+// @Description Without payer_email the request is open and can be paid by anyone with the pay link.
+// @Tags payment-requests
+// @Security BearerAuth
+// @Accept json
+// @Param body body createPaymentRequestReq true "payment request"
+// @Produce json
+// @Success 201 {object} paymentRequestCreated
+// @Failure 400 {string} string
+// @Router /payment-requests [post]
+func (s *Server) createPaymentRequest(w http.ResponseWriter, r *http.Request) {
+	var req createPaymentRequestReq
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.ToAccountID == "" || req.Amount <= 0 {
+		http.Error(w, "to_account_id and a positive amount required", http.StatusBadRequest)
+		return
+	}
+	ttl := s.cfg.PaymentRequestTTL
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid expires_in", http.StatusBadRequest)
+			return
+		}
+		ttl = d
+	}
+	if _, ok := s.authorizeAccount(w, r, req.ToAccountID, repo.PermInitiate, 0); !ok {
+		return
+	}
+	payerID := ""
+	if req.PayerEmail != "" {
+		payer, err := s.repo.GetUserByEmail(r.Context(), req.PayerEmail)
+		if err != nil {
+			http.Error(w, "payer not found", http.StatusBadRequest)
+			return
+		}
+		payerID = payer.ID
+	}
+	pr, token, err := s.repo.CreatePaymentRequest(r.Context(), getUserID(r), req.ToAccountID, payerID, req.Amount, req.Note, ttl)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(paymentRequestCreated{PaymentRequest: pr, PayLink: "/pay/" + token})
+}
+
+// @Summary List payment requests
+// @Tags payment-requests
+// @Security BearerAuth
+// @Param direction query string false "incoming|outgoing"
+// @Produce json
+// @Success 200 {array} model.PaymentRequest
+// @Router /payment-requests [get]
+func (s *Server) listPaymentRequests(w http.ResponseWriter, r *http.Request) {
+	list, _ := s.repo.ListPaymentRequests(r.Context(), getUserID(r), r.URL.Query().Get("direction"))
+	json.NewEncoder(w).Encode(list)
+}
+
+// @Summary Get payment request
+// @Tags payment-requests
+// @Security BearerAuth
+// @Param id path string true "payment request id"
+// @Produce json
+// @Success 200 {object} model.PaymentRequest
+// @Failure 404 {string} string
+// @Router /payment-requests/{id} [get]
+func (s *Server) getPaymentRequest(w http.ResponseWriter, r *http.Request) {
+	pr, err := s.repo.GetPaymentRequest(r.Context(), mux.Vars(r)["id"], getUserID(r))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(pr)
+}
+
+// @Summary Pay payment request
+// @Tags payment-requests
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "payment request id"
+// @Param body body payReq true "source account"
+// @Produce json
+// @Success 200 {object} model.PaymentRequest
+// @Failure 400 {string} string
+// @Failure 409 {string} string
+// @Router /payment-requests/{id}/pay [post]
+func (s *Server) payPaymentRequest(w http.ResponseWriter, r *http.Request) {
+	pr, err := s.repo.GetPaymentRequest(r.Context(), mux.Vars(r)["id"], getUserID(r))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	s.pay(w, r, pr)
+}
+
+// @Summary View pay link
+// @Tags payment-requests
+// @Security BearerAuth
+// @Param token path string true "pay link token"
+// @Produce json
+// @Success 200 {object} model.PaymentRequest
+// @Failure 404 {string} string
+// @Router /pay/{token} [get]
+func (s *Server) getPayLink(w http.ResponseWriter, r *http.Request) {
+	pr, err := s.repo.GetPaymentRequestByToken(r.Context(), mux.Vars(r)["token"])
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(pr)
+}
+
+// @Summary Pay via pay link
+// @Tags payment-requests
+// @Security BearerAuth
+// @Accept json
+// @Param token path string true "pay link token"
+// @Param body body payReq true "source account"
+// @Produce json
+// @Success 200 {object} model.PaymentRequest
+// @Failure 400 {string} string
+// @Failure 409 {string} string
+// @Router /pay/{token} [post]
+func (s *Server) payPayLink(w http.ResponseWriter, r *http.Request) {
+	pr, err := s.repo.GetPaymentRequestByToken(r.Context(), mux.Vars(r)["token"])
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	s.pay(w, r, pr)
+}
+
+func (s *Server) pay(w http.ResponseWriter, r *http.Request, pr *model.PaymentRequest) {
+	var req payReq
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	from, ok := s.authorizeAccount(w, r, req.FromAccountID, repo.PermInitiate, pr.Amount)
+	if !ok {
+		return
+	}
+	if repo.NeedsApproval(from, pr.Amount, s.cfg.ApprovalThreshold) {
+		http.Error(w, "amount requires approval; pay with POST /transfers instead", http.StatusConflict)
+		return
+	}
+	paid, err := s.repo.PayPaymentRequest(r.Context(), pr.ID, getUserID(r), req.FromAccountID)
+	if err != nil {
+		writePaymentRequestError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(paid)
+}
+
+// @Summary Decline payment request
+// @Tags payment-requests
+// @Security BearerAuth
+// @Param id path string true "payment request id"
+// @Produce json
+// @Success 200 {object} model.PaymentRequest
+// @Failure 409 {string} string
+// @Router /payment-requests/{id}/decline [post]
+func (s *Server) declinePaymentRequest(w http.ResponseWriter, r *http.Request) {
+	pr, err := s.repo.DeclinePaymentRequest(r.Context(), mux.Vars(r)["id"], getUserID(r))
+	if err != nil {
+		writePaymentRequestError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(pr)
+}
+
+// @Summary Cancel payment request
+// @Tags payment-requests
+// @Security BearerAuth
+// @Param id path string true "payment request id"
+// @Produce json
+// @Success 200 {object} model.PaymentRequest
+// @Failure 409 {string} string
+// @Router /payment-requests/{id}/cancel [post]
+func (s *Server) cancelPaymentRequest(w http.ResponseWriter, r *http.Request) {
+	pr, err := s.repo.CancelPaymentRequest(r.Context(), mux.Vars(r)["id"], getUserID(r))
+	if err != nil {
+		writePaymentRequestError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(pr)
+}
+
+func writePaymentRequestError(w http.ResponseWriter, err error) {
+	switch err {
+	case repo.ErrPaymentRequestNotFound:
+		http.Error(w, "not found", http.StatusNotFound)
+	case repo.ErrPaymentRequestSettled, repo.ErrPaymentRequestExpired:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
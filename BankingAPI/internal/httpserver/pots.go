@@ -0,0 +1,186 @@
+package httpservers
+
+import (
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/repo"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type createPotReq struct {
+	Name       string `json:"name"`
+	GoalAmount int64  `json:"goal_amount,omitempty"`
+	TargetDate string `json:"target_date,omitempty"` // YYYY-MM-DD
+}
+
+// potProgress is a pot with its progress towards the goal.
+type potProgress struct {
+	*model.Pot
+	ProgressPct float64 `json:"progress_pct,omitempty"`
+	Remaining   int64   `json:"remaining,omitempty"`
+	DaysLeft    *int    `json:"days_left,omitempty"`
+	// OnTrack compares the saved amount with a straight line from zero at
+	// creation to the goal at the target date.
+	OnTrack *bool `json:"on_track,omitempty"`
+}
+
+func progressOf(p *model.Pot, now time.Time) potProgress {
+	out := potProgress{Pot: p}
+	if p.GoalAmount <= 0 {
+		return out
+	}
+	out.ProgressPct = math.Round(float64(p.Balance)*10000/float64(p.GoalAmount)) / 100
+	if p.Balance < p.GoalAmount {
+		out.Remaining = p.GoalAmount - p.Balance
+	}
+	if p.TargetDate != nil {
+		days := int(math.Ceil(p.TargetDate.Sub(now).Hours() / 24))
+		if days < 0 {
+			days = 0
+		}
+		out.DaysLeft = &days
+		total := p.TargetDate.Sub(p.CreatedAt)
+		expected := p.GoalAmount
+		if total > 0 && now.Before(*p.TargetDate) {
+			expected = int64(float64(p.GoalAmount) * float64(now.Sub(p.CreatedAt)) / float64(total))
+		}
+		onTrack := p.Balance >= expected
+		out.OnTrack = &onTrack
+	}
+	return out
+}
+
+// @Summary Create pot
+// @Tags pots
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "account id"
+// @Param body body createPotReq true "pot"
+// @Produce json
+// @Success 201 {object} potProgress
+// @Failure 400 {string} string
+// @Router /accounts/{id}/pots [post]
+func (s *Server) createPot(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermManage, 0); !ok {
+		return
+	}
+	var req createPotReq
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.Name == "" || req.GoalAmount < 0 {
+		http.Error(w, "name required and goal_amount must not be negative", http.StatusBadRequest)
+		return
+	}
+	var target *time.Time
+	if req.TargetDate != "" {
+		t, err := time.Parse("2006-01-02", req.TargetDate)
+		if err != nil {
+			http.Error(w, "target_date must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		target = &t
+	}
+	p, err := s.repo.CreatePot(r.Context(), id, req.Name, req.GoalAmount, target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(progressOf(p, time.Now()))
+}
+
+// @Summary List pots
+// @Tags pots
+// @Security BearerAuth
+// @Param id path string true "account id"
+// @Produce json
+// @Success 200 {array} potProgress
+// @Router /accounts/{id}/pots [get]
+func (s *Server) listPots(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermView, 0); !ok {
+		return
+	}
+	pots, _ := s.repo.ListPots(r.Context(), id)
+	now := time.Now()
+	out := make([]potProgress, 0, len(pots))
+	for _, p := range pots {
+		out = append(out, progressOf(p, now))
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+// @Summary Move money into pot
+// @Tags pots
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "account id"
+// @Param pot_id path string true "pot id"
+// @Param body body amountReq true "amount"
+// @Produce json
+// @Success 200 {object} model.Transaction
+// @Failure 400 {string} string
+// @Router /accounts/{id}/pots/{pot_id}/deposit [post]
+func (s *Server) potDeposit(w http.ResponseWriter, r *http.Request) {
+	s.movePot(w, r, s.repo.MoveToPot)
+}
+
+// @Summary Move money out of pot
+// @Tags pots
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "account id"
+// @Param pot_id path string true "pot id"
+// @Param body body amountReq true "amount"
+// @Produce json
+// @Success 200 {object} model.Transaction
+// @Failure 400 {string} string
+// @Router /accounts/{id}/pots/{pot_id}/withdraw [post]
+func (s *Server) potWithdraw(w http.ResponseWriter, r *http.Request) {
+	s.movePot(w, r, s.repo.MoveFromPot)
+}
+
+func (s *Server) movePot(w http.ResponseWriter, r *http.Request, move func(ctx context.Context, accountID, potID string, amount int64) (*model.Pot, *model.Transaction, error)) {
+	vars := mux.Vars(r)
+	if _, ok := s.authorizeAccount(w, r, vars["id"], repo.PermInitiate, 0); !ok {
+		return
+	}
+	var req amountReq
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	_, t, err := move(r.Context(), vars["id"], vars["pot_id"], req.Amount)
+	if err != nil {
+		if err == repo.ErrPotNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(t)
+}
+
+// @Summary Delete pot
+// @Description Any money in the pot returns to the account's available balance.
+// @Tags pots
+// @Security BearerAuth
+// @Param id path string true "account id"
+// @Param pot_id path string true "pot id"
+// @Success 204 {string} string
+// @Failure 404 {string} string
+// @Router /accounts/{id}/pots/{pot_id} [delete]
+func (s *Server) deletePot(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if _, ok := s.authorizeAccount(w, r, vars["id"], repo.PermManage, 0); !ok {
+		return
+	}
+	if err := s.repo.DeletePot(r.Context(), vars["id"], vars["pot_id"]); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,107 @@
+package httpservers
+
+import (
+	"BankingAPI/internal/repo"
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// @Summary Export my data
+// @Description ZIP archive of the caller's profile, accounts, memberships, transactions (JSON and CSV), beneficiaries and audit events.
+// @Tags auth
+// @Security BearerAuth
+// @Produce application/zip
+// @Success 200 {file} file
+// @Router /auth/me/export [get]
+func (s *Server) exportMyData(w http.ResponseWriter, r *http.Request) {
+	exp, err := s.repo.ExportUserData(r.Context(), getUserID(r))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	now := time.Now().UTC()
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="export-%s.zip"`, now.Format("20060102")))
+	zw := zip.NewWriter(w)
+	create := func(name string) (io.Writer, error) {
+		return zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+	}
+	for _, f := range []struct {
+		name string
+		v    interface{}
+	}{
+		{"profile.json", exp.User},
+		{"accounts.json", exp.Accounts},
+		{"memberships.json", exp.Memberships},
+		{"transactions.json", exp.Transactions},
+		{"beneficiaries.json", exp.Beneficiaries},
+		{"audit_events.json", exp.AuditEvents},
+	} {
+		fw, err := create(f.name)
+		if err != nil {
+			return
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		enc.Encode(f.v)
+	}
+	fw, err := create("transactions.csv")
+	if err != nil {
+		return
+	}
+	currency := map[string]string{}
+	for _, a := range exp.Accounts {
+		currency[a.ID] = a.Currency
+	}
+	cw := csv.NewWriter(fw)
+	cw.Write([]string{"id", "account_id", "type", "amount", "currency", "created_at"})
+	for _, t := range exp.Transactions {
+		cw.Write([]string{t.ID, t.AccountID, string(t.Type), strconv.FormatInt(t.Amount, 10), currency[t.AccountID], t.CreatedAt.UTC().Format(time.RFC3339)})
+	}
+	cw.Flush()
+	zw.Close()
+}
+
+type eraseReq struct {
+	Password string `json:"password"`
+}
+
+// @Summary Erase my personal data
+// @Description Anonymises the caller's profile and frees their email. Financial records are kept under the user ID. Every account the caller owns must be closed or deleted first.
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Param body body eraseReq true "current password, to confirm"
+// @Success 204
+// @Failure 401 {string} string
+// @Failure 409 {string} string
+// @Router /auth/me/erase [post]
+func (s *Server) eraseMe(w http.ResponseWriter, r *http.Request) {
+	var req eraseReq
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	u, err := s.repo.GetUserByID(r.Context(), getUserID(r))
+	if err != nil || u.ErasedAt != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(req.Password)) != nil {
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	switch err := s.repo.EraseUser(r.Context(), u.ID); err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case repo.ErrErasureBlocked:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	}
+}
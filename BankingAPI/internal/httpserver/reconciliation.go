@@ -0,0 +1,218 @@
+package httpservers
+
+import (
+	"BankingAPI/internal/iso20022"
+	"BankingAPI/internal/repo"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxImportSize bounds an uploaded external statement.
+const maxImportSize = 10 << 20
+
+// defaultReconciliationDays is the period reported when none is given.
+const defaultReconciliationDays = 30
+
+func writeReconciliationError(w http.ResponseWriter, err error) {
+	switch err {
+	case repo.ErrNotFound, repo.ErrRecordNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case repo.ErrAlreadyMatched, repo.ErrNotMatched:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// @Summary Import external records
+// @Description Imports a settlement partner's statement (CSV with date, amount, currency, reference, description columns, or a camt.053 document) and auto-matches records to transactions by amount, date window and reference. Lines already imported are skipped.
+// @Tags reconciliation
+// @Security BearerAuth
+// @Accept plain
+// @Param id path string true "account id"
+// @Produce json
+// @Success 201 {object} repo.ImportResult
+// @Failure 400 {string} string
+// @Router /accounts/{id}/reconciliation/imports [post]
+func (s *Server) importExternalRecords(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermInitiate, 0); !ok {
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := s.repo.ImportExternalRecords(r.Context(), id, data, s.cfg.ReconciliationWindow)
+	if err != nil {
+		writeReconciliationError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(res)
+}
+
+// reconciliationPeriod reads from and to, defaulting to the last 30 days.
+func reconciliationPeriod(r *http.Request) (time.Time, time.Time, error) {
+	q := r.URL.Query()
+	to := time.Now()
+	if v := q.Get("to"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to")
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -defaultReconciliationDays)
+	if v := q.Get("from"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from")
+		}
+		from = t
+	}
+	return from, to, nil
+}
+
+// @Summary Reconciliation status
+// @Description Matched pairs and unmatched items on both sides for records and transactions dated in [from, to).
+// @Tags reconciliation
+// @Security BearerAuth
+// @Param id path string true "account id"
+// @Param from query string false "RFC 3339 time or YYYY-MM-DD (default 30 days before to)"
+// @Param to query string false "RFC 3339 time or YYYY-MM-DD (default now)"
+// @Produce json
+// @Success 200 {object} repo.ReconciliationReport
+// @Router /accounts/{id}/reconciliation [get]
+func (s *Server) reconciliation(w http.ResponseWriter, r *http.Request) {
+	rep, ok := s.loadReconciliation(w, r)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(rep)
+}
+
+func (s *Server) loadReconciliation(w http.ResponseWriter, r *http.Request) (*repo.ReconciliationReport, bool) {
+	id := mux.Vars(r)["id"]
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermView, 0); !ok {
+		return nil, false
+	}
+	from, to, err := reconciliationPeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	rep, err := s.repo.Reconciliation(r.Context(), id, from, to)
+	if err != nil {
+		writeReconciliationError(w, err)
+		return nil, false
+	}
+	return rep, true
+}
+
+type matchReq struct {
+	RecordID      string `json:"record_id"`
+	TransactionID string `json:"transaction_id"`
+}
+
+// @Summary Match record manually
+// @Tags reconciliation
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "account id"
+// @Param body body matchReq true "pair"
+// @Produce json
+// @Success 200 {object} model.ExternalRecord
+// @Failure 409 {string} string
+// @Router /accounts/{id}/reconciliation/matches [post]
+func (s *Server) matchRecord(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermInitiate, 0); !ok {
+		return
+	}
+	var req matchReq
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	rec, err := s.repo.MatchRecord(r.Context(), id, req.RecordID, req.TransactionID, getUserID(r))
+	if err != nil {
+		writeReconciliationError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(rec)
+}
+
+// @Summary Unmatch record
+// @Description Undoes a match. Auto-matching will not pair the two again.
+// @Tags reconciliation
+// @Security BearerAuth
+// @Param id path string true "account id"
+// @Param record_id path string true "external record id"
+// @Produce json
+// @Success 200 {object} model.ExternalRecord
+// @Failure 409 {string} string
+// @Router /accounts/{id}/reconciliation/matches/{record_id} [delete]
+func (s *Server) unmatchRecord(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if _, ok := s.authorizeAccount(w, r, vars["id"], repo.PermInitiate, 0); !ok {
+		return
+	}
+	rec, err := s.repo.UnmatchRecord(r.Context(), vars["id"], vars["record_id"], getUserID(r))
+	if err != nil {
+		writeReconciliationError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(rec)
+}
+
+// @Summary Export reconciliation report
+// @Description CSV with one row per matched pair, unmatched record and unmatched transaction.
+// @Tags reconciliation
+// @Security BearerAuth
+// @Param id path string true "account id"
+// @Param from query string false "RFC 3339 time or YYYY-MM-DD"
+// @Param to query string false "RFC 3339 time or YYYY-MM-DD"
+// @Produce text/csv
+// @Success 200 {string} string
+// @Router /accounts/{id}/reconciliation/report [get]
+func (s *Server) reconciliationReport(w http.ResponseWriter, r *http.Request) {
+	rep, ok := s.loadReconciliation(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="reconciliation-%s.csv"`, rep.AccountID))
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"status", "record_id", "record_date", "record_amount", "reference", "description", "transaction_id", "transaction_date", "transaction_amount", "matched_by"})
+	amount := func(v int64, ccy string) string {
+		if v < 0 {
+			return "-" + iso20022.FormatAmount(-v, ccy)
+		}
+		return iso20022.FormatAmount(v, ccy)
+	}
+	for _, p := range rep.Matched {
+		rec, t := p.Record, p.Transaction
+		cw.Write([]string{"matched", rec.ID, rec.Date.Format(time.RFC3339), amount(rec.Amount, rec.Currency), rec.Reference, rec.Description,
+			t.ID, t.CreatedAt.Format(time.RFC3339), amount(repo.BalanceDelta(t), rec.Currency), rec.MatchedBy})
+	}
+	for _, rec := range rep.UnmatchedRecords {
+		cw.Write([]string{"unmatched_record", rec.ID, rec.Date.Format(time.RFC3339), amount(rec.Amount, rec.Currency), rec.Reference, rec.Description, "", "", "", ""})
+	}
+	ccy := ""
+	if a, err := s.repo.GetAccount(r.Context(), rep.AccountID); err == nil {
+		ccy = a.Currency
+	}
+	for _, t := range rep.UnmatchedTransactions {
+		cw.Write([]string{"unmatched_transaction", "", "", "", "", "", t.ID, t.CreatedAt.Format(time.RFC3339), amount(repo.BalanceDelta(t), ccy), ""})
+	}
+	// totals of the unmatched items in the amount columns
+	cw.Write([]string{"summary", "", "", amount(rep.UnmatchedRecordTotal, ccy), "",
+		fmt.Sprintf("%d matched, %d unmatched records, %d unmatched transactions", len(rep.Matched), len(rep.UnmatchedRecords), len(rep.UnmatchedTransactions)),
+		"", "", amount(rep.UnmatchedTransactionTotal, ccy), ""})
+	cw.Flush()
+}
@@ -0,0 +1,48 @@
+package httpservers
+
+import (
+	"BankingAPI/internal/repo"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary List recently deleted accounts
+// @Description Deleted accounts the caller manages that can still be restored.
+// @Tags accounts
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} repo.DeletedAccount
+// @Router /accounts/deleted [get]
+func (s *Server) listDeletedAccounts(w http.ResponseWriter, r *http.Request) {
+	list, _ := s.repo.ListDeletedAccounts(r.Context(), getUserID(r), s.cfg.DeletedRetention)
+	json.NewEncoder(w).Encode(list)
+}
+
+// @Summary Restore deleted account
+// @Tags accounts
+// @Security BearerAuth
+// @Param id path string true "account id"
+// @Produce json
+// @Success 200 {object} model.Account
+// @Failure 409 {string} string
+// @Failure 410 {string} string
+// @Router /accounts/{id}/restore [post]
+func (s *Server) restoreAccount(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermManage, 0); !ok {
+		return
+	}
+	a, err := s.repo.RestoreAccount(r.Context(), id, getUserID(r), s.cfg.DeletedRetention)
+	switch err {
+	case nil:
+		json.NewEncoder(w).Encode(a)
+	case repo.ErrNotDeleted:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case repo.ErrRestoreExpired:
+		http.Error(w, err.Error(), http.StatusGone)
+	default:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	}
+}
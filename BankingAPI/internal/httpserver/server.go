@@ -2,12 +2,18 @@ package httpservers
 
 import (
 	"BankingAPI/internal/auth"
+	"BankingAPI/internal/config"
+	"BankingAPI/internal/iban"
+	"BankingAPI/internal/mailer"
 	"BankingAPI/internal/middleware"
 	"BankingAPI/internal/model"
 	"BankingAPI/internal/repo"
 	"BankingAPI/internal/storage"
+	"BankingAPI/internal/stream"
 	"context"
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -17,47 +23,277 @@ import (
 
 // Server ties repo and router
 type Server struct {
+	cfg    config.Config
 	repo   *repo.Repo
 	router *mux.Router
+	hub    *stream.Hub
+	stop   chan struct{}
+	// scopes maps routes to the scope an API key needs to use them.
+	scopes map[*mux.Route]model.Scope
 }
 
-// NewServer builds router, repo and handlers
-func NewServer() *Server {
+// NewServer builds router, repo and handlers and starts background jobs
+func NewServer(cfg config.Config) (*Server, error) {
 	store := storage.NewInMemoryStore()
 	r := repo.NewRepo(store)
-	s := &Server{repo: r}
+	hub := stream.NewHub(streamHistorySize, streamBufferSize)
+	r.SetNotifier(hub)
+	s := &Server{cfg: cfg, repo: r, hub: hub, stop: make(chan struct{}), scopes: map[*mux.Route]model.Scope{}}
+	for _, t := range cfg.Tenants {
+		r.PutTenant(&model.Tenant{
+			ID:          t.ID,
+			Name:        t.Name,
+			Hosts:       t.Hosts,
+			Currencies:  t.Currencies,
+			IBANCountry: t.IBANCountry,
+			BankCode:    t.BankCode,
+			AdminEmails: t.AdminEmails,
+		})
+		if err := setTenantKeys(t); err != nil {
+			return nil, fmt.Errorf("tenant %q: %w", t.ID, err)
+		}
+	}
 	mx := mux.NewRouter()
 	// global recover middleware
 	mx.Use(middleware.Recoverer)
+	mx.Use(middleware.Tenant(s.resolveTenant, cfg.DefaultTenant))
 
 	// auth handlers
-	authH := &auth.AuthHandler{Repo: r}
+	authH := &auth.AuthHandler{
+		Repo:            r,
+		AccessTTL:       cfg.AccessTokenTTL,
+		RefreshTTL:      cfg.RefreshTokenTTL,
+		MFAChallengeTTL: cfg.MFAChallengeTTL,
+		OAuthCodeTTL:    cfg.OAuthCodeTTL,
+
+		LoginPolicy: repo.LoginPolicy{
+			MaxFailures:   cfg.LoginMaxFailures,
+			IPMaxFailures: cfg.LoginIPMaxFailures,
+			Lockout:       cfg.LoginLockout,
+			MaxDelay:      cfg.LoginMaxDelay,
+		},
+
+		Mailer:                newMailer(cfg),
+		PublicURL:             cfg.PublicURL,
+		PasswordResetTTL:      cfg.PasswordResetTTL,
+		PasswordResetInterval: cfg.PasswordResetInterval,
+		EmailVerificationTTL:  cfg.EmailVerificationTTL,
+	}
 	mx.HandleFunc("/auth/register", authH.Register).Methods("POST")
 	mx.HandleFunc("/auth/login", authH.Login).Methods("POST")
+	mx.HandleFunc("/auth/login/mfa", authH.LoginMFA).Methods("POST")
+	mx.HandleFunc("/auth/refresh", authH.Refresh).Methods("POST")
+	mx.HandleFunc("/auth/password/forgot", authH.ForgotPassword).Methods("POST")
+	mx.HandleFunc("/auth/password/reset", authH.ResetPassword).Methods("POST")
+	mx.HandleFunc("/auth/email/verify", authH.VerifyEmail).Methods("POST")
+	mx.HandleFunc("/.well-known/jwks.json", authH.JWKS).Methods("GET")
+
+	// OAuth endpoints called by clients, which authenticate themselves
+	mx.HandleFunc("/oauth/token", authH.Token).Methods("POST")
+	mx.HandleFunc("/oauth/introspect", authH.Introspect).Methods("POST")
+	mx.HandleFunc("/oauth/revoke", authH.Revoke).Methods("POST")
+
+	// card network simulator; authenticates cards, not users
+	if cfg.CardSimulator {
+		mx.HandleFunc("/card-network/authorizations", s.authorizeCard).Methods("POST")
+		mx.HandleFunc("/card-network/authorizations/{id}/capture", s.captureAuthorization).Methods("POST")
+		mx.HandleFunc("/card-network/authorizations/{id}/release", s.releaseAuthorization).Methods("POST")
+	}
 
-	// protected routes
+	// staff endpoints; support may look up and unlock, admin may change
+	adm := mx.PathPrefix("/admin").Subrouter()
+	adm.Use(middleware.Auth(r.TokenRevoked, r.AuthenticateAPIKey), middleware.RequireRole(model.RoleSupport, model.RoleAdmin))
+	adminOnly := middleware.RequireRole(model.RoleAdmin)
+	adm.HandleFunc("/users", s.searchUsers).Methods("GET")
+	adm.HandleFunc("/users/{id}", s.getUserAdmin).Methods("GET")
+	adm.Handle("/users/{id}/deactivate", adminOnly(http.HandlerFunc(s.deactivateUser))).Methods("POST")
+	adm.Handle("/users/{id}/activate", adminOnly(http.HandlerFunc(s.activateUser))).Methods("POST")
+	adm.Handle("/users/{id}/roles", adminOnly(http.HandlerFunc(s.setUserRoles))).Methods("PUT")
+	adm.Handle("/accounts/{id}/freeze", adminOnly(http.HandlerFunc(s.adminFreezeAccount))).Methods("POST")
+	adm.Handle("/accounts/{id}/unfreeze", adminOnly(http.HandlerFunc(s.adminUnfreezeAccount))).Methods("POST")
+	adm.Handle("/accounts/{id}/adjustments", adminOnly(http.HandlerFunc(s.adjustBalance))).Methods("POST")
+	adm.Handle("/oauth/clients", adminOnly(http.HandlerFunc(authH.RegisterClient))).Methods("POST")
+	adm.Handle("/oauth/clients", adminOnly(http.HandlerFunc(authH.ListClients))).Methods("GET")
+	adm.Handle("/oauth/clients/{id}", adminOnly(http.HandlerFunc(authH.RevokeClient))).Methods("DELETE")
+	adm.HandleFunc("/lockouts", s.listLockouts).Methods("GET")
+	adm.HandleFunc("/users/{id}/unlock", s.unlockUser).Methods("POST")
+	adm.HandleFunc("/ips/{ip}/unlock", s.unlockIP).Methods("POST")
+
+	// protected routes; API keys and OAuth clients may only use the routes
+	// given a scope
 	pr := mx.PathPrefix("/").Subrouter()
-	pr.Use(middleware.Auth)
+	pr.Use(middleware.Auth(r.TokenRevoked, r.AuthenticateAPIKey), middleware.RequireScope(s.routeScope))
+	accountsRead := s.scope(model.ScopeAccountsRead)
+	accountsWrite := s.scope(model.ScopeAccountsWrite)
+	transfersRead := s.scope(model.ScopeTransfersRead)
+	transfersWrite := s.scope(model.ScopeTransfersWrite)
+	pr.HandleFunc("/auth/logout", authH.Logout).Methods("POST")
 	pr.HandleFunc("/auth/me", authH.Me).Methods("GET")
+	pr.HandleFunc("/auth/me/password", authH.ChangePassword).Methods("POST")
+	pr.HandleFunc("/auth/email/verification", authH.ResendVerification).Methods("POST")
+	pr.HandleFunc("/auth/mfa/totp", authH.EnrolTOTP).Methods("POST")
+	pr.HandleFunc("/auth/mfa/totp", authH.DisableTOTP).Methods("DELETE")
+	pr.HandleFunc("/auth/mfa/totp/confirm", authH.ConfirmTOTP).Methods("POST")
+	pr.HandleFunc("/auth/mfa/recovery-codes", authH.RegenerateRecoveryCodes).Methods("POST")
+	pr.HandleFunc("/auth/me/export", s.exportMyData).Methods("GET")
+	pr.HandleFunc("/auth/me/erase", s.eraseMe).Methods("POST")
+	pr.HandleFunc("/auth/api-keys", authH.CreateAPIKey).Methods("POST")
+	pr.HandleFunc("/auth/api-keys", authH.ListAPIKeys).Methods("GET")
+	pr.HandleFunc("/auth/api-keys/{id}", authH.RevokeAPIKey).Methods("DELETE")
+
+	// OAuth consent, given by the user in their own session
+	pr.HandleFunc("/oauth/authorize", authH.AuthorizeConsent).Methods("GET")
+	pr.HandleFunc("/oauth/authorize", authH.Authorize).Methods("POST")
+	pr.HandleFunc("/oauth/grants", authH.ListGrants).Methods("GET")
+	pr.HandleFunc("/oauth/grants/{client_id}", authH.RevokeGrant).Methods("DELETE")
 
 	// accounts
-	pr.HandleFunc("/accounts", s.createAccount).Methods("POST")
-	pr.HandleFunc("/accounts", s.listAccounts).Methods("GET")
-	pr.HandleFunc("/accounts/{id}", s.getAccount).Methods("GET")
-	pr.HandleFunc("/accounts/{id}", s.updateAccount).Methods("PUT")
-	pr.HandleFunc("/accounts/{id}", s.deleteAccount).Methods("DELETE")
-	pr.HandleFunc("/accounts/{id}/deposit", s.deposit).Methods("POST")
-	pr.HandleFunc("/accounts/{id}/withdraw", s.withdraw).Methods("POST")
+	accountsWrite(pr.HandleFunc("/accounts", s.createAccount).Methods("POST"))
+	accountsRead(pr.HandleFunc("/accounts", s.listAccounts).Methods("GET"))
+	accountsRead(pr.HandleFunc("/accounts/deleted", s.listDeletedAccounts).Methods("GET"))
+	accountsRead(pr.HandleFunc("/accounts/{id}", s.getAccount).Methods("GET"))
+	accountsWrite(pr.HandleFunc("/accounts/{id}", s.updateAccount).Methods("PUT"))
+	accountsWrite(pr.HandleFunc("/accounts/{id}", s.deleteAccount).Methods("DELETE"))
+	accountsWrite(pr.HandleFunc("/accounts/{id}/restore", s.restoreAccount).Methods("POST"))
+	accountsWrite(pr.HandleFunc("/accounts/{id}/deposit", s.deposit).Methods("POST"))
+	transfersWrite(pr.HandleFunc("/accounts/{id}/withdraw", s.verified(s.withdraw)).Methods("POST"))
+	accountsWrite(pr.HandleFunc("/accounts/{id}/status", s.setAccountStatus).Methods("POST"))
+	accountsWrite(pr.HandleFunc("/accounts/{id}/close", s.closeAccount).Methods("POST"))
+	accountsRead(pr.HandleFunc("/accounts/{id}/balance", s.accountBalance).Methods("GET"))
+	accountsRead(pr.HandleFunc("/accounts/{id}/balance-history", s.accountBalanceHistory).Methods("GET"))
+	accountsRead(pr.HandleFunc("/accounts/{id}/statements/camt053", s.camt053Statement).Methods("GET"))
+
+	// reconciliation against external statements
+	accountsRead(pr.HandleFunc("/accounts/{id}/reconciliation", s.reconciliation).Methods("GET"))
+	accountsWrite(pr.HandleFunc("/accounts/{id}/reconciliation/imports", s.importExternalRecords).Methods("POST"))
+	accountsWrite(pr.HandleFunc("/accounts/{id}/reconciliation/matches", s.matchRecord).Methods("POST"))
+	accountsWrite(pr.HandleFunc("/accounts/{id}/reconciliation/matches/{record_id}", s.unmatchRecord).Methods("DELETE"))
+	accountsRead(pr.HandleFunc("/accounts/{id}/reconciliation/report", s.reconciliationReport).Methods("GET"))
+
+	// ISO 20022 payment initiation
+	transfersWrite(pr.HandleFunc("/payments/pain001", s.verified(s.submitPain001)).Methods("POST"))
+
+	// spending insights
+	accountsRead(pr.HandleFunc("/insights", s.insights).Methods("GET"))
+	accountsWrite(pr.HandleFunc("/transactions/{id}/category", s.setTransactionCategory).Methods("PUT"))
+
+	// savings pots
+	accountsWrite(pr.HandleFunc("/accounts/{id}/pots", s.createPot).Methods("POST"))
+	accountsRead(pr.HandleFunc("/accounts/{id}/pots", s.listPots).Methods("GET"))
+	accountsWrite(pr.HandleFunc("/accounts/{id}/pots/{pot_id}", s.deletePot).Methods("DELETE"))
+	accountsWrite(pr.HandleFunc("/accounts/{id}/pots/{pot_id}/deposit", s.potDeposit).Methods("POST"))
+	accountsWrite(pr.HandleFunc("/accounts/{id}/pots/{pot_id}/withdraw", s.potWithdraw).Methods("POST"))
+
+	// cards
+	accountsWrite(pr.HandleFunc("/accounts/{id}/cards", s.verified(s.issueCard)).Methods("POST"))
+	accountsRead(pr.HandleFunc("/accounts/{id}/cards", s.listCards).Methods("GET"))
+	accountsWrite(pr.HandleFunc("/accounts/{id}/cards/{card_id}", s.updateCard).Methods("PATCH"))
+	accountsWrite(pr.HandleFunc("/accounts/{id}/cards/{card_id}", s.cancelCard).Methods("DELETE"))
+	accountsWrite(pr.HandleFunc("/accounts/{id}/cards/{card_id}/freeze", s.freezeCard).Methods("POST"))
+	accountsWrite(pr.HandleFunc("/accounts/{id}/cards/{card_id}/unfreeze", s.unfreezeCard).Methods("POST"))
+	accountsRead(pr.HandleFunc("/accounts/{id}/cards/{card_id}/authorizations", s.listCardAuthorizations).Methods("GET"))
+
+	// account members
+	pr.HandleFunc("/accounts/{id}/members", s.verified(s.inviteMember)).Methods("POST")
+	accountsRead(pr.HandleFunc("/accounts/{id}/members", s.listMembers).Methods("GET"))
+	pr.HandleFunc("/accounts/{id}/members/accept", s.acceptInvitation).Methods("POST")
+	pr.HandleFunc("/accounts/{id}/members/{user_id}", s.revokeMember).Methods("DELETE")
+	pr.HandleFunc("/invitations", s.listInvitations).Methods("GET")
+	accountsRead(pr.HandleFunc("/accounts/{id}/status-history", s.accountStatusHistory).Methods("GET"))
 
 	// transfers
-	pr.HandleFunc("/transfers", s.transfer).Methods("POST")
+	transfersWrite(pr.HandleFunc("/transfers", s.verified(s.transfer)).Methods("POST"))
+	transfersRead(pr.HandleFunc("/transfer-requests", s.listTransferRequests).Methods("GET"))
+	transfersRead(pr.HandleFunc("/transfer-requests/{id}", s.getTransferRequest).Methods("GET"))
+	transfersWrite(pr.HandleFunc("/transfer-requests/{id}/approve", s.verified(s.approveTransferRequest)).Methods("POST"))
+	transfersWrite(pr.HandleFunc("/transfer-requests/{id}/reject", s.rejectTransferRequest).Methods("POST"))
+
+	// payment requests and pay links
+	transfersWrite(pr.HandleFunc("/payment-requests", s.createPaymentRequest).Methods("POST"))
+	transfersRead(pr.HandleFunc("/payment-requests", s.listPaymentRequests).Methods("GET"))
+	transfersRead(pr.HandleFunc("/payment-requests/{id}", s.getPaymentRequest).Methods("GET"))
+	transfersWrite(pr.HandleFunc("/payment-requests/{id}/pay", s.verified(s.payPaymentRequest)).Methods("POST"))
+	transfersWrite(pr.HandleFunc("/payment-requests/{id}/decline", s.declinePaymentRequest).Methods("POST"))
+	transfersWrite(pr.HandleFunc("/payment-requests/{id}/cancel", s.cancelPaymentRequest).Methods("POST"))
+	transfersRead(pr.HandleFunc("/pay/{token}", s.getPayLink).Methods("GET"))
+	transfersWrite(pr.HandleFunc("/pay/{token}", s.verified(s.payPayLink)).Methods("POST"))
+
+	// beneficiaries
+	transfersWrite(pr.HandleFunc("/beneficiaries", s.createBeneficiary).Methods("POST"))
+	accountsRead(pr.HandleFunc("/beneficiaries", s.listBeneficiaries).Methods("GET"))
+	transfersWrite(pr.HandleFunc("/beneficiaries/{id}", s.deleteBeneficiary).Methods("DELETE"))
+
+	// live balance stream
+	accountsRead(pr.HandleFunc("/stream", s.streamSSE).Methods("GET"))
+	accountsRead(pr.HandleFunc("/stream/ws", s.streamWS).Methods("GET"))
 
 	// transactions listing
 	// pr.HandleFunc("/accounts/transactions", s.listTransactions).Methods("GET")
 
 	s.router = mx
 	s.repo = r
-	return s
+	go s.runJobs()
+	return s, nil
+}
+
+// setTenantKeys registers a tenant's configured JWT keys with auth.
+func setTenantKeys(t config.TenantConfig) error {
+	keys := make([]*auth.Key, 0, len(t.JWTKeys))
+	for _, kc := range t.JWTKeys {
+		k, err := auth.NewKey(kc.ID, kc.Alg, kc.Secret, kc.PrivateKey, kc.PublicKey)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, k)
+	}
+	return auth.SetTenantKeys(t.ID, keys, t.JWTSigningKey)
+}
+
+// scope returns a function that opens routes to API keys granted scope.
+func (s *Server) scope(scope model.Scope) func(*mux.Route) {
+	return func(rt *mux.Route) { s.scopes[rt] = scope }
+}
+
+// routeScope returns the scope an API key needs for the request's route.
+func (s *Server) routeScope(r *http.Request) (model.Scope, bool) {
+	scope, ok := s.scopes[mux.CurrentRoute(r)]
+	return scope, ok
+}
+
+// verified wraps handlers that move money out or extend access to others,
+// refusing users who have not verified their email address when
+// RequireVerifiedEmail is set.
+func (s *Server) verified(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.RequireVerifiedEmail {
+			u, err := s.repo.GetUserByID(r.Context(), getUserID(r))
+			if err != nil || u.EmailVerifiedAt == nil {
+				http.Error(w, "verify your email address first", http.StatusForbidden)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// mailQueueSize is how many emails may wait to be sent.
+const mailQueueSize = 256
+
+// newMailer builds the mail transport selected in cfg behind a queue.
+func newMailer(cfg config.Config) mailer.Mailer {
+	if cfg.Mailer == "log" {
+		log.Printf("BANKING_MAILER=log: emails, including reset links, are logged; do not use in production")
+		return mailer.NewQueue(&mailer.LogMailer{From: cfg.MailFrom, Dir: cfg.MailDir}, mailQueueSize)
+	}
+	return mailer.NewQueue(&mailer.SMTPMailer{Addr: cfg.SMTPAddr, From: cfg.MailFrom, Username: cfg.SMTPUsername, Password: cfg.SMTPPassword}, mailQueueSize)
+}
+
+func (s *Server) resolveTenant(host string) (string, bool) {
+	t, ok := s.repo.TenantByHost(host)
+	if !ok {
+		return "", false
+	}
+	return t.ID, true
 }
 
 func (s *Server) Router() http.Handler {
@@ -66,15 +302,70 @@ func (s *Server) Router() http.Handler {
 
 func (s *Server) Shutdown(ctx context.Context) error {
 	_ = ctx
+	close(s.stop)
 	return nil
 }
 
 func getUserID(r *http.Request) string { return r.Context().Value("user_id").(string) }
 
+// parseTimeParam parses a query parameter given either as RFC 3339 or as
+// a plain YYYY-MM-DD date (midnight UTC).
+func parseTimeParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+// reservedMetaKeys are the transaction meta keys the service writes
+// itself. Clients may not set them, or a deposit could pass for an admin
+// adjustment and a withdrawal for a transfer.
+var reservedMetaKeys = []string{
+	"adjustment", "admin_id", "reason",
+	"initiated_by", "counterparty_account_id", "beneficiary_id", "transfer_request_id",
+	"closed_account_id", "closing_balance", "swept_amount", "swept_to_account_id",
+	"card_id", "authorization_id", "pot_id", "pot_name",
+	"end_to_end_id", "pain001_msg_id", "pmt_inf_id", "creditor_name",
+}
+
+// clientMeta returns a copy of a request's meta without the reserved keys.
+func clientMeta(meta map[string]interface{}) map[string]interface{} {
+	if meta == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(meta))
+	for k, v := range meta {
+		out[k] = v
+	}
+	for _, k := range reservedMetaKeys {
+		delete(out, k)
+	}
+	return out
+}
+
+// authorizeAccount loads account id and checks the caller holds perm on it
+// (for amount, when initiating payments). On failure it writes the error
+// response and returns false.
+func (s *Server) authorizeAccount(w http.ResponseWriter, r *http.Request, id string, perm repo.Permission, amount int64) (*model.Account, bool) {
+	a, err := s.repo.Authorize(r.Context(), id, getUserID(r), perm, amount)
+	switch err {
+	case nil:
+		return a, true
+	case repo.ErrNotFound:
+		http.Error(w, "not found", http.StatusNotFound)
+	case repo.ErrLimitExceeded:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}
+	return nil, false
+}
+
 // createAccount
 type createAccountReq struct {
-	Name     string `json:"name"`
-	Currency string `json:"currency"`
+	Name     string            `json:"name"`
+	Currency string            `json:"currency"`
+	Kind     model.AccountKind `json:"kind,omitempty"`
 }
 
 // @Summary Create account
@@ -93,18 +384,31 @@ func (s *Server) createAccount(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "name and currency required", http.StatusBadRequest)
 		return
 	}
+	switch req.Kind {
+	case "":
+		req.Kind = model.AccountPersonal
+	case model.AccountPersonal, model.AccountBusiness:
+	default:
+		http.Error(w, "kind must be PERSONAL or BUSINESS", http.StatusBadRequest)
+		return
+	}
 	userID := getUserID(r)
 	acc := &model.Account{
 		UserID:    userID,
 		Name:      req.Name,
+		Kind:      req.Kind,
 		Currency:  req.Currency,
 		Balance:   0,
-		IsActive:  true,
+		Status:    model.AccountActive,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 	created, err := s.repo.CreateAccount(r.Context(), acc)
 	if err != nil {
+		if err == repo.ErrCurrencyNotAllowed {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -143,22 +447,15 @@ func (s *Server) listAccounts(w http.ResponseWriter, r *http.Request) {
 // @Failure 404 {string} string
 // @Router /accounts/{id} [get]
 func (s *Server) getAccount(w http.ResponseWriter, r *http.Request) {
-	id := mux.Vars(r)["id"]
-	a, err := s.repo.GetAccount(r.Context(), id)
-	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
-		return
-	}
-	if a.UserID != getUserID(r) {
-		http.Error(w, "forbidden", http.StatusForbidden)
+	a, ok := s.authorizeAccount(w, r, mux.Vars(r)["id"], repo.PermView, 0)
+	if !ok {
 		return
 	}
 	json.NewEncoder(w).Encode(a)
 }
 
 type updateAccountReq struct {
-	Name     *string `json:"name,omitempty"`
-	IsActive *bool   `json:"is_active,omitempty"`
+	Name *string `json:"name,omitempty"`
 }
 
 // @Summary Update account
@@ -171,43 +468,44 @@ type updateAccountReq struct {
 // @Router /accounts/{id} [put]
 func (s *Server) updateAccount(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
-	acc, err := s.repo.GetAccount(r.Context(), id)
-	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
-		return
-	}
-	if acc.UserID != getUserID(r) {
-		http.Error(w, "forbidden", http.StatusForbidden)
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermManage, 0); !ok {
 		return
 	}
 	var req updateAccountReq
 	_ = json.NewDecoder(r.Body).Decode(&req)
-	updated, err := s.repo.UpdateAccount(r.Context(), id, req.Name, req.IsActive)
+	updated, err := s.repo.UpdateAccount(r.Context(), id, req.Name)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 	json.NewEncoder(w).Encode(updated)
 }
 
 // @Summary Soft delete account
+// @Description Only accounts with a zero balance can be deleted; use /accounts/{id}/close to sweep funds.
 // @Tags accounts
 // @Security BearerAuth
 // @Param id path string true "account id"
 // @Success 204 {string} string
+// @Failure 409 {string} string
 // @Router /accounts/{id} [delete]
 func (s *Server) deleteAccount(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
-	a, err := s.repo.GetAccount(r.Context(), id)
-	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermManage, 0); !ok {
 		return
 	}
-	if a.UserID != getUserID(r) {
-		http.Error(w, "forbidden", http.StatusForbidden)
+	if err := s.repo.DeleteAccount(r.Context(), id, getUserID(r)); err != nil {
+		if err == repo.ErrBalanceNotZero {
+			http.Error(w, "account balance is not zero; close it with a sweep instead", http.StatusConflict)
+			return
+		}
+		if err == repo.ErrNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	_ = s.repo.DeleteAccount(r.Context(), id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -226,18 +524,13 @@ type amountReq struct {
 // @Router /accounts/{id}/deposit [post]
 func (s *Server) deposit(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
-	acc, err := s.repo.GetAccount(r.Context(), id)
-	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
-		return
-	}
-	if acc.UserID != getUserID(r) || !acc.IsActive {
-		http.Error(w, "forbidden or inactive", http.StatusForbidden)
-		return
-	}
 	var req amountReq
 	_ = json.NewDecoder(r.Body).Decode(&req)
-	t, err := s.repo.Deposit(r.Context(), id, req.Amount, req.Meta)
+	// an initiator's limit caps what leaves the account, not credits
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermInitiate, 0); !ok {
+		return
+	}
+	t, err := s.repo.Deposit(r.Context(), id, req.Amount, clientMeta(req.Meta))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -246,32 +539,32 @@ func (s *Server) deposit(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Withdraw
+// @Description Withdrawals from business accounts above the approval threshold are refused with 409; send them as a transfer so a second user can approve them.
 // @Tags accounts
 // @Security BearerAuth
 // @Param id path string true "account id"
 // @Param body body amountReq true "withdraw amount"
 // @Produce json
 // @Success 200 {object} model.Transaction
+// @Failure 409 {string} string
 // @Router /accounts/{id}/withdraw [post]
 func (s *Server) withdraw(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
-	acc, err := s.repo.GetAccount(r.Context(), id)
-	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
-		return
-	}
-	if acc.UserID != getUserID(r) || !acc.IsActive {
-		http.Error(w, "forbidden or inactive", http.StatusForbidden)
-		return
-	}
 	var req amountReq
 	_ = json.NewDecoder(r.Body).Decode(&req)
-	t, err := s.repo.Withdraw(r.Context(), id, req.Amount, req.Meta)
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermInitiate, req.Amount); !ok {
+		return
+	}
+	t, err := s.repo.Withdraw(r.Context(), id, req.Amount, s.cfg.ApprovalThreshold, clientMeta(req.Meta))
 	if err != nil {
 		if err == repo.ErrInsufficient {
 			http.Error(w, "insufficient funds", http.StatusBadRequest)
 			return
 		}
+		if err == repo.ErrApprovalRequired {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -280,42 +573,95 @@ func (s *Server) withdraw(w http.ResponseWriter, r *http.Request) {
 
 type transferReq struct {
 	FromAccountID string                 `json:"from_account_id"`
-	ToAccountID   string                 `json:"to_account_id"`
+	ToAccountID   string                 `json:"to_account_id,omitempty"`
+	ToIBAN        string                 `json:"to_iban,omitempty"`
+	ToAccountNo   string                 `json:"to_account_number,omitempty"`
+	BeneficiaryID string                 `json:"beneficiary_id,omitempty"`
 	Amount        int64                  `json:"amount"`
 	Meta          map[string]interface{} `json:"meta,omitempty"`
 }
 
 // @Summary Transfer
+// @Description The destination is one of to_account_id, to_iban, to_account_number or a saved beneficiary_id.
+// @Description Transfers from business accounts above the approval threshold return 202 with a pending transfer request.
+// @Description A destination saved as a beneficiary that is still cooling off only accepts up to its cooling_off_limit in total, whichever way it is addressed.
 // @Tags transfers
 // @Security BearerAuth
 // @Accept json
 // @Param body body transferReq true "transfer"
 // @Produce json
 // @Success 200 {object} map[string]model.Transaction
+// @Success 202 {object} model.TransferRequest
+// @Failure 400 {string} string
+// @Failure 403 {string} string
 // @Router /transfers [post]
 func (s *Server) transfer(w http.ResponseWriter, r *http.Request) {
 	var req transferReq
 	_ = json.NewDecoder(r.Body).Decode(&req)
-	from, err := s.repo.GetAccount(r.Context(), req.FromAccountID)
-	if err != nil || from.UserID != getUserID(r) {
+	req.Meta = clientMeta(req.Meta)
+	from, err := s.repo.Authorize(r.Context(), req.FromAccountID, getUserID(r), repo.PermInitiate, req.Amount)
+	if err != nil {
+		if err == repo.ErrLimitExceeded {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
 		http.Error(w, "invalid from account", http.StatusBadRequest)
 		return
 	}
-	if !from.IsActive {
-		http.Error(w, "from account inactive", http.StatusBadRequest)
-		return
+	switch {
+	case req.ToIBAN != "":
+		code := iban.Normalize(req.ToIBAN)
+		if err := iban.Validate(code); err != nil {
+			http.Error(w, "invalid IBAN: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		to, err := s.repo.FindAccountByIBAN(r.Context(), code)
+		if err != nil {
+			http.Error(w, "to account not found", http.StatusBadRequest)
+			return
+		}
+		req.ToAccountID = to.ID
+	case req.ToAccountNo != "":
+		if !iban.ValidAccountNumber(req.ToAccountNo) {
+			http.Error(w, "invalid account number check digits", http.StatusBadRequest)
+			return
+		}
+		to, err := s.repo.FindAccountByNumber(r.Context(), req.ToAccountNo)
+		if err != nil {
+			http.Error(w, "to account not found", http.StatusBadRequest)
+			return
+		}
+		req.ToAccountID = to.ID
+	case req.BeneficiaryID != "":
+		b, err := s.repo.GetBeneficiary(r.Context(), getUserID(r), req.BeneficiaryID)
+		if err != nil {
+			http.Error(w, "beneficiary not found", http.StatusBadRequest)
+			return
+		}
+		req.ToAccountID = b.AccountID
+		req.Meta = repo.WithMeta(req.Meta, "beneficiary_id", b.ID)
 	}
-	to, err := s.repo.GetAccount(r.Context(), req.ToAccountID)
-	if err != nil {
+	if _, err := s.repo.GetAccount(r.Context(), req.ToAccountID); err != nil {
 		http.Error(w, "to account not found", http.StatusBadRequest)
 		return
 	}
-	if !to.IsActive {
-		http.Error(w, "to account inactive", http.StatusBadRequest)
+	if repo.NeedsApproval(from, req.Amount, s.cfg.ApprovalThreshold) {
+		tr, err := s.repo.CreateTransferRequest(r.Context(), getUserID(r), req.FromAccountID, req.ToAccountID, req.Amount, req.Meta, s.cfg.ApprovalTTL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(tr)
 		return
 	}
-	out, in, err := s.repo.Transfer(r.Context(), req.FromAccountID, req.ToAccountID, req.Amount, req.Meta)
-	if err != nil {
+	out, in, err := s.repo.Transfer(r.Context(), getUserID(r), req.FromAccountID, req.ToAccountID, req.Amount, req.Meta)
+	switch err {
+	case nil:
+	case repo.ErrCoolingOffLimit:
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	default:
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -0,0 +1,80 @@
+package httpservers
+
+import (
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/repo"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type setStatusReq struct {
+	Status model.AccountStatus `json:"status"`
+	Reason string              `json:"reason"`
+}
+
+// @Summary Change account status
+// @Description Owners may freeze an active account, unfreeze it, or reactivate a dormant one. Closing goes through /accounts/{id}/close. A freeze imposed by the bank can only be lifted by an admin.
+// @Tags accounts
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "account id"
+// @Param body body setStatusReq true "new status and reason"
+// @Produce json
+// @Success 200 {object} model.Account
+// @Failure 400 {string} string
+// @Failure 409 {string} string
+// @Router /accounts/{id}/status [post]
+func (s *Server) setAccountStatus(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermManage, 0); !ok {
+		return
+	}
+	var req setStatusReq
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.Status == "" || req.Reason == "" {
+		http.Error(w, "status and reason required", http.StatusBadRequest)
+		return
+	}
+	if req.Status == model.AccountDormant {
+		http.Error(w, "accounts become dormant through inactivity only", http.StatusBadRequest)
+		return
+	}
+	if req.Status == model.AccountClosed {
+		http.Error(w, "use POST /accounts/{id}/close to close an account", http.StatusBadRequest)
+		return
+	}
+	updated, err := s.repo.SetAccountStatus(r.Context(), id, req.Status, getUserID(r), req.Reason)
+	if err != nil {
+		if err == repo.ErrInvalidStatus || err == repo.ErrBalanceNotZero || err == repo.ErrAdminFrozen {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(updated)
+}
+
+// @Summary Account status history
+// @Tags accounts
+// @Security BearerAuth
+// @Param id path string true "account id"
+// @Produce json
+// @Success 200 {array} model.AuditEvent
+// @Router /accounts/{id}/status-history [get]
+func (s *Server) accountStatusHistory(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, ok := s.authorizeAccount(w, r, id, repo.PermView, 0); !ok {
+		return
+	}
+	events, _ := s.repo.ListAccountAudit(r.Context(), id)
+	out := []*model.AuditEvent{}
+	for _, e := range events {
+		if e.Action == "account.status_changed" {
+			out = append(out, e)
+		}
+	}
+	json.NewEncoder(w).Encode(out)
+}
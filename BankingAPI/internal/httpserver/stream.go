@@ -0,0 +1,151 @@
+package httpservers
+
+import (
+	"BankingAPI/internal/stream"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	heartbeatInterval  = 15 * time.Second
+	streamWriteTimeout = 10 * time.Second
+	streamHistorySize  = 1024
+	streamBufferSize   = 64
+)
+
+// lastEventID reads the resume position from the Last-Event-ID header
+// (sent automatically by EventSource on reconnect) or the last_event_id
+// query parameter.
+func lastEventID(r *http.Request) uint64 {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("last_event_id")
+	}
+	id, _ := strconv.ParseUint(v, 10, 64)
+	return id
+}
+
+// @Summary Stream balance and transaction events (SSE)
+// @Description Pushes "balance" and "transaction" events for the caller's accounts. Send Last-Event-ID to resume; a "reset" event means missed events are no longer retained and balances must be refetched.
+// @Tags stream
+// @Security BearerAuth
+// @Param Last-Event-ID header string false "resume after this event id"
+// @Produce text/event-stream
+// @Success 200 {string} string
+// @Router /stream [get]
+func (s *Server) streamSSE(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	sub, replay := s.hub.Subscribe(getUserID(r), lastEventID(r))
+	defer s.hub.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(payload string) bool {
+		_ = rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := fmt.Fprint(w, payload); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	writeEvent := func(ev stream.Event) bool {
+		data, _ := json.Marshal(ev.Data)
+		return write(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data))
+	}
+
+	if !write("retry: 3000\n\n") {
+		return
+	}
+	for _, ev := range replay {
+		if !writeEvent(ev) {
+			return
+		}
+	}
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if !write(": heartbeat\n\n") {
+				return
+			}
+		case ev, ok := <-sub.C:
+			if !ok {
+				// dropped for falling behind; the client reconnects with Last-Event-ID
+				return
+			}
+			if !writeEvent(ev) {
+				return
+			}
+		}
+	}
+}
+
+// @Summary Stream balance and transaction events (WebSocket)
+// @Description WebSocket equivalent of /stream. Each message is a JSON event; heartbeats have type "heartbeat" and a "reset" event means balances must be refetched.
+// @Tags stream
+// @Security BearerAuth
+// @Param last_event_id query int false "resume after this event id"
+// @Success 101 {string} string
+// @Router /stream/ws [get]
+func (s *Server) streamWS(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	after := lastEventID(r)
+	websocket.Server{
+		// the request is already authenticated by middleware.Auth, so
+		// non-browser clients without an Origin header are allowed
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			sub, replay := s.hub.Subscribe(userID, after)
+			defer s.hub.Unsubscribe(sub)
+
+			// the reader only detects the client going away
+			closed := make(chan struct{})
+			go func() {
+				defer close(closed)
+				var discard string
+				for websocket.Message.Receive(ws, &discard) == nil {
+				}
+			}()
+
+			send := func(ev stream.Event) bool {
+				_ = ws.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+				return websocket.JSON.Send(ws, ev) == nil
+			}
+			for _, ev := range replay {
+				if !send(ev) {
+					return
+				}
+			}
+			ticker := time.NewTicker(heartbeatInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-closed:
+					return
+				case <-ticker.C:
+					if !send(stream.Event{Type: "heartbeat", CreatedAt: time.Now()}) {
+						return
+					}
+				case ev, ok := <-sub.C:
+					if !ok {
+						return
+					}
+					if !send(ev) {
+						return
+					}
+				}
+			}
+		},
+	}.ServeHTTP(w, r)
+}
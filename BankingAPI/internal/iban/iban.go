@@ -0,0 +1,98 @@
+// Package iban implements ISO 13616 IBANs and account number check digits (ISO 7064 mod 97-10).
+package iban
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrFormat   = errors.New("malformed IBAN")
+	ErrChecksum = errors.New("IBAN check digits do not match")
+)
+
+// mod97 returns the remainder of the decimal number formed by s, with
+// letters expanded to two digits (A=10 ... Z=35), divided by 97.
+func mod97(s string) (int, error) {
+	rem := 0
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			rem = (rem*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			v := int(c-'A') + 10
+			rem = (rem*100 + v) % 97
+		default:
+			return 0, ErrFormat
+		}
+	}
+	return rem, nil
+}
+
+// Normalize strips spaces and upper-cases an IBAN as typed by a person.
+func Normalize(s string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(s), ""))
+}
+
+// Generate builds the IBAN for a two-letter country code and a BBAN
+// (bank code followed by account number).
+func Generate(country, bban string) (string, error) {
+	country = strings.ToUpper(country)
+	bban = Normalize(bban)
+	if len(country) != 2 || bban == "" {
+		return "", ErrFormat
+	}
+	rem, err := mod97(bban + country + "00")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%02d%s", country, 98-rem, bban), nil
+}
+
+// Validate checks the structure and mod-97 check digits of an IBAN in
+// electronic (normalized) form.
+func Validate(s string) error {
+	if len(s) < 15 || len(s) > 34 {
+		return ErrFormat
+	}
+	for _, c := range s[:2] {
+		if c < 'A' || c > 'Z' {
+			return ErrFormat
+		}
+	}
+	for _, c := range s[2:4] {
+		if c < '0' || c > '9' {
+			return ErrFormat
+		}
+	}
+	rem, err := mod97(s[4:] + s[:4])
+	if err != nil {
+		return err
+	}
+	if rem != 1 {
+		return ErrChecksum
+	}
+	return nil
+}
+
+// AccountNumber appends two mod-97 check digits to a numeric base, so a
+// single mistyped or swapped digit is detected.
+func AccountNumber(base string) string {
+	rem, _ := mod97(base + "00")
+	return fmt.Sprintf("%s%02d", base, 98-rem)
+}
+
+// ValidAccountNumber reports whether n ends in the check digits of the
+// rest of the number.
+func ValidAccountNumber(n string) bool {
+	if len(n) < 3 {
+		return false
+	}
+	for _, c := range n {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return AccountNumber(n[:len(n)-2]) == n
+}
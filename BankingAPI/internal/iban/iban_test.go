@@ -0,0 +1,92 @@
+package iban
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		iban string
+		want error
+	}{
+		{"GB", "GB82WEST12345698765432", nil},
+		{"DE", "DE89370400440532013000", nil},
+		{"NL", "NL91ABNA0417164300", nil},
+		{"wrong check digits", "GB83WEST12345698765432", ErrChecksum},
+		{"mistyped digit", "GB82WEST12345698765433", ErrChecksum},
+		{"swapped digits", "GB82WEST12345698765423", ErrChecksum},
+		{"too short", "GB82WEST1234", ErrFormat},
+		{"too long", "GB82WEST123456987654321234567890123", ErrFormat},
+		{"lower-case country", "gb82WEST12345698765432", ErrFormat},
+		{"letters as check digits", "GBAAWEST12345698765432", ErrFormat},
+		{"not normalized", "GB82 WEST 1234 5698 7654 32", ErrFormat},
+		{"symbol in BBAN", "GB82WEST1234569876543!", ErrFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.iban); !errors.Is(err, tt.want) {
+				t.Errorf("Validate(%q) = %v, want %v", tt.iban, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	got, err := Generate("gb", "WEST 1234 5698 7654 32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "GB82WEST12345698765432" {
+		t.Errorf("Generate = %q, want GB82WEST12345698765432", got)
+	}
+	if err := Validate(got); err != nil {
+		t.Errorf("Validate(Generate(...)) = %v", err)
+	}
+}
+
+func TestAccountNumber(t *testing.T) {
+	tests := []struct {
+		base string
+		want string
+	}{
+		{"12345678", "1234567889"},
+		{"0000000001", "000000000195"},
+		{"987654321", "98765432108"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.base, func(t *testing.T) {
+			got := AccountNumber(tt.base)
+			if got != tt.want {
+				t.Fatalf("AccountNumber(%q) = %q, want %q", tt.base, got, tt.want)
+			}
+			if !ValidAccountNumber(got) {
+				t.Errorf("ValidAccountNumber(%q) = false", got)
+			}
+		})
+	}
+}
+
+func TestValidAccountNumber(t *testing.T) {
+	tests := []struct {
+		name string
+		n    string
+		want bool
+	}{
+		{"valid", "1234567889", true},
+		{"mistyped digit", "1234577889", false},
+		{"swapped digits", "2134567889", false},
+		{"wrong check digits", "1234567890", false},
+		{"non-numeric", "12345A7889", false},
+		{"too short", "12", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidAccountNumber(tt.n); got != tt.want {
+				t.Errorf("ValidAccountNumber(%q) = %v, want %v", tt.n, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// Package insights categorises transactions and aggregates them into
+// spending reports.
+package insights
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Categories a transaction can be assigned to.
+const (
+	Groceries     = "groceries"
+	Dining        = "dining"
+	Transport     = "transport"
+	Travel        = "travel"
+	Shopping      = "shopping"
+	Utilities     = "utilities"
+	Entertainment = "entertainment"
+	Health        = "health"
+	Cash          = "cash"
+	Fees          = "fees"
+	Income        = "income"
+	Transfers     = "transfers"
+	Other         = "other"
+)
+
+// Categories lists every valid category.
+var Categories = []string{Groceries, Dining, Transport, Travel, Shopping, Utilities, Entertainment, Health, Cash, Fees, Income, Transfers, Other}
+
+// Valid reports whether c is a known category.
+func Valid(c string) bool {
+	for _, v := range Categories {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
+
+// mccRange maps an inclusive range of ISO 18245 merchant category codes.
+type mccRange struct {
+	from, to int
+	category string
+}
+
+var mccRules = []mccRange{
+	{3000, 3350, Travel}, // airlines
+	{3351, 3500, Travel}, // car rental
+	{3501, 3999, Travel}, // hotels
+	{4000, 4299, Transport},
+	{4411, 4411, Travel},
+	{4511, 4511, Travel},
+	{4722, 4722, Travel},
+	{4784, 4789, Transport},
+	{4812, 4816, Utilities},
+	{4899, 4900, Utilities},
+	{5300, 5300, Shopping},
+	{5411, 5411, Groceries},
+	{5412, 5499, Groceries},
+	{5541, 5542, Transport},
+	{5811, 5814, Dining},
+	{5912, 5912, Health},
+	{6010, 6011, Cash},
+	{7832, 7841, Entertainment},
+	{7911, 7999, Entertainment},
+	{8011, 8099, Health},
+	{5000, 5999, Shopping},
+}
+
+// keywordRules are matched as substrings of the lower-cased merchant name,
+// then of the description, in order.
+var keywordRules = []struct {
+	keyword, category string
+}{
+	{"supermarket", Groceries},
+	{"grocer", Groceries},
+	{"aldi", Groceries},
+	{"lidl", Groceries},
+	{"tesco", Groceries},
+	{"rewe", Groceries},
+	{"restaurant", Dining},
+	{"cafe", Dining},
+	{"coffee", Dining},
+	{"pizza", Dining},
+	{"uber eats", Dining},
+	{"uber", Transport},
+	{"taxi", Transport},
+	{"railway", Transport},
+	{"fuel", Transport},
+	{"parking", Transport},
+	{"airline", Travel},
+	{"hotel", Travel},
+	{"airbnb", Travel},
+	{"amazon", Shopping},
+	{"electric", Utilities},
+	{"water", Utilities},
+	{"internet", Utilities},
+	{"mobile", Utilities},
+	{"rent", Utilities},
+	{"netflix", Entertainment},
+	{"spotify", Entertainment},
+	{"cinema", Entertainment},
+	{"pharmacy", Health},
+	{"doctor", Health},
+	{"dentist", Health},
+	{"atm", Cash},
+	{"fee", Fees},
+	{"salary", Income},
+	{"payroll", Income},
+}
+
+// Categorize picks a category from a transaction's metadata using the
+// "mcc", "merchant" and "description" keys. ok is false when no rule
+// matched.
+func Categorize(meta map[string]interface{}) (category string, ok bool) {
+	if mcc, found := mccOf(meta); found {
+		for _, rule := range mccRules {
+			if mcc >= rule.from && mcc <= rule.to {
+				return rule.category, true
+			}
+		}
+	}
+	for _, field := range []string{"merchant", "description"} {
+		text := strings.ToLower(stringOf(meta, field))
+		if text == "" {
+			continue
+		}
+		for _, rule := range keywordRules {
+			if strings.Contains(text, rule.keyword) {
+				return rule.category, true
+			}
+		}
+	}
+	return "", false
+}
+
+// MerchantKey returns the normalised merchant name used to remember a
+// user's category override, or "" if the transaction has no merchant.
+func MerchantKey(meta map[string]interface{}) string {
+	return strings.Join(strings.Fields(strings.ToLower(stringOf(meta, "merchant"))), " ")
+}
+
+func stringOf(meta map[string]interface{}, key string) string {
+	switch v := meta[key].(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func mccOf(meta map[string]interface{}) (int, bool) {
+	switch v := meta["mcc"].(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		return n, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,162 @@
+package insights
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Entry is a transaction as seen by the report: a signed amount (positive
+// for money in, negative for money out) with its resolved category.
+type Entry struct {
+	Amount       int64
+	Category     string
+	Counterparty string
+	// Internal marks moves between the user's own accounts, which are
+	// not spending and are left out of the report.
+	Internal bool
+	At       time.Time
+}
+
+// MonthSpend is the outflow of one calendar month per category.
+type MonthSpend struct {
+	Month      string           `json:"month"` // YYYY-MM
+	Categories map[string]int64 `json:"categories"`
+	Total      int64            `json:"total"`
+}
+
+// CategoryTotal is the outflow of a category in the period and the one
+// before it.
+type CategoryTotal struct {
+	Category  string   `json:"category"`
+	Amount    int64    `json:"amount"`
+	Previous  int64    `json:"previous"`
+	ChangePct *float64 `json:"change_pct,omitempty"`
+}
+
+// Counterparty is the outflow to one merchant or payee.
+type Counterparty struct {
+	Name   string `json:"name"`
+	Amount int64  `json:"amount"`
+	Count  int    `json:"count"`
+}
+
+// Report summarises spending between From (inclusive) and To (exclusive).
+// The previous period has the same length and ends at From.
+type Report struct {
+	Currency          string          `json:"currency"`
+	From              time.Time       `json:"from"`
+	To                time.Time       `json:"to"`
+	Income            int64           `json:"income"`
+	Outflow           int64           `json:"outflow"`
+	Net               int64           `json:"net"`
+	PreviousIncome    int64           `json:"previous_income"`
+	PreviousOutflow   int64           `json:"previous_outflow"`
+	IncomeChangePct   *float64        `json:"income_change_pct,omitempty"`
+	OutflowChangePct  *float64        `json:"outflow_change_pct,omitempty"`
+	Months            []MonthSpend    `json:"months"`
+	Categories        []CategoryTotal `json:"categories"`
+	TopCounterparties []Counterparty  `json:"top_counterparties"`
+}
+
+// Build aggregates entries into a report. Entries outside both the
+// period and the previous period are ignored; top limits the number of
+// counterparties returned.
+func Build(currency string, entries []Entry, from, to time.Time, top int) *Report {
+	prevFrom := from.Add(-to.Sub(from))
+	rep := &Report{Currency: currency, From: from, To: to, Months: []MonthSpend{}, Categories: []CategoryTotal{}, TopCounterparties: []Counterparty{}}
+	current := map[string]int64{}
+	previous := map[string]int64{}
+	months := map[string]*MonthSpend{}
+	parties := map[string]*Counterparty{}
+
+	for _, e := range entries {
+		inPeriod := !e.At.Before(from) && e.At.Before(to)
+		inPrevious := !e.At.Before(prevFrom) && e.At.Before(from)
+		if (!inPeriod && !inPrevious) || e.Internal {
+			continue
+		}
+		if e.Amount > 0 {
+			if inPeriod {
+				rep.Income += e.Amount
+			} else {
+				rep.PreviousIncome += e.Amount
+			}
+			continue
+		}
+		out := -e.Amount
+		if !inPeriod {
+			previous[e.Category] += out
+			rep.PreviousOutflow += out
+			continue
+		}
+		current[e.Category] += out
+		key := e.At.Format("2006-01")
+		m := months[key]
+		if m == nil {
+			m = &MonthSpend{Month: key, Categories: map[string]int64{}}
+			months[key] = m
+		}
+		m.Categories[e.Category] += out
+		m.Total += out
+		rep.Outflow += out
+		if e.Counterparty != "" {
+			// group spelling variants of the same merchant
+			key := strings.Join(strings.Fields(strings.ToLower(e.Counterparty)), " ")
+			p := parties[key]
+			if p == nil {
+				p = &Counterparty{Name: e.Counterparty}
+				parties[key] = p
+			}
+			p.Amount += out
+			p.Count++
+		}
+	}
+	rep.Net = rep.Income - rep.Outflow
+	rep.IncomeChangePct = changePct(rep.PreviousIncome, rep.Income)
+	rep.OutflowChangePct = changePct(rep.PreviousOutflow, rep.Outflow)
+
+	for _, m := range months {
+		rep.Months = append(rep.Months, *m)
+	}
+	sort.Slice(rep.Months, func(i, j int) bool { return rep.Months[i].Month < rep.Months[j].Month })
+
+	for _, c := range Categories {
+		if current[c] == 0 && previous[c] == 0 {
+			continue
+		}
+		rep.Categories = append(rep.Categories, CategoryTotal{
+			Category:  c,
+			Amount:    current[c],
+			Previous:  previous[c],
+			ChangePct: changePct(previous[c], current[c]),
+		})
+	}
+	sort.SliceStable(rep.Categories, func(i, j int) bool { return rep.Categories[i].Amount > rep.Categories[j].Amount })
+
+	for _, p := range parties {
+		rep.TopCounterparties = append(rep.TopCounterparties, *p)
+	}
+	sort.Slice(rep.TopCounterparties, func(i, j int) bool {
+		a, b := rep.TopCounterparties[i], rep.TopCounterparties[j]
+		if a.Amount != b.Amount {
+			return a.Amount > b.Amount
+		}
+		return a.Name < b.Name
+	})
+	if top >= 0 && len(rep.TopCounterparties) > top {
+		rep.TopCounterparties = rep.TopCounterparties[:top]
+	}
+	return rep
+}
+
+// changePct is the percentage change from prev to cur, rounded to two
+// decimals, or nil when there is nothing to compare against.
+func changePct(prev, cur int64) *float64 {
+	if prev == 0 {
+		return nil
+	}
+	v := math.Round(float64(cur-prev)*10000/float64(prev)) / 100
+	return &v
+}
@@ -0,0 +1,72 @@
+// Package iso20022 reads and writes the ISO 20022 XML messages exchanged
+// with corporate customers: camt.053 statements, pain.001 credit transfer
+// initiations and pain.002 payment status reports.
+//
+// Messages are checked against the rules of their schemas (required
+// elements, lengths, code lists, amount formats and totals) in Go; no XSD
+// engine is involved.
+package iso20022
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrAmount = errors.New("invalid amount")
+
+// exponents lists ISO 4217 currencies that do not use two decimals.
+var exponents = map[string]int{
+	"JPY": 0, "KRW": 0, "ISK": 0, "CLP": 0, "VND": 0, "XAF": 0, "XOF": 0,
+	"BHD": 3, "KWD": 3, "OMR": 3, "JOD": 3, "TND": 3,
+}
+
+// Exponent returns the number of minor-unit digits of a currency.
+func Exponent(ccy string) int {
+	if e, ok := exponents[ccy]; ok {
+		return e
+	}
+	return 2
+}
+
+// FormatAmount renders a non-negative amount in minor units as an ISO
+// 20022 decimal, e.g. 1050 EUR as "10.50".
+func FormatAmount(minor int64, ccy string) string {
+	exp := Exponent(ccy)
+	if exp == 0 {
+		return strconv.FormatInt(minor, 10)
+	}
+	div := pow10(exp)
+	return fmt.Sprintf("%d.%0*d", minor/div, exp, minor%div)
+}
+
+// ParseAmount parses a decimal amount into minor units of ccy. It rejects
+// negative values and more fraction digits than the currency has.
+func ParseAmount(s, ccy string) (int64, error) {
+	return parseDecimal(s, Exponent(ccy))
+}
+
+// parseDecimal parses a non-negative decimal into units of 10^-exp.
+func parseDecimal(s string, exp int) (int64, error) {
+	s = strings.TrimSpace(s)
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" || len(frac) > exp || len(whole) > 18-exp {
+		return 0, ErrAmount
+	}
+	for _, c := range whole + frac {
+		if c < '0' || c > '9' {
+			return 0, ErrAmount
+		}
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+	return strconv.ParseInt(whole+frac, 10, 64)
+}
+
+func pow10(n int) int64 {
+	v := int64(1)
+	for i := 0; i < n; i++ {
+		v *= 10
+	}
+	return v
+}
@@ -0,0 +1,268 @@
+package iso20022
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.08"
+
+// Statement is the content of a camt.053 bank-to-customer statement for
+// one account and period.
+type Statement struct {
+	MessageID string
+	ID        string
+	IBAN      string
+	Currency  string
+	OwnerName string
+	From, To  time.Time
+	Opening   int64 // minor units, may be negative
+	Closing   int64
+	Entries   []StatementEntry
+}
+
+// StatementEntry is one booked transaction. Amount is signed: positive
+// for credits, negative for debits.
+type StatementEntry struct {
+	Reference  string
+	Amount     int64
+	BookedAt   time.Time
+	Code       string // proprietary bank transaction code
+	EndToEndID string
+	Info       string
+}
+
+type camtDocument struct {
+	XMLName xml.Name     `xml:"Document"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Stmt    camtBkToCstm `xml:"BkToCstmrStmt"`
+}
+
+type camtBkToCstm struct {
+	GrpHdr camtGrpHdr `xml:"GrpHdr"`
+	Stmt   camtStmt   `xml:"Stmt"`
+}
+
+type camtGrpHdr struct {
+	MsgId   string `xml:"MsgId"`
+	CreDtTm string `xml:"CreDtTm"`
+}
+
+type camtStmt struct {
+	Id        string        `xml:"Id"`
+	CreDtTm   string        `xml:"CreDtTm"`
+	FrToDt    camtFrToDt    `xml:"FrToDt"`
+	Acct      camtAcct      `xml:"Acct"`
+	Bal       []camtBal     `xml:"Bal"`
+	TxsSummry camtTxsSummry `xml:"TxsSummry"`
+	Ntry      []camtNtry    `xml:"Ntry"`
+}
+
+type camtFrToDt struct {
+	FrDtTm string `xml:"FrDtTm"`
+	ToDtTm string `xml:"ToDtTm"`
+}
+
+type camtAcct struct {
+	IBAN string    `xml:"Id>IBAN"`
+	Ccy  string    `xml:"Ccy"`
+	Ownr *camtName `xml:"Ownr,omitempty"`
+}
+
+type camtName struct {
+	Nm string `xml:"Nm"`
+}
+
+type camtAmt struct {
+	Ccy   string `xml:"Ccy,attr"`
+	Value string `xml:",chardata"`
+}
+
+type camtBal struct {
+	Cd        string  `xml:"Tp>CdOrPrtry>Cd"`
+	Amt       camtAmt `xml:"Amt"`
+	CdtDbtInd string  `xml:"CdtDbtInd"`
+	Dt        string  `xml:"Dt>Dt"`
+}
+
+type camtTxsSummry struct {
+	NbOfNtries    int    `xml:"TtlNtries>NbOfNtries"`
+	Sum           string `xml:"TtlNtries>Sum"`
+	TtlNetAmt     string `xml:"TtlNtries>TtlNetNtry>Amt"`
+	TtlNetInd     string `xml:"TtlNtries>TtlNetNtry>CdtDbtInd"`
+	CdtNbOfNtries int    `xml:"TtlCdtNtries>NbOfNtries"`
+	CdtSum        string `xml:"TtlCdtNtries>Sum"`
+	DbtNbOfNtries int    `xml:"TtlDbtNtries>NbOfNtries"`
+	DbtSum        string `xml:"TtlDbtNtries>Sum"`
+}
+
+type camtNtry struct {
+	NtryRef     string     `xml:"NtryRef"`
+	Amt         camtAmt    `xml:"Amt"`
+	CdtDbtInd   string     `xml:"CdtDbtInd"`
+	Sts         string     `xml:"Sts>Cd"`
+	BookgDt     string     `xml:"BookgDt>DtTm"`
+	BookgDtDt   string     `xml:"BookgDt>Dt,omitempty"`
+	ValDt       string     `xml:"ValDt>Dt"`
+	AcctSvcrRef string     `xml:"AcctSvcrRef"`
+	BkTxCd      string     `xml:"BkTxCd>Prtry>Cd"`
+	TxDtls      camtTxDtls `xml:"NtryDtls>TxDtls"`
+}
+
+type camtTxDtls struct {
+	EndToEndId string `xml:"Refs>EndToEndId"`
+	Ustrd      string `xml:"RmtInf>Ustrd,omitempty"`
+}
+
+// indicator splits a signed amount into its absolute value and the
+// ISO 20022 credit/debit indicator.
+func indicator(amount int64) (int64, string) {
+	if amount < 0 {
+		return -amount, "DBIT"
+	}
+	return amount, "CRDT"
+}
+
+// MarshalCamt053 renders s as a camt.053.001.08 document.
+func MarshalCamt053(s *Statement, now time.Time) ([]byte, error) {
+	ccy := s.Currency
+	amt := func(v int64) camtAmt { return camtAmt{Ccy: ccy, Value: FormatAmount(v, ccy)} }
+	bal := func(code string, v int64, day time.Time) camtBal {
+		abs, ind := indicator(v)
+		return camtBal{Cd: code, Amt: amt(abs), CdtDbtInd: ind, Dt: day.Format("2006-01-02")}
+	}
+	st := camtStmt{
+		Id:      s.ID,
+		CreDtTm: now.UTC().Format(time.RFC3339),
+		FrToDt:  camtFrToDt{FrDtTm: s.From.UTC().Format(time.RFC3339), ToDtTm: s.To.UTC().Format(time.RFC3339)},
+		Acct:    camtAcct{IBAN: s.IBAN, Ccy: ccy},
+		Bal: []camtBal{
+			bal("OPBD", s.Opening, s.From.UTC()),
+			bal("CLBD", s.Closing, s.To.UTC().Add(-time.Nanosecond)),
+		},
+		Ntry: []camtNtry{},
+	}
+	if s.OwnerName != "" {
+		st.Acct.Ownr = &camtName{Nm: s.OwnerName}
+	}
+	var sum, credits, debits, net int64
+	for _, e := range s.Entries {
+		abs, ind := indicator(e.Amount)
+		sum += abs
+		net += e.Amount
+		if ind == "CRDT" {
+			credits += abs
+			st.TxsSummry.CdtNbOfNtries++
+		} else {
+			debits += abs
+			st.TxsSummry.DbtNbOfNtries++
+		}
+		endToEnd := e.EndToEndID
+		if endToEnd == "" {
+			endToEnd = "NOTPROVIDED"
+		}
+		st.Ntry = append(st.Ntry, camtNtry{
+			NtryRef:     e.Reference,
+			Amt:         amt(abs),
+			CdtDbtInd:   ind,
+			Sts:         "BOOK",
+			BookgDt:     e.BookedAt.UTC().Format(time.RFC3339),
+			ValDt:       e.BookedAt.UTC().Format("2006-01-02"),
+			AcctSvcrRef: e.Reference,
+			BkTxCd:      e.Code,
+			TxDtls:      camtTxDtls{EndToEndId: endToEnd, Ustrd: e.Info},
+		})
+	}
+	netAbs, netInd := indicator(net)
+	st.TxsSummry.NbOfNtries = len(s.Entries)
+	st.TxsSummry.Sum = FormatAmount(sum, ccy)
+	st.TxsSummry.TtlNetAmt = FormatAmount(netAbs, ccy)
+	st.TxsSummry.TtlNetInd = netInd
+	st.TxsSummry.CdtSum = FormatAmount(credits, ccy)
+	st.TxsSummry.DbtSum = FormatAmount(debits, ccy)
+
+	doc := camtDocument{
+		Xmlns: camt053Namespace,
+		Stmt: camtBkToCstm{
+			GrpHdr: camtGrpHdr{MsgId: s.MessageID, CreDtTm: now.UTC().Format(time.RFC3339)},
+			Stmt:   st,
+		},
+	}
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// ParseCamt053 reads the statement of a camt.053 document. Entry amounts
+// are signed by their credit/debit indicator. Only the first statement of
+// the document is read.
+func ParseCamt053(data []byte) (*Statement, error) {
+	var doc camtDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	st := doc.Stmt.Stmt
+	s := &Statement{
+		MessageID: doc.Stmt.GrpHdr.MsgId,
+		ID:        st.Id,
+		IBAN:      st.Acct.IBAN,
+		Currency:  st.Acct.Ccy,
+	}
+	for i, n := range st.Ntry {
+		ccy := n.Amt.Ccy
+		if ccy == "" {
+			ccy = s.Currency
+		}
+		amount, err := ParseAmount(n.Amt.Value, ccy)
+		if err != nil {
+			return nil, fmt.Errorf("Ntry[%d]/Amt: %w", i+1, err)
+		}
+		switch n.CdtDbtInd {
+		case "DBIT":
+			amount = -amount
+		case "CRDT":
+		default:
+			return nil, fmt.Errorf("Ntry[%d]/CdtDbtInd: must be CRDT or DBIT", i+1)
+		}
+		booked, err := parseDate(n.BookgDt, n.BookgDtDt, n.ValDt)
+		if err != nil {
+			return nil, fmt.Errorf("Ntry[%d]/BookgDt: %w", i+1, err)
+		}
+		ref := n.AcctSvcrRef
+		if ref == "" {
+			ref = n.NtryRef
+		}
+		end := n.TxDtls.EndToEndId
+		if end == "NOTPROVIDED" {
+			end = ""
+		}
+		s.Entries = append(s.Entries, StatementEntry{
+			Reference:  ref,
+			Amount:     amount,
+			BookedAt:   booked,
+			Code:       n.BkTxCd,
+			EndToEndID: end,
+			Info:       n.TxDtls.Ustrd,
+		})
+	}
+	return s, nil
+}
+
+// parseDate returns the first of the given ISO date-times or dates that
+// is set.
+func parseDate(values ...string) (time.Time, error) {
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, nil
+		}
+		return time.Parse("2006-01-02", v)
+	}
+	return time.Time{}, errors.New("missing date")
+}
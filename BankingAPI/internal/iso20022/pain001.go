@@ -0,0 +1,197 @@
+package iso20022
+
+import (
+	"BankingAPI/internal/iban"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Pain001Namespaces are the pain.001 versions accepted.
+var Pain001Namespaces = []string{
+	"urn:iso:std:iso:20022:tech:xsd:pain.001.001.03",
+	"urn:iso:std:iso:20022:tech:xsd:pain.001.001.09",
+}
+
+// Pain001 is a customer credit transfer initiation.
+type Pain001 struct {
+	XMLName xml.Name `xml:"Document"`
+	GrpHdr  struct {
+		MsgId    string `xml:"MsgId"`
+		CreDtTm  string `xml:"CreDtTm"`
+		NbOfTxs  string `xml:"NbOfTxs"`
+		CtrlSum  string `xml:"CtrlSum"`
+		InitgPty string `xml:"InitgPty>Nm"`
+	} `xml:"CstmrCdtTrfInitn>GrpHdr"`
+	PmtInf []PaymentInfo `xml:"CstmrCdtTrfInitn>PmtInf"`
+}
+
+// PaymentInfo is a group of credit transfers from one debtor account.
+type PaymentInfo struct {
+	PmtInfId string `xml:"PmtInfId"`
+	PmtMtd   string `xml:"PmtMtd"`
+	NbOfTxs  string `xml:"NbOfTxs"`
+	CtrlSum  string `xml:"CtrlSum"`
+	// ReqdExctnDt is a plain date in version 03 and wrapped in <Dt> in 09.
+	ReqdExctnDt struct {
+		Value string `xml:",chardata"`
+		Dt    string `xml:"Dt"`
+	} `xml:"ReqdExctnDt"`
+	DbtrNm      string           `xml:"Dbtr>Nm"`
+	DbtrIBAN    string           `xml:"DbtrAcct>Id>IBAN"`
+	DbtrCcy     string           `xml:"DbtrAcct>Ccy"`
+	CdtTrfTxInf []CreditTransfer `xml:"CdtTrfTxInf"`
+}
+
+// CreditTransfer is a single payment instruction.
+type CreditTransfer struct {
+	InstrId    string `xml:"PmtId>InstrId"`
+	EndToEndId string `xml:"PmtId>EndToEndId"`
+	Amt        struct {
+		Ccy   string `xml:"Ccy,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"Amt>InstdAmt"`
+	CdtrNm   string `xml:"Cdtr>Nm"`
+	CdtrIBAN string `xml:"CdtrAcct>Id>IBAN"`
+	Ustrd    string `xml:"RmtInf>Ustrd"`
+
+	// Amount is InstdAmt in minor units, set by Validate.
+	Amount int64 `xml:"-"`
+}
+
+// Namespace returns the document's XML namespace.
+func (p *Pain001) Namespace() string { return p.XMLName.Space }
+
+var (
+	max35Text = regexp.MustCompile(`^\S.{0,34}$`)
+	ccyCode   = regexp.MustCompile(`^[A-Z]{3}$`)
+	isoDate   = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	isoDtTm   = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}`)
+)
+
+// ParsePain001 decodes a pain.001 document. It only fails on XML that is
+// not well-formed; use Validate for the schema rules.
+func ParsePain001(data []byte) (*Pain001, error) {
+	var p Pain001
+	if err := xml.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate checks the document against the pain.001 schema rules used
+// here and fills in each transfer's Amount. It returns every problem
+// found, each naming the offending element.
+func (p *Pain001) Validate() []string {
+	var errs []string
+	bad := func(path, format string, args ...interface{}) {
+		errs = append(errs, path+": "+fmt.Sprintf(format, args...))
+	}
+	known := false
+	for _, ns := range Pain001Namespaces {
+		known = known || p.XMLName.Space == ns
+	}
+	if p.XMLName.Local != "Document" || !known {
+		bad("Document", "namespace %q is not a supported pain.001 version", p.XMLName.Space)
+		return errs
+	}
+	h := p.GrpHdr
+	if !max35Text.MatchString(h.MsgId) {
+		bad("GrpHdr/MsgId", "required, 1 to 35 characters")
+	}
+	if !isoDtTm.MatchString(h.CreDtTm) {
+		bad("GrpHdr/CreDtTm", "required ISO date-time")
+	}
+	if len(p.PmtInf) == 0 {
+		bad("PmtInf", "at least one payment information block is required")
+	}
+
+	total := 0
+	var sum int64
+	for i, pi := range p.PmtInf {
+		path := fmt.Sprintf("PmtInf[%d]", i+1)
+		if !max35Text.MatchString(pi.PmtInfId) {
+			bad(path+"/PmtInfId", "required, 1 to 35 characters")
+		}
+		if pi.PmtMtd != "TRF" {
+			bad(path+"/PmtMtd", "must be TRF")
+		}
+		if !isoDate.MatchString(pi.ExecutionDate()) {
+			bad(path+"/ReqdExctnDt", "required ISO date")
+		}
+		if err := iban.Validate(iban.Normalize(pi.DbtrIBAN)); err != nil {
+			bad(path+"/DbtrAcct/Id/IBAN", "%v", err)
+		}
+		if len(pi.CdtTrfTxInf) == 0 {
+			bad(path+"/CdtTrfTxInf", "at least one credit transfer is required")
+		}
+		var blockSum int64
+		for j := range pi.CdtTrfTxInf {
+			tx := &pi.CdtTrfTxInf[j]
+			tpath := fmt.Sprintf("%s/CdtTrfTxInf[%d]", path, j+1)
+			if !max35Text.MatchString(tx.EndToEndId) {
+				bad(tpath+"/PmtId/EndToEndId", "required, 1 to 35 characters")
+			}
+			if tx.InstrId != "" && !max35Text.MatchString(tx.InstrId) {
+				bad(tpath+"/PmtId/InstrId", "1 to 35 characters")
+			}
+			if !ccyCode.MatchString(tx.Amt.Ccy) {
+				bad(tpath+"/Amt/InstdAmt/@Ccy", "required ISO 4217 code")
+			} else if amount, err := ParseAmount(tx.Amt.Value, tx.Amt.Ccy); err != nil || amount <= 0 {
+				bad(tpath+"/Amt/InstdAmt", "must be a positive amount with at most %d decimals", Exponent(tx.Amt.Ccy))
+			} else {
+				tx.Amount = amount
+				blockSum += amount * pow10(ctrlSumExp-Exponent(tx.Amt.Ccy))
+			}
+			if strings.TrimSpace(tx.CdtrNm) == "" {
+				bad(tpath+"/Cdtr/Nm", "required")
+			}
+			if err := iban.Validate(iban.Normalize(tx.CdtrIBAN)); err != nil {
+				bad(tpath+"/CdtrAcct/Id/IBAN", "%v", err)
+			}
+			if len(tx.Ustrd) > 140 {
+				bad(tpath+"/RmtInf/Ustrd", "at most 140 characters")
+			}
+		}
+		if pi.NbOfTxs != "" && pi.NbOfTxs != strconv.Itoa(len(pi.CdtTrfTxInf)) {
+			bad(path+"/NbOfTxs", "is %s but the block has %d transactions", pi.NbOfTxs, len(pi.CdtTrfTxInf))
+		}
+		if pi.CtrlSum != "" && !sumMatches(pi.CtrlSum, blockSum) {
+			bad(path+"/CtrlSum", "does not match the sum of the block's amounts")
+		}
+		total += len(pi.CdtTrfTxInf)
+		sum += blockSum
+	}
+	if h.NbOfTxs != strconv.Itoa(total) {
+		bad("GrpHdr/NbOfTxs", "is %q but the message has %d transactions", h.NbOfTxs, total)
+	}
+	if h.CtrlSum != "" && !sumMatches(h.CtrlSum, sum) {
+		bad("GrpHdr/CtrlSum", "does not match the sum of all amounts")
+	}
+	return errs
+}
+
+// ctrlSumExp is the scale control sums are compared at: the most minor
+// unit digits of any currency, since a control sum may mix currencies.
+const ctrlSumExp = 3
+
+// sumMatches compares a CtrlSum with a total in units of 10^-ctrlSumExp.
+// The comparison is exact; trailing zeros beyond that scale are allowed.
+func sumMatches(ctrl string, sum int64) bool {
+	ctrl = strings.TrimSpace(ctrl)
+	if strings.Contains(ctrl, ".") {
+		ctrl = strings.TrimSuffix(strings.TrimRight(ctrl, "0"), ".")
+	}
+	v, err := parseDecimal(ctrl, ctrlSumExp)
+	return err == nil && v == sum
+}
+
+// ExecutionDate returns the requested execution date of a block.
+func (pi *PaymentInfo) ExecutionDate() string {
+	if pi.ReqdExctnDt.Dt != "" {
+		return pi.ReqdExctnDt.Dt
+	}
+	return strings.TrimSpace(pi.ReqdExctnDt.Value)
+}
@@ -0,0 +1,149 @@
+package iso20022
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// pain001Doc renders a one-block, two-transfer pain.001, with the
+// placeholders in over replacing the defaults.
+func pain001Doc(over map[string]string) string {
+	vals := map[string]string{
+		"NS":     "urn:iso:std:iso:20022:tech:xsd:pain.001.001.03",
+		"NB":     "2",
+		"SUM":    "210.50",
+		"BSUM":   "210.50",
+		"DATE":   "2026-10-02",
+		"DBTR":   "DE89370400440532013000",
+		"CDTR":   "GB82WEST12345698765432",
+		"AMT1":   "10.50",
+		"AMT2":   "200.00",
+		"CCY":    "EUR",
+		"E2E1":   "E2E-1",
+		"PMTMTD": "TRF",
+	}
+	for k, v := range over {
+		vals[k] = v
+	}
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="NS">
+  <CstmrCdtTrfInitn>
+    <GrpHdr>
+      <MsgId>MSG-1</MsgId>
+      <CreDtTm>2026-10-01T09:00:00</CreDtTm>
+      <NbOfTxs>NB</NbOfTxs>
+      <CtrlSum>SUM</CtrlSum>
+      <InitgPty><Nm>Acme</Nm></InitgPty>
+    </GrpHdr>
+    <PmtInf>
+      <PmtInfId>PMT-1</PmtInfId>
+      <PmtMtd>PMTMTD</PmtMtd>
+      <NbOfTxs>2</NbOfTxs>
+      <CtrlSum>BSUM</CtrlSum>
+      <ReqdExctnDt>DATE</ReqdExctnDt>
+      <Dbtr><Nm>Acme</Nm></Dbtr>
+      <DbtrAcct><Id><IBAN>DBTR</IBAN></Id><Ccy>EUR</Ccy></DbtrAcct>
+      <CdtTrfTxInf>
+        <PmtId><InstrId>I-1</InstrId><EndToEndId>E2E1</EndToEndId></PmtId>
+        <Amt><InstdAmt Ccy="CCY">AMT1</InstdAmt></Amt>
+        <Cdtr><Nm>Bob</Nm></Cdtr>
+        <CdtrAcct><Id><IBAN>CDTR</IBAN></Id></CdtrAcct>
+        <RmtInf><Ustrd>Invoice 1</Ustrd></RmtInf>
+      </CdtTrfTxInf>
+      <CdtTrfTxInf>
+        <PmtId><EndToEndId>E2E-2</EndToEndId></PmtId>
+        <Amt><InstdAmt Ccy="EUR">AMT2</InstdAmt></Amt>
+        <Cdtr><Nm>Carol</Nm></Cdtr>
+        <CdtrAcct><Id><IBAN>DE89370400440532013000</IBAN></Id></CdtrAcct>
+      </CdtTrfTxInf>
+    </PmtInf>
+  </CstmrCdtTrfInitn>
+</Document>`
+	// longest keys first so NB does not eat into another placeholder
+	for _, k := range []string{"PMTMTD", "BSUM", "DATE", "DBTR", "CDTR", "AMT1", "AMT2", "E2E1", "SUM", "CCY", "NS", "NB"} {
+		doc = strings.ReplaceAll(doc, k, vals[k])
+	}
+	return doc
+}
+
+func TestPain001Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		over map[string]string
+		// want lists the element paths of the expected errors
+		want []string
+	}{
+		{"valid 001.03", nil, nil},
+		{"valid 001.09", map[string]string{
+			"NS":   "urn:iso:std:iso:20022:tech:xsd:pain.001.001.09",
+			"DATE": "<Dt>2026-10-02</Dt>",
+		}, nil},
+		{"control sum with trailing zeros", map[string]string{"SUM": "210.5000", "BSUM": "210.5"}, nil},
+		{"unsupported version", map[string]string{"NS": "urn:iso:std:iso:20022:tech:xsd:pain.001.001.02"}, []string{"Document"}},
+		{"bad creditor IBAN", map[string]string{"CDTR": "GB83WEST12345698765432"}, []string{"PmtInf[1]/CdtTrfTxInf[1]/CdtrAcct/Id/IBAN"}},
+		{"bad debtor IBAN", map[string]string{"DBTR": "DE89370400440532013001"}, []string{"PmtInf[1]/DbtrAcct/Id/IBAN"}},
+		{"NbOfTxs mismatch", map[string]string{"NB": "3"}, []string{"GrpHdr/NbOfTxs"}},
+		{"CtrlSum off by a cent", map[string]string{"SUM": "210.51"}, []string{"GrpHdr/CtrlSum"}},
+		{"CtrlSum off below a cent", map[string]string{"SUM": "210.501"}, []string{"GrpHdr/CtrlSum"}},
+		{"block CtrlSum mismatch", map[string]string{"BSUM": "210.00"}, []string{"PmtInf[1]/CtrlSum"}},
+		{"too many decimals", map[string]string{"AMT1": "10.505", "SUM": "200.00", "BSUM": "200.00"}, []string{"PmtInf[1]/CdtTrfTxInf[1]/Amt/InstdAmt"}},
+		{"three-decimal currency", map[string]string{"AMT1": "10.505", "CCY": "KWD", "SUM": "210.505", "BSUM": "210.505"}, nil},
+		{"zero amount", map[string]string{"AMT1": "0.00", "SUM": "200.00", "BSUM": "200.00"}, []string{"PmtInf[1]/CdtTrfTxInf[1]/Amt/InstdAmt"}},
+		{"missing end-to-end ID", map[string]string{"E2E1": ""}, []string{"PmtInf[1]/CdtTrfTxInf[1]/PmtId/EndToEndId"}},
+		{"not a transfer", map[string]string{"PMTMTD": "CHK"}, []string{"PmtInf[1]/PmtMtd"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParsePain001([]byte(pain001Doc(tt.over)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range doc.Validate() {
+				path, _, _ := strings.Cut(e, ":")
+				got = append(got, path)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate errors at %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePain001(t *testing.T) {
+	t.Run("001.09", func(t *testing.T) {
+		doc, err := ParsePain001([]byte(pain001Doc(map[string]string{
+			"NS":   "urn:iso:std:iso:20022:tech:xsd:pain.001.001.09",
+			"DATE": "<Dt>2026-10-02</Dt>",
+		})))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errs := doc.Validate(); len(errs) > 0 {
+			t.Fatal(errs)
+		}
+		if doc.GrpHdr.MsgId != "MSG-1" || len(doc.PmtInf) != 1 {
+			t.Fatalf("MsgId %q with %d blocks", doc.GrpHdr.MsgId, len(doc.PmtInf))
+		}
+		pi := doc.PmtInf[0]
+		if got := pi.ExecutionDate(); got != "2026-10-02" {
+			t.Errorf("ExecutionDate = %q, want 2026-10-02", got)
+		}
+		if len(pi.CdtTrfTxInf) != 2 {
+			t.Fatalf("%d transfers, want 2", len(pi.CdtTrfTxInf))
+		}
+		tx := pi.CdtTrfTxInf[0]
+		if tx.Amount != 1050 || tx.Amt.Ccy != "EUR" || tx.CdtrIBAN != "GB82WEST12345698765432" || tx.Ustrd != "Invoice 1" {
+			t.Errorf("first transfer = %+v", tx)
+		}
+		if got := pi.CdtTrfTxInf[1].Amount; got != 20000 {
+			t.Errorf("second transfer Amount = %d, want 20000", got)
+		}
+	})
+	t.Run("malformed XML", func(t *testing.T) {
+		if _, err := ParsePain001([]byte("<Document><CstmrCdtTrfInitn>")); err == nil {
+			t.Error("ParsePain001 accepted truncated XML")
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package iso20022
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+const pain002Namespace = "urn:iso:std:iso:20022:tech:xsd:pain.002.001.10"
+
+// Transaction and group status codes (ExternalPaymentTransactionStatus1Code).
+const (
+	StatusAccepted        = "ACCP" // group: accepted for processing
+	StatusSettled         = "ACSC" // accepted, settlement completed
+	StatusPending         = "PDNG"
+	StatusRejected        = "RJCT"
+	StatusPartiallyAccept = "PART"
+)
+
+// Status reason codes (ExternalStatusReason1Code).
+const (
+	ReasonInvalidFile       = "FF01"
+	ReasonDuplicate         = "AM05"
+	ReasonInsufficientFunds = "AM04"
+	ReasonNotAllowedAmount  = "AM02"
+	ReasonWrongCurrency     = "AM03"
+	ReasonIncorrectAccount  = "AC01"
+	ReasonClosedAccount     = "AC04"
+	ReasonBlockedAccount    = "AC06"
+	ReasonForbidden         = "AG01"
+	ReasonNarrative         = "NARR"
+)
+
+// StatusReport is the content of a pain.002 report on a pain.001 message.
+type StatusReport struct {
+	MessageID         string
+	OriginalMessageID string
+	OriginalNamespace string
+	OriginalNbOfTxs   string
+	GroupStatus       string
+	GroupReasons      []Reason
+	Payments          []PaymentStatus
+}
+
+// PaymentStatus reports on one PmtInf block.
+type PaymentStatus struct {
+	OriginalPmtInfID string
+	Status           string
+	Transactions     []TransactionStatus
+}
+
+// TransactionStatus reports on one credit transfer.
+type TransactionStatus struct {
+	OriginalInstrID    string
+	OriginalEndToEndID string
+	Status             string
+	Reason             *Reason
+}
+
+// Reason is a status reason code with optional free text.
+type Reason struct {
+	Code string
+	Info string
+}
+
+type p2Document struct {
+	XMLName xml.Name `xml:"Document"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Rpt     p2Report `xml:"CstmrPmtStsRpt"`
+}
+
+type p2Report struct {
+	MsgId   string       `xml:"GrpHdr>MsgId"`
+	CreDtTm string       `xml:"GrpHdr>CreDtTm"`
+	Orgnl   p2OrgnlGrp   `xml:"OrgnlGrpInfAndSts"`
+	PmtInf  []p2OrgnlPmt `xml:"OrgnlPmtInfAndSts"`
+}
+
+type p2OrgnlGrp struct {
+	OrgnlMsgId   string     `xml:"OrgnlMsgId"`
+	OrgnlMsgNmId string     `xml:"OrgnlMsgNmId"`
+	OrgnlNbOfTxs string     `xml:"OrgnlNbOfTxs,omitempty"`
+	GrpSts       string     `xml:"GrpSts"`
+	StsRsnInf    []p2Reason `xml:"StsRsnInf"`
+}
+
+type p2OrgnlPmt struct {
+	OrgnlPmtInfId string    `xml:"OrgnlPmtInfId"`
+	PmtInfSts     string    `xml:"PmtInfSts"`
+	TxInfAndSts   []p2TxSts `xml:"TxInfAndSts"`
+}
+
+type p2TxSts struct {
+	OrgnlInstrId    string    `xml:"OrgnlInstrId,omitempty"`
+	OrgnlEndToEndId string    `xml:"OrgnlEndToEndId"`
+	TxSts           string    `xml:"TxSts"`
+	StsRsnInf       *p2Reason `xml:"StsRsnInf,omitempty"`
+}
+
+type p2Reason struct {
+	Cd       string `xml:"Rsn>Cd"`
+	AddtlInf string `xml:"AddtlInf,omitempty"`
+}
+
+// messageName maps a namespace to the message name identifier, e.g.
+// "pain.001.001.09".
+func messageName(ns string) string {
+	const prefix = "urn:iso:std:iso:20022:tech:xsd:"
+	if len(ns) > len(prefix) && ns[:len(prefix)] == prefix {
+		return ns[len(prefix):]
+	}
+	return "pain.001"
+}
+
+// AddtlInf is limited to 105 characters.
+func truncate(s string) string {
+	if len(s) > 105 {
+		return s[:105]
+	}
+	return s
+}
+
+// MarshalPain002 renders rep as a pain.002.001.10 document.
+func MarshalPain002(rep *StatusReport, now time.Time) ([]byte, error) {
+	orig := rep.OriginalMessageID
+	if orig == "" {
+		orig = "NOTPROVIDED"
+	}
+	doc := p2Document{
+		Xmlns: pain002Namespace,
+		Rpt: p2Report{
+			MsgId:   rep.MessageID,
+			CreDtTm: now.UTC().Format(time.RFC3339),
+			Orgnl: p2OrgnlGrp{
+				OrgnlMsgId:   orig,
+				OrgnlMsgNmId: messageName(rep.OriginalNamespace),
+				OrgnlNbOfTxs: rep.OriginalNbOfTxs,
+				GrpSts:       rep.GroupStatus,
+			},
+		},
+	}
+	for _, r := range rep.GroupReasons {
+		doc.Rpt.Orgnl.StsRsnInf = append(doc.Rpt.Orgnl.StsRsnInf, p2Reason{Cd: r.Code, AddtlInf: truncate(r.Info)})
+	}
+	for _, p := range rep.Payments {
+		op := p2OrgnlPmt{OrgnlPmtInfId: p.OriginalPmtInfID, PmtInfSts: p.Status}
+		for _, t := range p.Transactions {
+			ts := p2TxSts{OrgnlInstrId: t.OriginalInstrID, OrgnlEndToEndId: t.OriginalEndToEndID, TxSts: t.Status}
+			if t.Reason != nil {
+				ts.StsRsnInf = &p2Reason{Cd: t.Reason.Code, AddtlInf: truncate(t.Reason.Info)}
+			}
+			op.TxInfAndSts = append(op.TxInfAndSts, ts)
+		}
+		doc.Rpt.PmtInf = append(doc.Rpt.PmtInf, op)
+	}
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// Summarize derives a block or group status from its parts' statuses:
+// all rejected is RJCT, some rejected is PART, otherwise ACCP while
+// anything is pending and ACSC once everything settled.
+func Summarize(statuses []string) string {
+	rejected, partial, pending := 0, 0, 0
+	for _, s := range statuses {
+		switch s {
+		case StatusRejected:
+			rejected++
+		case StatusPartiallyAccept:
+			partial++
+		case StatusPending, StatusAccepted:
+			pending++
+		}
+	}
+	switch {
+	case len(statuses) == 0 || rejected == len(statuses):
+		return StatusRejected
+	case rejected > 0 || partial > 0:
+		return StatusPartiallyAccept
+	case pending > 0:
+		return StatusAccepted
+	}
+	return StatusSettled
+}
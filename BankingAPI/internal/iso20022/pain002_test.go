@@ -0,0 +1,107 @@
+package iso20022
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalPain002(t *testing.T) {
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		rep  *StatusReport
+		want []string
+	}{
+		{
+			"duplicate message ID",
+			&StatusReport{
+				MessageID:         "RPT-1",
+				OriginalMessageID: "MSG-1",
+				OriginalNamespace: "urn:iso:std:iso:20022:tech:xsd:pain.001.001.09",
+				OriginalNbOfTxs:   "2",
+				GroupStatus:       StatusRejected,
+				GroupReasons:      []Reason{{Code: ReasonDuplicate, Info: "message ID already submitted"}},
+			},
+			[]string{
+				`<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.002.001.10">`,
+				"<MsgId>RPT-1</MsgId>",
+				"<CreDtTm>2026-10-01T09:00:00Z</CreDtTm>",
+				"<OrgnlMsgId>MSG-1</OrgnlMsgId>",
+				"<OrgnlMsgNmId>pain.001.001.09</OrgnlMsgNmId>",
+				"<OrgnlNbOfTxs>2</OrgnlNbOfTxs>",
+				"<GrpSts>RJCT</GrpSts>",
+				"<Cd>AM05</Cd>",
+				"<AddtlInf>message ID already submitted</AddtlInf>",
+			},
+		},
+		{
+			"unreadable file",
+			&StatusReport{MessageID: "RPT-2", GroupStatus: StatusRejected, GroupReasons: []Reason{{Code: ReasonInvalidFile}}},
+			[]string{"<OrgnlMsgId>NOTPROVIDED</OrgnlMsgId>", "<OrgnlMsgNmId>pain.001</OrgnlMsgNmId>", "<Cd>FF01</Cd>"},
+		},
+		{
+			"transaction statuses",
+			&StatusReport{
+				MessageID:         "RPT-3",
+				OriginalMessageID: "MSG-3",
+				OriginalNamespace: "urn:iso:std:iso:20022:tech:xsd:pain.001.001.03",
+				GroupStatus:       StatusPartiallyAccept,
+				Payments: []PaymentStatus{{
+					OriginalPmtInfID: "PMT-1",
+					Status:           StatusPartiallyAccept,
+					Transactions: []TransactionStatus{
+						{OriginalInstrID: "I-1", OriginalEndToEndID: "E2E-1", Status: StatusSettled},
+						{OriginalEndToEndID: "E2E-2", Status: StatusRejected, Reason: &Reason{Code: ReasonWrongCurrency, Info: strings.Repeat("x", 200)}},
+					},
+				}},
+			},
+			[]string{
+				"<OrgnlMsgNmId>pain.001.001.03</OrgnlMsgNmId>",
+				"<GrpSts>PART</GrpSts>",
+				"<OrgnlPmtInfId>PMT-1</OrgnlPmtInfId>",
+				"<PmtInfSts>PART</PmtInfSts>",
+				"<OrgnlInstrId>I-1</OrgnlInstrId>",
+				"<TxSts>ACSC</TxSts>",
+				"<TxSts>RJCT</TxSts>",
+				"<Cd>AM03</Cd>",
+				"<AddtlInf>" + strings.Repeat("x", 105) + "</AddtlInf>",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := MarshalPain002(tt.rep, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(string(out), w) {
+					t.Errorf("report lacks %s:\n%s", w, out)
+				}
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		want     string
+	}{
+		{"none", nil, StatusRejected},
+		{"all settled", []string{StatusSettled, StatusSettled}, StatusSettled},
+		{"one pending", []string{StatusSettled, StatusPending}, StatusAccepted},
+		{"all rejected", []string{StatusRejected, StatusRejected}, StatusRejected},
+		{"some rejected", []string{StatusSettled, StatusRejected}, StatusPartiallyAccept},
+		{"partial block", []string{StatusSettled, StatusPartiallyAccept}, StatusPartiallyAccept},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Summarize(tt.statuses); got != tt.want {
+				t.Errorf("Summarize(%v) = %s, want %s", tt.statuses, got, tt.want)
+			}
+		})
+	}
+}
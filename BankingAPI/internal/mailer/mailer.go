@@ -0,0 +1,132 @@
+// Package mailer sends the emails the API needs, such as password reset
+// and address verification links, through a pluggable transport.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// ErrQueueFull is returned when a Queue has no room for another message.
+var ErrQueueFull = errors.New("mail queue full")
+
+// Queue hands messages to a Mailer in the background, so a request never
+// waits for delivery and its timing does not depend on whether mail was
+// sent. Delivery failures are logged.
+type Queue struct {
+	next Mailer
+	ch   chan Message
+}
+
+// NewQueue starts a queue of up to size messages in front of next.
+func NewQueue(next Mailer, size int) *Queue {
+	q := &Queue{next: next, ch: make(chan Message, size)}
+	go q.run()
+	return q
+}
+
+func (q *Queue) Send(ctx context.Context, m Message) error {
+	select {
+	case q.ch <- m:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (q *Queue) run() {
+	for m := range q.ch {
+		if err := q.next.Send(context.Background(), m); err != nil {
+			log.Printf("mail to %s failed: %v", m.To, err)
+		}
+	}
+}
+
+// LogMailer writes each message to Dir as an .eml file, or to the log
+// when Dir is empty. It is meant for development only: the messages hold
+// live reset and verification links.
+type LogMailer struct {
+	From string
+	Dir  string
+}
+
+func (l *LogMailer) Send(ctx context.Context, m Message) error {
+	data := format(l.From, m, time.Now())
+	if l.Dir == "" {
+		log.Printf("mail:\n%s", data)
+		return nil
+	}
+	if err := os.MkdirAll(l.Dir, 0o700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), sanitizeFileName(m.To))
+	return os.WriteFile(filepath.Join(l.Dir, name), data, 0o600)
+}
+
+// SMTPMailer sends messages through an SMTP server. Username and Password
+// are optional; net/smtp only sends them over TLS or to localhost.
+type SMTPMailer struct {
+	Addr     string // host:port
+	From     string
+	Username string
+	Password string
+}
+
+func (s *SMTPMailer) Send(ctx context.Context, m Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		host := s.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	return smtp.SendMail(s.Addr, auth, s.From, []string{m.To}, format(s.From, m, time.Now()))
+}
+
+// format renders m as an RFC 5322 message. Header values are stripped of
+// line breaks so user input cannot inject headers.
+func format(from string, m Message, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", headerValue(from))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(m.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+	return b.Bytes()
+}
+
+func headerValue(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(v)
+}
+
+func sanitizeFileName(v string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '@' || r == '.' || r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, v)
+}
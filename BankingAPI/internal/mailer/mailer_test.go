@@ -0,0 +1,172 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpSession is what a stub SMTP server received in one session.
+type smtpSession struct {
+	from, to string
+	data     string
+}
+
+// stubSMTP accepts a single SMTP session on a local port and reports it
+// on the returned channel.
+func stubSMTP(t *testing.T) (string, <-chan smtpSession) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	got := make(chan smtpSession, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		tp := textproto.NewConn(conn)
+		var s smtpSession
+		tp.PrintfLine("220 stub ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch verb {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 stub")
+			case "MAIL":
+				s.from = line
+				tp.PrintfLine("250 ok")
+			case "RCPT":
+				s.to = line
+				tp.PrintfLine("250 ok")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				lines, err := tp.ReadDotLines()
+				if err != nil {
+					return
+				}
+				s.data = strings.Join(lines, "\n")
+				tp.PrintfLine("250 queued")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				got <- s
+				return
+			default:
+				tp.PrintfLine("250 ok")
+			}
+		}
+	}()
+	return l.Addr().String(), got
+}
+
+// headers returns the header lines of a received message.
+func headers(data string) []string {
+	head, _, _ := strings.Cut(data, "\n\n")
+	return strings.Split(head, "\n")
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	tests := []struct {
+		name        string
+		msg         Message
+		wantSubject string
+	}{
+		{"plain", Message{To: "alice@example.com", Subject: "Reset your password", Body: "Line one\nLine two"}, "Reset your password"},
+		{"CRLF in subject", Message{To: "alice@example.com", Subject: "Hi\r\nBcc: evil@example.com", Body: "x"}, "HiBcc: evil@example.com"},
+		{"bare LF in subject", Message{To: "alice@example.com", Subject: "Hi\nX-Injected: 1\r\n\r\nfake body", Body: "x"}, "HiX-Injected: 1fake body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, got := stubSMTP(t)
+			m := &SMTPMailer{Addr: addr, From: "no-reply@example.com"}
+			if err := m.Send(context.Background(), tt.msg); err != nil {
+				t.Fatal(err)
+			}
+			var s smtpSession
+			select {
+			case s = <-got:
+			case <-time.After(5 * time.Second):
+				t.Fatal("stub server received no message")
+			}
+			if s.from != "MAIL FROM:<no-reply@example.com>" {
+				t.Errorf("MAIL = %q", s.from)
+			}
+			if s.to != "RCPT TO:<alice@example.com>" {
+				t.Errorf("RCPT = %q", s.to)
+			}
+			hs := headers(s.data)
+			subjects := 0
+			for _, h := range hs {
+				name, value, _ := strings.Cut(h, ": ")
+				switch name {
+				case "Subject":
+					subjects++
+					if value != tt.wantSubject {
+						t.Errorf("Subject = %q, want %q", value, tt.wantSubject)
+					}
+				case "From", "To", "Date", "MIME-Version", "Content-Type":
+				default:
+					t.Errorf("unexpected header line %q", h)
+				}
+			}
+			if subjects != 1 {
+				t.Errorf("%d Subject headers, want 1", subjects)
+			}
+		})
+	}
+}
+
+func TestSMTPMailerRejectsRecipientWithCRLF(t *testing.T) {
+	m := &SMTPMailer{Addr: "127.0.0.1:1", From: "no-reply@example.com"}
+	if err := m.Send(context.Background(), Message{To: "alice@example.com\r\nRCPT TO:<evil@example.com>", Subject: "s"}); err == nil {
+		t.Error("Send accepted a recipient containing CRLF")
+	}
+}
+
+func TestHeaderValue(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Hello", "Hello"},
+		{"a\r\nBcc: b@example.com", "aBcc: b@example.com"},
+		{"a\nb", "ab"},
+		{"a\rb", "ab"},
+		{"\r\n\r\n", ""},
+	}
+	for _, tt := range tests {
+		if got := headerValue(tt.in); got != tt.want {
+			t.Errorf("headerValue(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	out := string(format("bank@example.com", Message{To: "a@example.com", Subject: "s\r\nBcc: x@example.com", Body: "one\ntwo"}, now))
+	r := textproto.NewReader(bufio.NewReader(strings.NewReader(out)))
+	h, err := r.ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Get("Bcc") != "" {
+		t.Errorf("injected Bcc header: %q", h.Get("Bcc"))
+	}
+	if got := h.Get("Subject"); got != "sBcc: x@example.com" {
+		t.Errorf("Subject = %q", got)
+	}
+	if !strings.HasSuffix(out, "\r\n\r\none\r\ntwo") {
+		t.Errorf("body not CRLF-terminated:\n%q", out)
+	}
+}
@@ -2,6 +2,8 @@ package middleware
 
 import (
 	authpkg "BankingAPI/internal/auth"
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/repo"
 	"context"
 	"encoding/json"
 	"log"
@@ -24,26 +26,112 @@ func Recoverer(next http.Handler) http.Handler {
 	})
 }
 
-// Auth middleware enforces Bearer token and injects user_id into context
-func Auth(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		auth := r.Header.Get("Authorization")
-		if auth == "" {
-			http.Error(w, "missing authorization", http.StatusUnauthorized)
-			return
-		}
-		parts := strings.SplitN(auth, " ", 2)
-		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			http.Error(w, "invalid authorization header", http.StatusUnauthorized)
-			return
-		}
-		tok := parts[1]
-		userID, err := authpkg.ParseToken(tok)
-		if err != nil {
-			http.Error(w, "invalid token", http.StatusUnauthorized)
-			return
-		}
-		ctx := context.WithValue(r.Context(), "user_id", userID)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+// Tenant resolves the tenant from the Host header, falling back to
+// defaultTenant, and scopes the request context to it. Hosts that map to
+// a tenant are remembered so Auth can reject tokens of another tenant.
+func Tenant(resolve func(host string) (string, bool), defaultTenant string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			tenantID, ok := resolve(r.Host)
+			if ok {
+				ctx = context.WithValue(ctx, "host_tenant_id", tenantID)
+			} else {
+				tenantID = defaultTenant
+			}
+			next.ServeHTTP(w, r.WithContext(repo.WithTenant(ctx, tenantID)))
+		})
+	}
+}
+
+// RequireRole admits requests whose token carries one of roles. It must
+// run after Auth.
+func RequireRole(roles ...model.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, _ := r.Context().Value("claims").(*authpkg.Claims)
+			if claims == nil || !claims.HasRole(roles...) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Auth middleware enforces a Bearer JWT, including those issued to OAuth
+// clients, or API key, rejects tokens whose jti has been revoked and
+// injects user_id, the credential's tenant and its claims into context
+func Auth(revoked func(jti string) bool, apiKey func(key, ip string) (*model.APIKey, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth := r.Header.Get("Authorization")
+			if auth == "" {
+				http.Error(w, "missing authorization", http.StatusUnauthorized)
+				return
+			}
+			parts := strings.SplitN(auth, " ", 2)
+			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+				http.Error(w, "invalid authorization header", http.StatusUnauthorized)
+				return
+			}
+			tok := parts[1]
+			var claims *authpkg.Claims
+			if strings.HasPrefix(tok, repo.APIKeyPrefix) {
+				k, err := apiKey(tok, authpkg.ClientIP(r))
+				if err == repo.ErrAPIKeyIPRefused {
+					http.Error(w, err.Error(), http.StatusForbidden)
+					return
+				}
+				if err != nil {
+					http.Error(w, "invalid API key", http.StatusUnauthorized)
+					return
+				}
+				claims = authpkg.APIKeyClaims(k)
+			} else {
+				var err error
+				claims, err = authpkg.ParseToken(tok)
+				if err != nil || revoked(claims.TokenID) {
+					http.Error(w, "invalid token", http.StatusUnauthorized)
+					return
+				}
+			}
+			if host, ok := r.Context().Value("host_tenant_id").(string); ok && host != claims.TenantID {
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+			ctx := context.WithValue(r.Context(), "user_id", claims.UserID)
+			ctx = context.WithValue(ctx, "claims", claims)
+			ctx = repo.WithTenant(ctx, claims.TenantID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireScope refuses scoped credentials, API keys and OAuth client
+// tokens, on routes whose scope they were not granted. scopeOf names the
+// scope a request's route needs; routes without one are closed to scoped
+// credentials. It must run after Auth.
+func RequireScope(scopeOf func(r *http.Request) (model.Scope, bool)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, _ := r.Context().Value("claims").(*authpkg.Claims)
+			if claims == nil {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if claims.Scoped {
+				scope, ok := scopeOf(r)
+				if !ok {
+					http.Error(w, "not available to API keys or OAuth clients", http.StatusForbidden)
+					return
+				}
+				if !claims.Allows(scope) {
+					http.Error(w, "insufficient scope: requires "+string(scope), http.StatusForbidden)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
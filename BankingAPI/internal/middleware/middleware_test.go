@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	authpkg "BankingAPI/internal/auth"
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/repo"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// setTestKeys gives tenantID an HS256 signing key.
+func setTestKeys(t *testing.T, tenantID string) {
+	t.Helper()
+	k, err := authpkg.NewKey(tenantID+"-hs256", "HS256", strings.Repeat("s", 32), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := authpkg.SetTenantKeys(tenantID, []*authpkg.Key{k}, ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testToken(t *testing.T, c authpkg.Claims) string {
+	t.Helper()
+	tok, _, err := authpkg.GenerateToken(c, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+// testAPIKeys stands in for Repo.AuthenticateAPIKey; the key is the
+// comma-separated list of its scopes after the prefix.
+func testAPIKeys(key, ip string) (*model.APIKey, error) {
+	var scopes []model.Scope
+	for _, s := range strings.Split(strings.TrimPrefix(key, repo.APIKeyPrefix), ",") {
+		if s != "" {
+			scopes = append(scopes, model.Scope(s))
+		}
+	}
+	return &model.APIKey{ID: "key", TenantID: "t", UserID: "u", Scopes: scopes}, nil
+}
+
+func TestRequireScope(t *testing.T) {
+	setTestKeys(t, "t")
+	mx := mux.NewRouter()
+	pr := mx.PathPrefix("/").Subrouter()
+	scopes := map[*mux.Route]model.Scope{}
+	scopeOf := func(r *http.Request) (model.Scope, bool) {
+		s, ok := scopes[mux.CurrentRoute(r)]
+		return s, ok
+	}
+	pr.Use(Auth(func(string) bool { return false }, testAPIKeys), RequireScope(scopeOf))
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	scopes[pr.HandleFunc("/accounts", ok).Methods("GET")] = model.ScopeAccountsRead
+	scopes[pr.HandleFunc("/accounts/{id}/deposit", ok).Methods("POST")] = model.ScopeAccountsWrite
+	scopes[pr.HandleFunc("/transfers", ok).Methods("GET")] = model.ScopeTransfersRead
+	scopes[pr.HandleFunc("/transfers", ok).Methods("POST")] = model.ScopeTransfersWrite
+	pr.HandleFunc("/auth/api-keys", ok).Methods("POST")
+
+	session := "Bearer " + testToken(t, authpkg.Claims{TenantID: "t", UserID: "u", SessionID: "s"})
+	client := "Bearer " + testToken(t, authpkg.Claims{TenantID: "t", UserID: "u", ClientID: "app", Scopes: []model.Scope{model.ScopeTransfersRead}})
+	readKey := "Bearer " + repo.APIKeyPrefix + "accounts:read"
+	allKey := "Bearer " + repo.APIKeyPrefix + "accounts:read,accounts:write,transfers:read,transfers:write"
+	tests := []struct {
+		name   string
+		auth   string
+		method string
+		path   string
+		want   int
+	}{
+		{"no credentials", "", "GET", "/accounts", http.StatusUnauthorized},
+		{"session on a scoped route", session, "GET", "/accounts", http.StatusOK},
+		{"session on a write route", session, "POST", "/transfers", http.StatusOK},
+		{"session on an unscoped route", session, "POST", "/auth/api-keys", http.StatusOK},
+		{"read key reading", readKey, "GET", "/accounts", http.StatusOK},
+		{"read key depositing", readKey, "POST", "/accounts/a1/deposit", http.StatusForbidden},
+		{"read key listing transfers", readKey, "GET", "/transfers", http.StatusForbidden},
+		{"read key transferring", readKey, "POST", "/transfers", http.StatusForbidden},
+		{"full key depositing", allKey, "POST", "/accounts/a1/deposit", http.StatusOK},
+		{"full key transferring", allKey, "POST", "/transfers", http.StatusOK},
+		{"full key on an unscoped route", allKey, "POST", "/auth/api-keys", http.StatusForbidden},
+		{"OAuth client within its scope", client, "GET", "/transfers", http.StatusOK},
+		{"OAuth client outside its scope", client, "POST", "/transfers", http.StatusForbidden},
+		{"OAuth client on an unscoped route", client, "POST", "/auth/api-keys", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			mx.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.path, rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestAuthTenant(t *testing.T) {
+	setTestKeys(t, "t")
+	setTestKeys(t, "other")
+	hosts := map[string]string{"t.example.com": "t", "other.example.com": "other"}
+	resolve := func(host string) (string, bool) {
+		id, ok := hosts[host]
+		return id, ok
+	}
+	var gotTenant string
+	h := Tenant(resolve, "t")(Auth(func(string) bool { return false }, testAPIKeys)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTenant = repo.TenantFrom(r.Context())
+	})))
+
+	tToken := "Bearer " + testToken(t, authpkg.Claims{TenantID: "t", UserID: "u"})
+	otherToken := "Bearer " + testToken(t, authpkg.Claims{TenantID: "other", UserID: "u"})
+	tKey := "Bearer " + repo.APIKeyPrefix + "accounts:read"
+	tests := []struct {
+		name       string
+		host       string
+		auth       string
+		want       int
+		wantTenant string
+	}{
+		{"token at its tenant's host", "t.example.com", tToken, http.StatusOK, "t"},
+		{"token at another tenant's host", "other.example.com", tToken, http.StatusUnauthorized, ""},
+		{"other tenant's token at its host", "other.example.com", otherToken, http.StatusOK, "other"},
+		{"other tenant's token at the first host", "t.example.com", otherToken, http.StatusUnauthorized, ""},
+		{"token at an unmapped host", "localhost", otherToken, http.StatusOK, "other"},
+		{"API key at another tenant's host", "other.example.com", tKey, http.StatusUnauthorized, ""},
+		{"API key at its tenant's host", "t.example.com", tKey, http.StatusOK, "t"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTenant = ""
+			req := httptest.NewRequest("GET", "/accounts", nil)
+			req.Host = tt.host
+			req.Header.Set("Authorization", tt.auth)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want || gotTenant != tt.wantTenant {
+				t.Errorf("status %d in tenant %q, want %d in %q", rec.Code, gotTenant, tt.want, tt.wantTenant)
+			}
+		})
+	}
+}
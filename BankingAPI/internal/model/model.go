@@ -2,25 +2,226 @@ package model
 
 import "time"
 
+// Tenant is a bank or brand served from the same deployment. All users,
+// accounts and transactions belong to exactly one tenant.
+type Tenant struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Hosts       []string `json:"hosts,omitempty"`
+	Currencies  []string `json:"currencies,omitempty"`
+	IBANCountry string   `json:"iban_country"`
+	BankCode    string   `json:"bank_code"`
+	// AdminEmails are granted RoleAdmin once they are verified, so a new
+	// deployment has someone who can assign roles.
+	AdminEmails []string `json:"-"`
+}
+
+// Role grants a user staff access to the /admin endpoints.
+type Role string
+
+const (
+	// RoleSupport may look users up and lift login lockouts.
+	RoleSupport Role = "support"
+	// RoleAdmin may also deactivate users, assign roles, freeze accounts
+	// and adjust balances.
+	RoleAdmin Role = "admin"
+)
+
+// Valid reports whether r is a known role.
+func (r Role) Valid() bool {
+	return r == RoleSupport || r == RoleAdmin
+}
+
 type User struct {
 	ID           string    `json:"id"`
+	TenantID     string    `json:"tenant_id"`
 	Email        string    `json:"email"`
 	PasswordHash string    `json:"-"`
 	Name         string    `json:"name"`
 	IsActive     bool      `json:"is_active"`
+	Roles        []Role    `json:"roles,omitempty"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+	// EmailVerifiedAt is set once the user has followed a verification
+	// link sent to Email.
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// ErasedAt is set once the user's personal data has been erased; the
+	// record then only keeps its ID, which financial records still refer to.
+	ErasedAt *time.Time `json:"erased_at,omitempty"`
+	// TOTPEnabled requires a second factor at login. TOTPSecret is set
+	// from enrolment on, but only counts once a first code confirms it.
+	TOTPEnabled  bool   `json:"totp_enabled"`
+	TOTPSecret   string `json:"-"`
+	TOTPLastStep int64  `json:"-"` // last accepted time step, against replay
+	// RecoveryCodes are hashes of the unused one-time recovery codes.
+	RecoveryCodes []string `json:"-"`
+}
+
+// HasRole reports whether the user holds any of roles.
+func (u *User) HasRole(roles ...Role) bool {
+	for _, have := range u.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// UserTokenPurpose is what a UserToken may be spent on.
+type UserTokenPurpose string
+
+const (
+	TokenPasswordReset     UserTokenPurpose = "password_reset"
+	TokenEmailVerification UserTokenPurpose = "email_verification"
+)
+
+// UserToken is a single-use token sent to a user by email. Only its hash
+// is stored.
+type UserToken struct {
+	TokenHash string
+	TenantID  string
+	UserID    string
+	Purpose   UserTokenPurpose
+	Email     string // the address the token was sent to
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// Scope is a permission a scoped credential, such as an API key, may
+// be granted. A user's own session may do everything.
+type Scope string
+
+const (
+	ScopeAccountsRead   Scope = "accounts:read"
+	ScopeAccountsWrite  Scope = "accounts:write"
+	ScopeTransfersRead  Scope = "transfers:read"
+	ScopeTransfersWrite Scope = "transfers:write"
+)
+
+// Valid reports whether s is a known scope.
+func (s Scope) Valid() bool {
+	switch s {
+	case ScopeAccountsRead, ScopeAccountsWrite, ScopeTransfersRead, ScopeTransfersWrite:
+		return true
+	}
+	return false
+}
+
+// APIKey lets a server-to-server client act as its user within Scopes.
+// The key itself is only shown when it is created.
+type APIKey struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+	UserID   string `json:"user_id"`
+	Name     string `json:"name"`
+	// Prefix is the start of the key, kept to tell keys apart.
+	Prefix  string  `json:"prefix"`
+	KeyHash string  `json:"-"`
+	Scopes  []Scope `json:"scopes"`
+	// AllowedIPs are the addresses or CIDR ranges the key may be used
+	// from; empty allows any.
+	AllowedIPs []string   `json:"allowed_ips,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// LoginThrottle counts recent failed logins for one email address or
+// client IP.
+type LoginThrottle struct {
+	Key           string     `json:"key"` // "email:<tenant>/<email>" or "ip:<tenant>/<ip>"
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	// InFlight counts attempts whose password is still being checked.
+	InFlight int `json:"-"`
+}
+
+// MFAChallenge is the pending second step of a login: the password was
+// right and a TOTP or recovery code is still owed.
+type MFAChallenge struct {
+	TokenHash string
+	TenantID  string
+	UserID    string
+	Attempts  int
+	ExpiresAt time.Time
+}
+
+type AccountStatus string
+
+const (
+	AccountActive  AccountStatus = "ACTIVE"
+	AccountFrozen  AccountStatus = "FROZEN"
+	AccountDormant AccountStatus = "DORMANT"
+	AccountClosed  AccountStatus = "CLOSED"
+)
+
+// accountTransitions lists the statuses reachable from each status.
+// CLOSED is terminal.
+var accountTransitions = map[AccountStatus][]AccountStatus{
+	AccountActive:  {AccountFrozen, AccountDormant, AccountClosed},
+	AccountFrozen:  {AccountActive, AccountClosed},
+	AccountDormant: {AccountActive, AccountFrozen, AccountClosed},
+}
+
+// CanTransition reports whether an account may move from s to to.
+func (s AccountStatus) CanTransition(to AccountStatus) bool {
+	for _, t := range accountTransitions[s] {
+		if t == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CanCredit reports whether money may be paid into an account in status s.
+// Frozen and dormant accounts still accept incoming funds.
+func (s AccountStatus) CanCredit() bool {
+	return s == AccountActive || s == AccountFrozen || s == AccountDormant
+}
+
+// CanDebit reports whether money may leave an account in status s.
+func (s AccountStatus) CanDebit() bool {
+	return s == AccountActive
+}
+
+type AccountKind string
+
+const (
+	AccountPersonal AccountKind = "PERSONAL"
+	// AccountBusiness accounts require a second user's approval for large transfers.
+	AccountBusiness AccountKind = "BUSINESS"
+)
+
+// Available returns the part of the balance that can be spent.
+func (a *Account) Available() int64 {
+	return a.Balance - a.Reserved
 }
 
 type Account struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Name      string    `json:"name"`
-	Balance   int64     `json:"balance"`
-	Currency  string    `json:"currency"`
-	IsActive  bool      `json:"is_active"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID             string        `json:"id"`
+	TenantID       string        `json:"tenant_id"`
+	Number         string        `json:"number"`
+	IBAN           string        `json:"iban"`
+	UserID         string        `json:"user_id"`
+	Name           string        `json:"name"`
+	Kind           AccountKind   `json:"kind"`
+	Balance        int64         `json:"balance"`
+	Reserved       int64         `json:"reserved"` // part of Balance set aside in pots and card holds
+	Currency       string        `json:"currency"`
+	Status         AccountStatus `json:"status"`
+	LastActivityAt time.Time     `json:"last_activity_at"`
+	// AdminFrozen marks a freeze imposed by the bank. The owner cannot
+	// lift it or close the account until an admin unfreezes it.
+	AdminFrozen bool       `json:"admin_frozen,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
 type TransactionType string
@@ -29,13 +230,351 @@ const (
 	Deposit  TransactionType = "DEPOSIT"
 	Withdraw TransactionType = "WITHDRAW"
 	Transfer TransactionType = "TRANSFER"
+	// Closure is the zero-amount closing statement entry of a closed account.
+	Closure TransactionType = "CLOSURE"
+	// PotIn and PotOut move money between an account and one of its pots.
+	// They leave the account Balance unchanged.
+	PotIn  TransactionType = "POT_IN"
+	PotOut TransactionType = "POT_OUT"
 )
 
 type Transaction struct {
 	ID        string                 `json:"id"`
+	TenantID  string                 `json:"tenant_id"`
 	AccountID string                 `json:"account_id"`
 	Type      TransactionType        `json:"type"`
 	Amount    int64                  `json:"amount"`
 	Meta      map[string]interface{} `json:"meta,omitempty"`
 	CreatedAt time.Time              `json:"created_at"`
 }
+
+// ArchivedAccount is a deleted account moved out of the live store once
+// its restore window passed, kept together with its ledger.
+type ArchivedAccount struct {
+	Account      *Account       `json:"account"`
+	Transactions []*Transaction `json:"transactions"`
+	ArchivedAt   time.Time      `json:"archived_at"`
+}
+
+// BalanceSnapshot records an account's balance at the end of a UTC day,
+// so point-in-time balances only replay the transactions after it.
+type BalanceSnapshot struct {
+	AccountID string    `json:"account_id"`
+	Date      string    `json:"date"` // YYYY-MM-DD
+	Balance   int64     `json:"balance"`
+	At        time.Time `json:"at"` // start of the following day
+}
+
+// BalancePoint is a balance at a point in time.
+type BalancePoint struct {
+	At      time.Time `json:"at"`
+	Balance int64     `json:"balance"`
+}
+
+// BalanceUpdate is pushed to stream subscribers when an account balance changes.
+type BalanceUpdate struct {
+	AccountID string    `json:"account_id"`
+	Balance   int64     `json:"balance"`
+	Currency  string    `json:"currency"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AuditEvent records who did what to which entity, and why.
+type AuditEvent struct {
+	ID         string                 `json:"id"`
+	ActorID    string                 `json:"actor_id"`
+	Action     string                 `json:"action"`
+	TargetType string                 `json:"target_type"`
+	TargetID   string                 `json:"target_id"`
+	Reason     string                 `json:"reason,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+type MemberRole string
+
+const (
+	MemberOwner MemberRole = "OWNER"
+	// MemberCoOwner may move money without a limit and approve transfer
+	// requests, but cannot change the account or its members, so a
+	// co-owner can neither close it and sweep the balance away nor lock
+	// the owner out.
+	MemberCoOwner MemberRole = "CO_OWNER"
+	MemberViewer  MemberRole = "VIEWER"
+	// MemberInitiator may view the account and move money out of it up to
+	// the membership Limit per transaction.
+	MemberInitiator MemberRole = "INITIATOR"
+)
+
+type MemberStatus string
+
+const (
+	MemberInvited MemberStatus = "INVITED"
+	MemberActive  MemberStatus = "ACTIVE"
+	MemberRevoked MemberStatus = "REVOKED"
+)
+
+// AccountMember grants a user access to an account.
+type AccountMember struct {
+	AccountID string       `json:"account_id"`
+	UserID    string       `json:"user_id"`
+	Role      MemberRole   `json:"role"`
+	Limit     int64        `json:"limit,omitempty"`
+	Status    MemberStatus `json:"status"`
+	InvitedBy string       `json:"invited_by,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// Beneficiary is a saved transfer destination (payee) of a user.
+type Beneficiary struct {
+	ID              string    `json:"id"`
+	UserID          string    `json:"user_id"`
+	AccountID       string    `json:"account_id"`
+	Nickname        string    `json:"nickname"`
+	OwnerName       string    `json:"owner_name"` // masked, e.g. "J*** D***"
+	Currency        string    `json:"currency"`
+	CoolingOffUntil time.Time `json:"cooling_off_until"`
+	// CoolingOffLimit caps the total the user may send to the account
+	// until CoolingOffUntil (minor units).
+	CoolingOffLimit int64 `json:"cooling_off_limit"`
+	// SentDuringCoolingOff is what the user has sent the account since it
+	// was saved, counted until CoolingOffUntil (minor units).
+	SentDuringCoolingOff int64     `json:"sent_during_cooling_off"`
+	CreatedAt            time.Time `json:"created_at"`
+	// DeletedAt is set when a payee still cooling off is removed. It is
+	// kept until CoolingOffUntil so deleting it does not lift the limit.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type TransferRequestStatus string
+
+const (
+	TransferPending   TransferRequestStatus = "PENDING"
+	TransferApproved  TransferRequestStatus = "APPROVED" // approved, being executed
+	TransferExecuted  TransferRequestStatus = "EXECUTED"
+	TransferRejected  TransferRequestStatus = "REJECTED"
+	TransferWithdrawn TransferRequestStatus = "WITHDRAWN" // rejected by its maker
+	TransferExpired   TransferRequestStatus = "EXPIRED"
+	TransferFailed    TransferRequestStatus = "FAILED"
+)
+
+// ApprovalStep is one entry of a transfer request's approval trail.
+type ApprovalStep struct {
+	ActorID string                `json:"actor_id"`
+	Status  TransferRequestStatus `json:"status"`
+	Note    string                `json:"note,omitempty"`
+	At      time.Time             `json:"at"`
+}
+
+// TransferRequest is a transfer waiting for a second user's approval.
+type TransferRequest struct {
+	ID            string                 `json:"id"`
+	FromAccountID string                 `json:"from_account_id"`
+	ToAccountID   string                 `json:"to_account_id"`
+	Amount        int64                  `json:"amount"`
+	Meta          map[string]interface{} `json:"meta,omitempty"`
+	Status        TransferRequestStatus  `json:"status"`
+	MakerID       string                 `json:"maker_id"`
+	CheckerID     string                 `json:"checker_id,omitempty"`
+	Trail         []ApprovalStep         `json:"trail"`
+	WithdrawTxnID string                 `json:"withdraw_txn_id,omitempty"`
+	DepositTxnID  string                 `json:"deposit_txn_id,omitempty"`
+	Error         string                 `json:"error,omitempty"`
+	ExpiresAt     time.Time              `json:"expires_at"`
+	CreatedAt     time.Time              `json:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+}
+
+type PaymentRequestStatus string
+
+const (
+	PaymentRequestOpen       PaymentRequestStatus = "OPEN"
+	PaymentRequestProcessing PaymentRequestStatus = "PROCESSING"
+	PaymentRequestPaid       PaymentRequestStatus = "PAID"
+	PaymentRequestDeclined   PaymentRequestStatus = "DECLINED"
+	PaymentRequestCancelled  PaymentRequestStatus = "CANCELLED"
+	PaymentRequestExpired    PaymentRequestStatus = "EXPIRED"
+)
+
+// PaymentRequest asks a payer (or, if PayerID is empty, anyone holding the
+// pay link) to send money into the requester's account.
+type PaymentRequest struct {
+	ID          string               `json:"id"`
+	TenantID    string               `json:"tenant_id"`
+	RequesterID string               `json:"requester_id"`
+	ToAccountID string               `json:"to_account_id"`
+	PayerID     string               `json:"payer_id,omitempty"`
+	Amount      int64                `json:"amount"`
+	Currency    string               `json:"currency"`
+	Note        string               `json:"note,omitempty"`
+	Status      PaymentRequestStatus `json:"status"`
+	TokenHash   string               `json:"-"`
+	PaidBy      string               `json:"paid_by,omitempty"`
+	PaidTxnID   string               `json:"paid_txn_id,omitempty"`
+	ExpiresAt   time.Time            `json:"expires_at"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+// Pot ring-fences part of an account's balance, e.g. for savings goals.
+type Pot struct {
+	ID         string     `json:"id"`
+	AccountID  string     `json:"account_id"`
+	Name       string     `json:"name"`
+	Balance    int64      `json:"balance"`
+	GoalAmount int64      `json:"goal_amount,omitempty"`
+	TargetDate *time.Time `json:"target_date,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type CardStatus string
+
+const (
+	CardActive    CardStatus = "ACTIVE"
+	CardFrozen    CardStatus = "FROZEN"
+	CardCancelled CardStatus = "CANCELLED"
+)
+
+// Card is a virtual payment card drawing on an account. The full number
+// and CVV are shown once at issuance; only their hashes are kept.
+type Card struct {
+	ID        string     `json:"id"`
+	TenantID  string     `json:"tenant_id"`
+	AccountID string     `json:"account_id"`
+	HolderID  string     `json:"holder_id"`
+	MaskedPAN string     `json:"masked_pan"`
+	PANHash   string     `json:"-"`
+	CVVHash   string     `json:"-"`
+	ExpMonth  int        `json:"exp_month"`
+	ExpYear   int        `json:"exp_year"`
+	Status    CardStatus `json:"status"`
+	// TransactionLimit and DailyLimit cap a single authorization and the
+	// authorizations of a rolling 24 hours; 0 means no limit.
+	TransactionLimit int64 `json:"transaction_limit,omitempty"`
+	DailyLimit       int64 `json:"daily_limit,omitempty"`
+	// AllowedMCCs, when set, is the only merchant category codes the card
+	// may be used at; BlockedMCCs are always refused.
+	AllowedMCCs []string `json:"allowed_mccs,omitempty"`
+	BlockedMCCs []string `json:"blocked_mccs,omitempty"`
+	// CVVFailures counts wrong CVVs since the last right one.
+	CVVFailures int       `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type CardAuthorizationStatus string
+
+const (
+	AuthorizationApproved CardAuthorizationStatus = "AUTHORIZED"
+	AuthorizationDeclined CardAuthorizationStatus = "DECLINED"
+	AuthorizationSettled  CardAuthorizationStatus = "SETTLED"
+	AuthorizationReleased CardAuthorizationStatus = "RELEASED"
+	AuthorizationExpired  CardAuthorizationStatus = "EXPIRED"
+)
+
+// CardAuthorization is a card payment attempt. An approved one holds
+// Amount on the account (counted in Reserved) until it is settled into a
+// transaction, released or expires.
+type CardAuthorization struct {
+	ID            string                  `json:"id"`
+	TenantID      string                  `json:"tenant_id"`
+	CardID        string                  `json:"card_id"`
+	AccountID     string                  `json:"account_id"`
+	Amount        int64                   `json:"amount"`
+	Currency      string                  `json:"currency"`
+	Merchant      string                  `json:"merchant,omitempty"`
+	MCC           string                  `json:"mcc,omitempty"`
+	Status        CardAuthorizationStatus `json:"status"`
+	DeclineReason string                  `json:"decline_reason,omitempty"`
+	SettledAmount int64                   `json:"settled_amount,omitempty"`
+	TransactionID string                  `json:"transaction_id,omitempty"`
+	ExpiresAt     time.Time               `json:"expires_at"`
+	CreatedAt     time.Time               `json:"created_at"`
+	UpdatedAt     time.Time               `json:"updated_at"`
+}
+
+// ExternalRecord is a line of a statement from an outside party (e.g. a
+// settlement partner) imported for reconciliation. Amount is signed:
+// positive for money into the account.
+type ExternalRecord struct {
+	ID            string     `json:"id"`
+	AccountID     string     `json:"account_id"`
+	ImportID      string     `json:"import_id"`
+	Source        string     `json:"source"` // "csv" or "camt053"
+	Date          time.Time  `json:"date"`
+	Amount        int64      `json:"amount"`
+	Currency      string     `json:"currency"`
+	Reference     string     `json:"reference,omitempty"`
+	Description   string     `json:"description,omitempty"`
+	TransactionID string     `json:"transaction_id,omitempty"`
+	MatchedBy     string     `json:"matched_by,omitempty"` // "auto" or the user who matched it
+	MatchedAt     *time.Time `json:"matched_at,omitempty"`
+	// RejectedTxnIDs are transactions a user unmatched from this record;
+	// auto-matching does not pair them again.
+	RejectedTxnIDs []string  `json:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Session is a login. Each refresh rotates its refresh token; the access
+// tokens issued along the way are remembered so the whole session can be
+// revoked at once.
+type Session struct {
+	ID         string     `json:"id"`
+	TenantID   string     `json:"tenant_id"`
+	UserID     string     `json:"user_id"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	// ClientID is set for sessions an OAuth client holds on the user's
+	// behalf; their tokens only carry the Scopes the user consented to.
+	ClientID string  `json:"client_id,omitempty"`
+	Scopes   []Scope `json:"scopes,omitempty"`
+	// AccessTokens maps the jti of each unexpired access token to its
+	// expiry.
+	AccessTokens map[string]time.Time `json:"-"`
+}
+
+// OAuthClient is a third-party app registered to act on customers'
+// behalf. Public clients, such as mobile apps, cannot keep a secret and
+// authenticate with their ID and PKCE alone.
+type OAuthClient struct {
+	ID           string     `json:"client_id"`
+	TenantID     string     `json:"tenant_id"`
+	Name         string     `json:"name"`
+	SecretHash   string     `json:"-"`
+	Public       bool       `json:"public"`
+	RedirectURIs []string   `json:"redirect_uris"`
+	Scopes       []Scope    `json:"scopes"` // the most the client may ask for
+	CreatedBy    string     `json:"created_by"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// OAuthCode is an authorization code handed to a client's redirect URI
+// after the user consented. It is exchanged once, with the PKCE verifier
+// matching CodeChallenge, for a session's tokens.
+type OAuthCode struct {
+	CodeHash      string     `json:"-"`
+	TenantID      string     `json:"tenant_id"`
+	ClientID      string     `json:"client_id"`
+	UserID        string     `json:"user_id"`
+	RedirectURI   string     `json:"redirect_uri"`
+	Scopes        []Scope    `json:"scopes"`
+	CodeChallenge string     `json:"-"` // base64url SHA-256 of the verifier
+	SessionID     string     `json:"session_id,omitempty"`
+	UsedAt        *time.Time `json:"used_at,omitempty"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+}
+
+// RefreshToken is one link in a session's refresh token chain. Only its
+// hash is stored. A token that is presented again after being used marks
+// the session as compromised.
+type RefreshToken struct {
+	TokenHash string     `json:"-"`
+	SessionID string     `json:"session_id"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
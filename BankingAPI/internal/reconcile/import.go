@@ -0,0 +1,113 @@
+// Package reconcile parses external statements and matches their lines
+// against the ledger.
+package reconcile
+
+import (
+	"BankingAPI/internal/iso20022"
+	"BankingAPI/internal/model"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Parse reads external records from a CSV file or a camt.053 document,
+// telling them apart by the leading "<" of XML. currency is used for CSV
+// rows without a currency column.
+func Parse(data []byte, currency string) ([]*model.ExternalRecord, string, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+		recs, err := parseCamt053(data)
+		return recs, "camt053", err
+	}
+	recs, err := parseCSV(data, currency)
+	return recs, "csv", err
+}
+
+func parseCamt053(data []byte) ([]*model.ExternalRecord, error) {
+	st, err := iso20022.ParseCamt053(data)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*model.ExternalRecord, 0, len(st.Entries))
+	for _, e := range st.Entries {
+		ref := e.EndToEndID
+		if ref == "" {
+			ref = e.Reference
+		}
+		out = append(out, &model.ExternalRecord{
+			Date:        e.BookedAt,
+			Amount:      e.Amount,
+			Currency:    st.Currency,
+			Reference:   ref,
+			Description: e.Info,
+		})
+	}
+	return out, nil
+}
+
+// parseCSV reads a CSV with a header row. The date and amount columns are
+// required; currency, reference and description are optional. Amounts
+// are signed decimals ("-12.50"), dates YYYY-MM-DD or RFC 3339.
+func parseCSV(data []byte, currency string) ([]*model.ExternalRecord, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, errors.New("missing CSV header")
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, required := range []string{"date", "amount"} {
+		if _, ok := col[required]; !ok {
+			return nil, fmt.Errorf("CSV header has no %q column", required)
+		}
+	}
+	field := func(row []string, name string) string {
+		if i, ok := col[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	out := []*model.ExternalRecord{}
+	for line := 2; ; line++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rec := &model.ExternalRecord{
+			Currency:    currency,
+			Reference:   field(row, "reference"),
+			Description: field(row, "description"),
+		}
+		if c := field(row, "currency"); c != "" {
+			rec.Currency = strings.ToUpper(c)
+		}
+		if rec.Date, err = parseDate(field(row, "date")); err != nil {
+			return nil, fmt.Errorf("line %d: invalid date", line)
+		}
+		amount := field(row, "amount")
+		negative := strings.HasPrefix(amount, "-")
+		if rec.Amount, err = iso20022.ParseAmount(strings.TrimPrefix(strings.TrimPrefix(amount, "-"), "+"), rec.Currency); err != nil {
+			return nil, fmt.Errorf("line %d: invalid amount", line)
+		}
+		if negative {
+			rec.Amount = -rec.Amount
+		}
+		out = append(out, rec)
+	}
+}
+
+func parseDate(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
@@ -0,0 +1,98 @@
+package reconcile
+
+import (
+	"BankingAPI/internal/model"
+	"strings"
+	"time"
+)
+
+// Candidate is a ledger transaction available for matching, with its
+// signed effect on the balance.
+type Candidate struct {
+	Txn    *model.Transaction
+	Amount int64
+}
+
+// references returns the identifiers an external party may quote for t.
+func references(t *model.Transaction) []string {
+	refs := []string{t.ID, strings.ReplaceAll(t.ID, "-", "")}
+	for _, k := range []string{"end_to_end_id", "reference", "payment_request_id", "authorization_id"} {
+		if v, ok := t.Meta[k].(string); ok && v != "" {
+			refs = append(refs, v)
+		}
+	}
+	return refs
+}
+
+func rejected(rec *model.ExternalRecord, txnID string) bool {
+	for _, id := range rec.RejectedTxnIDs {
+		if id == txnID {
+			return true
+		}
+	}
+	return false
+}
+
+func refMatches(rec *model.ExternalRecord, t *model.Transaction) bool {
+	if rec.Reference == "" && rec.Description == "" {
+		return false
+	}
+	for _, ref := range references(t) {
+		if strings.EqualFold(rec.Reference, ref) || (len(ref) >= 8 && strings.Contains(strings.ToLower(rec.Description), strings.ToLower(ref))) {
+			return true
+		}
+	}
+	return false
+}
+
+// tieTolerance is how close two candidates' distances from a record must
+// be for the choice between them to count as ambiguous.
+const tieTolerance = time.Minute
+
+// Match pairs unmatched records with unmatched candidates of the same
+// amount booked within window of the record's date. A candidate whose
+// reference matches wins; otherwise the one closest in time is taken,
+// unless another is about as close, in which case the record is left for
+// a person to decide. Each candidate is used at most once. The result
+// maps record ID to transaction ID.
+func Match(records []*model.ExternalRecord, candidates []Candidate, window time.Duration) map[string]string {
+	used := map[string]bool{}
+	out := map[string]string{}
+	// reference matches first so they are not taken by a date match
+	for pass := 0; pass < 2; pass++ {
+		for _, rec := range records {
+			if _, done := out[rec.ID]; done {
+				continue
+			}
+			var best *model.Transaction
+			bestGap, secondGap := time.Duration(-1), time.Duration(-1)
+			for _, c := range candidates {
+				if used[c.Txn.ID] || c.Amount != rec.Amount || rejected(rec, c.Txn.ID) {
+					continue
+				}
+				gap := c.Txn.CreatedAt.Sub(rec.Date)
+				if gap < 0 {
+					gap = -gap
+				}
+				if gap > window {
+					continue
+				}
+				if pass == 0 && !refMatches(rec, c.Txn) {
+					continue
+				}
+				switch {
+				case best == nil || gap < bestGap:
+					secondGap = bestGap
+					best, bestGap = c.Txn, gap
+				case secondGap < 0 || gap < secondGap:
+					secondGap = gap
+				}
+			}
+			if best != nil && (secondGap < 0 || secondGap-bestGap >= tieTolerance) {
+				out[rec.ID] = best.ID
+				used[best.ID] = true
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,214 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAdminFrozen = errors.New("account is frozen by the bank")
+	ErrUserErased  = errors.New("user has been erased")
+	ErrSelfAdmin   = errors.New("admins cannot deactivate themselves or drop their own admin role")
+)
+
+// SearchUsers returns up to limit of the tenant's users whose ID equals
+// query or whose email or name contains it, ignoring case. An empty
+// query matches everyone.
+func (r *Repo) SearchUsers(ctx context.Context, query string, limit int) []*model.User {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	tenantID := TenantFrom(ctx)
+	q := strings.ToLower(strings.TrimSpace(query))
+	out := []*model.User{}
+	for _, u := range r.store.Users {
+		if u.TenantID != tenantID {
+			continue
+		}
+		if q == "" || u.ID == q || strings.Contains(strings.ToLower(u.Email), q) || strings.Contains(strings.ToLower(u.Name), q) {
+			out = append(out, u)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// SetUserActive deactivates or reactivates a user. Deactivation signs the
+// user out everywhere; they cannot log in again until reactivated.
+func (r *Repo) SetUserActive(ctx context.Context, userID string, active bool, actorID, reason string) (*model.User, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	u, ok := r.userLocked(ctx, userID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if u.ErasedAt != nil {
+		return nil, ErrUserErased
+	}
+	if !active && userID == actorID {
+		return nil, ErrSelfAdmin
+	}
+	if u.IsActive == active {
+		return u, nil
+	}
+	now := time.Now()
+	u.IsActive = active
+	u.UpdatedAt = now
+	action := "user.activated"
+	if !active {
+		action = "user.deactivated"
+		r.revokeUserSessionsLocked(u.ID, now)
+	}
+	r.auditLocked(actorID, action, "user", u.ID, reason, nil)
+	return u, nil
+}
+
+// SetUserRoles replaces a user's roles. Removing a role revokes the
+// user's sessions so tokens carrying it stop working; added roles apply
+// from the next token refresh.
+func (r *Repo) SetUserRoles(ctx context.Context, userID string, roles []model.Role, actorID string) (*model.User, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	u, ok := r.userLocked(ctx, userID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if u.ErasedAt != nil {
+		return nil, ErrUserErased
+	}
+	next := &model.User{}
+	for _, role := range roles {
+		if !next.HasRole(role) {
+			next.Roles = append(next.Roles, role)
+		}
+	}
+	if userID == actorID && u.HasRole(model.RoleAdmin) && !next.HasRole(model.RoleAdmin) {
+		return nil, ErrSelfAdmin
+	}
+	removed := false
+	for _, role := range u.Roles {
+		if !next.HasRole(role) {
+			removed = true
+		}
+	}
+	now := time.Now()
+	from := u.Roles
+	u.Roles = next.Roles
+	u.UpdatedAt = now
+	if removed {
+		r.revokeUserSessionsLocked(u.ID, now)
+	}
+	r.auditLocked(actorID, "user.roles_changed", "user", u.ID, "", map[string]interface{}{
+		"from": from,
+		"to":   u.Roles,
+	})
+	return u, nil
+}
+
+// grantBootstrapAdminLocked makes u an admin if its tenant lists u's
+// email among its AdminEmails. It is called once the address is verified.
+func (r *Repo) grantBootstrapAdminLocked(u *model.User) {
+	t, ok := r.store.Tenants[u.TenantID]
+	if !ok || u.HasRole(model.RoleAdmin) {
+		return
+	}
+	for _, email := range t.AdminEmails {
+		if strings.EqualFold(email, u.Email) {
+			u.Roles = append(u.Roles, model.RoleAdmin)
+			r.auditLocked(SystemActor, "user.roles_changed", "user", u.ID, "configured admin email verified", map[string]interface{}{
+				"to": u.Roles,
+			})
+			return
+		}
+	}
+}
+
+// FreezeAccount freezes an account on the bank's behalf. Unlike a freeze
+// by the owner it can only be lifted with UnfreezeAccount.
+func (r *Repo) FreezeAccount(ctx context.Context, id, actorID, reason string) (*model.Account, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	a, ok := r.accountLocked(ctx, id)
+	if !ok || a.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	if a.AdminFrozen {
+		return a, nil
+	}
+	if a.Status != model.AccountFrozen {
+		if err := r.setStatusLocked(a, model.AccountFrozen, actorID, reason); err != nil {
+			return nil, err
+		}
+	}
+	a.AdminFrozen = true
+	r.auditLocked(actorID, "account.admin_frozen", "account", a.ID, reason, nil)
+	return a, nil
+}
+
+// UnfreezeAccount lifts a freeze, whether the bank or the owner imposed
+// it, and reactivates the account.
+func (r *Repo) UnfreezeAccount(ctx context.Context, id, actorID, reason string) (*model.Account, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	a, ok := r.accountLocked(ctx, id)
+	if !ok || a.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	if a.Status != model.AccountFrozen {
+		return nil, ErrInvalidStatus
+	}
+	wasAdmin := a.AdminFrozen
+	a.AdminFrozen = false
+	if err := r.setStatusLocked(a, model.AccountActive, actorID, reason); err != nil {
+		a.AdminFrozen = wasAdmin
+		return nil, err
+	}
+	if wasAdmin {
+		r.auditLocked(actorID, "account.admin_unfrozen", "account", a.ID, reason, nil)
+	}
+	return a, nil
+}
+
+// AdjustBalance books a manual correction of amount (negative to debit)
+// on an account. Corrections go through even on frozen or dormant
+// accounts, but may not take the balance below what is reserved, and do
+// not count as customer activity.
+func (r *Repo) AdjustBalance(ctx context.Context, id string, amount int64, actorID, reason string) (*model.Transaction, error) {
+	if amount == 0 {
+		return nil, errors.New("amount must not be zero")
+	}
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	a, ok := r.accountLocked(ctx, id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := creditErr(a); err != nil {
+		return nil, err
+	}
+	meta := map[string]interface{}{"adjustment": true, "reason": reason, "admin_id": actorID}
+	var t *model.Transaction
+	if amount > 0 {
+		t = newTransaction(a, model.Deposit, amount, meta)
+	} else {
+		if a.Available() < -amount {
+			return nil, ErrInsufficient
+		}
+		t = newTransaction(a, model.Withdraw, -amount, meta)
+	}
+	a.Balance += amount
+	a.UpdatedAt = time.Now()
+	r.store.Transactions[t.ID] = t
+	r.auditLocked(actorID, "account.adjusted", "account", a.ID, reason, map[string]interface{}{
+		"amount":         amount,
+		"transaction_id": t.ID,
+	})
+	r.notifyLocked(a, t)
+	return t, nil
+}
@@ -0,0 +1,160 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix starts every API key, so Auth can tell keys from JWTs.
+const APIKeyPrefix = "bk_"
+
+var (
+	ErrInvalidAPIKey    = errors.New("invalid API key")
+	ErrAPIKeyIPRefused  = errors.New("API key not allowed from this address")
+	ErrInvalidAllowedIP = errors.New("allowed_ips must be IP addresses or CIDR ranges")
+)
+
+// CreateAPIKey issues a key for userID and returns it with the key
+// itself, which is not stored and cannot be shown again.
+func (r *Repo) CreateAPIKey(ctx context.Context, userID, name string, scopes []model.Scope, allowedIPs []string, expiresAt *time.Time) (*model.APIKey, string, error) {
+	for _, ip := range allowedIPs {
+		if _, err := parseAllowedIP(ip); err != nil {
+			return nil, "", ErrInvalidAllowedIP
+		}
+	}
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	u, ok := r.userLocked(ctx, userID)
+	if !ok || !u.IsActive {
+		return nil, "", ErrNotFound
+	}
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", err
+	}
+	secret, _ := newOpaqueToken()
+	prefix := APIKeyPrefix + hex.EncodeToString(id)
+	key := prefix + "_" + secret
+	k := &model.APIKey{
+		ID:         uuid.NewString(),
+		TenantID:   u.TenantID,
+		UserID:     u.ID,
+		Name:       name,
+		Prefix:     prefix,
+		KeyHash:    HashToken(key),
+		Scopes:     scopes,
+		AllowedIPs: allowedIPs,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  time.Now(),
+	}
+	r.store.APIKeys[k.KeyHash] = k
+	r.auditLocked(userID, "api_key.created", "user", userID, "", map[string]interface{}{"api_key_id": k.ID, "prefix": prefix, "scopes": scopes})
+	return k, key, nil
+}
+
+// ListAPIKeys returns the user's keys, newest first, including revoked
+// and expired ones.
+func (r *Repo) ListAPIKeys(ctx context.Context, userID string) []*model.APIKey {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	tenantID := TenantFrom(ctx)
+	out := []*model.APIKey{}
+	for _, k := range r.store.APIKeys {
+		if k.UserID == userID && k.TenantID == tenantID {
+			out = append(out, k)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
+
+// RevokeAPIKey stops one of the user's keys from working.
+func (r *Repo) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	tenantID := TenantFrom(ctx)
+	for _, k := range r.store.APIKeys {
+		if k.ID != id || k.UserID != userID || k.TenantID != tenantID {
+			continue
+		}
+		if k.RevokedAt == nil {
+			now := time.Now()
+			k.RevokedAt = &now
+			r.auditLocked(userID, "api_key.revoked", "user", userID, "", map[string]interface{}{"api_key_id": k.ID, "prefix": k.Prefix})
+		}
+		return nil
+	}
+	return ErrNotFound
+}
+
+// AuthenticateAPIKey returns the key matching key when it may be used
+// from ip now, and records the use.
+func (r *Repo) AuthenticateAPIKey(key, ip string) (*model.APIKey, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	k, ok := r.store.APIKeys[HashToken(key)]
+	if !ok || k.RevokedAt != nil || !strings.HasPrefix(key, k.Prefix+"_") {
+		return nil, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if k.ExpiresAt != nil && now.After(*k.ExpiresAt) {
+		return nil, ErrInvalidAPIKey
+	}
+	if u, ok := r.store.Users[k.UserID]; !ok || !u.IsActive {
+		return nil, ErrInvalidAPIKey
+	}
+	if !ipAllowed(k.AllowedIPs, ip) {
+		return nil, ErrAPIKeyIPRefused
+	}
+	k.LastUsedAt = &now
+	return k, nil
+}
+
+// revokeUserAPIKeysLocked revokes every key of a user.
+func (r *Repo) revokeUserAPIKeysLocked(userID string, now time.Time) {
+	for _, k := range r.store.APIKeys {
+		if k.UserID == userID && k.RevokedAt == nil {
+			k.RevokedAt = &now
+		}
+	}
+}
+
+// parseAllowedIP reads an allowlist entry, a single address being a
+// range of one.
+func parseAllowedIP(v string) (netip.Prefix, error) {
+	if strings.Contains(v, "/") {
+		p, err := netip.ParsePrefix(v)
+		return p.Masked(), err
+	}
+	a, err := netip.ParseAddr(v)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(a, a.BitLen()), nil
+}
+
+func ipAllowed(allowed []string, ip string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, v := range allowed {
+		if p, err := parseAllowedIP(v); err == nil && p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
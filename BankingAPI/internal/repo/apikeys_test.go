@@ -0,0 +1,99 @@
+package repo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	tests := []struct {
+		name       string
+		allowedIPs []string
+		expiresAt  *time.Time
+		revoke     bool
+		deactivate bool
+		tamper     bool // the secret part of the key is changed
+		ip         string
+		wantErr    error
+	}{
+		{name: "valid", ip: "203.0.113.7"},
+		{name: "not expired yet", expiresAt: &future, ip: "203.0.113.7"},
+		{name: "expired", expiresAt: &past, ip: "203.0.113.7", wantErr: ErrInvalidAPIKey},
+		{name: "revoked", revoke: true, ip: "203.0.113.7", wantErr: ErrInvalidAPIKey},
+		{name: "inactive owner", deactivate: true, ip: "203.0.113.7", wantErr: ErrInvalidAPIKey},
+		{name: "wrong secret", tamper: true, ip: "203.0.113.7", wantErr: ErrInvalidAPIKey},
+		{name: "single address allowed", allowedIPs: []string{"203.0.113.7"}, ip: "203.0.113.7"},
+		{name: "single address refused", allowedIPs: []string{"203.0.113.7"}, ip: "203.0.113.8", wantErr: ErrAPIKeyIPRefused},
+		{name: "inside CIDR", allowedIPs: []string{"10.0.0.0/8"}, ip: "10.20.30.40"},
+		{name: "outside CIDR", allowedIPs: []string{"10.0.0.0/8"}, ip: "11.0.0.1", wantErr: ErrAPIKeyIPRefused},
+		{name: "unmasked CIDR", allowedIPs: []string{"192.168.1.77/24"}, ip: "192.168.1.3"},
+		{name: "IPv4-mapped client inside CIDR", allowedIPs: []string{"10.0.0.0/8"}, ip: "::ffff:10.1.2.3"},
+		{name: "IPv4-mapped client outside CIDR", allowedIPs: []string{"10.0.0.0/8"}, ip: "::ffff:11.1.2.3", wantErr: ErrAPIKeyIPRefused},
+		{name: "IPv6 CIDR", allowedIPs: []string{"2001:db8::/32"}, ip: "2001:db8::1"},
+		{name: "IPv4 client against IPv6 CIDR", allowedIPs: []string{"2001:db8::/32"}, ip: "10.1.2.3", wantErr: ErrAPIKeyIPRefused},
+		{name: "second entry matches", allowedIPs: []string{"10.0.0.0/8", "203.0.113.0/24"}, ip: "203.0.113.7"},
+		{name: "unparseable client address", allowedIPs: []string{"10.0.0.0/8"}, ip: "unknown", wantErr: ErrAPIKeyIPRefused},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ctx := newTestRepo(t)
+			u := newTestUser(t, r, ctx, "a@example.com")
+			k, key, err := r.CreateAPIKey(ctx, u.ID, "ci", nil, tt.allowedIPs, tt.expiresAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.revoke {
+				if err := r.RevokeAPIKey(ctx, u.ID, k.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.deactivate {
+				if _, err := r.SetUserActive(ctx, u.ID, false, "admin", ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.tamper {
+				last := "A"
+				if strings.HasSuffix(key, last) {
+					last = "B"
+				}
+				key = key[:len(key)-1] + last
+			}
+			got, err := r.AuthenticateAPIKey(key, tt.ip)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AuthenticateAPIKey = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.ID != k.ID || got.LastUsedAt == nil) {
+				t.Errorf("AuthenticateAPIKey = %+v, want key %s with its use recorded", got, k.ID)
+			}
+		})
+	}
+}
+
+func TestCreateAPIKeyAllowedIPs(t *testing.T) {
+	r, ctx := newTestRepo(t)
+	u := newTestUser(t, r, ctx, "a@example.com")
+	for _, ips := range [][]string{{"10.0.0.0/33"}, {"example.com"}, {"10.0.0.1", ""}} {
+		if _, _, err := r.CreateAPIKey(ctx, u.ID, "ci", nil, ips, nil); !errors.Is(err, ErrInvalidAllowedIP) {
+			t.Errorf("CreateAPIKey(%q) = %v, want %v", ips, err, ErrInvalidAllowedIP)
+		}
+	}
+}
+
+func TestPasswordChangeRevokesAPIKeys(t *testing.T) {
+	r, ctx := newTestRepo(t)
+	u := newTestUser(t, r, ctx, "a@example.com")
+	_, key, err := r.CreateAPIKey(ctx, u.ID, "ci", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ChangePassword(ctx, u.ID, "new"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.AuthenticateAPIKey(key, "203.0.113.7"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("AuthenticateAPIKey after a password change = %v, want %v", err, ErrInvalidAPIKey)
+	}
+}
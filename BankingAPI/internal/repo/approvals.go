@@ -0,0 +1,221 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSelfApproval     = errors.New("the requester cannot approve their own transfer")
+	ErrRequestExpired   = errors.New("transfer request expired")
+	ErrRequestSettled   = errors.New("transfer request is no longer pending")
+	ErrRequestNotFound  = errors.New("transfer request not found")
+	ErrApprovalRequired = errors.New("amount requires a second user's approval; use a transfer instead")
+)
+
+// canApprove reports whether a membership may approve or reject transfers.
+func canApprove(m *model.AccountMember) bool {
+	return m != nil && m.Status == model.MemberActive && (m.Role == model.MemberOwner || m.Role == model.MemberCoOwner)
+}
+
+// NeedsApproval reports whether a transfer of amount from a must go
+// through a transfer request instead of executing immediately.
+func NeedsApproval(a *model.Account, amount, threshold int64) bool {
+	return a.Kind == model.AccountBusiness && amount > threshold
+}
+
+// CreateTransferRequest records a transfer that waits for approval.
+func (r *Repo) CreateTransferRequest(ctx context.Context, makerID, fromID, toID string, amount int64, meta map[string]interface{}, ttl time.Duration) (*model.TransferRequest, error) {
+	if amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	if _, ok := r.accountLocked(ctx, fromID); !ok {
+		return nil, ErrNotFound
+	}
+	if _, ok := r.accountLocked(ctx, toID); !ok {
+		return nil, ErrNotFound
+	}
+	now := time.Now()
+	tr := &model.TransferRequest{
+		ID:            uuid.NewString(),
+		FromAccountID: fromID,
+		ToAccountID:   toID,
+		Amount:        amount,
+		Meta:          meta,
+		Status:        model.TransferPending,
+		MakerID:       makerID,
+		Trail:         []model.ApprovalStep{{ActorID: makerID, Status: model.TransferPending, At: now}},
+		ExpiresAt:     now.Add(ttl),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	r.store.TransferRequests[tr.ID] = tr
+	return tr, nil
+}
+
+// transferRequestLocked returns request id if its account belongs to the
+// tenant of ctx.
+func (r *Repo) transferRequestLocked(ctx context.Context, id string) (*model.TransferRequest, bool) {
+	tr, ok := r.store.TransferRequests[id]
+	if !ok {
+		return nil, false
+	}
+	if _, ok := r.accountLocked(ctx, tr.FromAccountID); !ok {
+		return nil, false
+	}
+	return tr, true
+}
+
+// GetTransferRequest returns a request visible to userID, i.e. one on an
+// account the user is an active member of.
+func (r *Repo) GetTransferRequest(ctx context.Context, id, userID string) (*model.TransferRequest, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	tr, ok := r.transferRequestLocked(ctx, id)
+	if !ok {
+		return nil, ErrRequestNotFound
+	}
+	if err := allows(r.store.Members[tr.FromAccountID][userID], PermView, 0); err != nil {
+		return nil, ErrRequestNotFound
+	}
+	return tr, nil
+}
+
+// ListTransferRequests returns the requests on accounts userID can see,
+// optionally filtered by status.
+func (r *Repo) ListTransferRequests(ctx context.Context, userID string, status model.TransferRequestStatus) ([]*model.TransferRequest, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	out := []*model.TransferRequest{}
+	for _, tr := range r.store.TransferRequests {
+		if _, ok := r.accountLocked(ctx, tr.FromAccountID); !ok {
+			continue
+		}
+		if allows(r.store.Members[tr.FromAccountID][userID], PermView, 0) != nil {
+			continue
+		}
+		if status != "" && tr.Status != status {
+			continue
+		}
+		out = append(out, tr)
+	}
+	return out, nil
+}
+
+// checkPendingLocked validates that tr can still be decided on.
+func (r *Repo) checkPendingLocked(tr *model.TransferRequest) error {
+	if tr.Status != model.TransferPending {
+		return ErrRequestSettled
+	}
+	if time.Now().After(tr.ExpiresAt) {
+		r.settleRequestLocked(tr, model.TransferExpired, SystemActor, "")
+		return ErrRequestExpired
+	}
+	return nil
+}
+
+// checkDecisionLocked validates that checkerID may decide on tr now.
+func (r *Repo) checkDecisionLocked(tr *model.TransferRequest, checkerID string) error {
+	if err := r.checkPendingLocked(tr); err != nil {
+		return err
+	}
+	if !canApprove(r.store.Members[tr.FromAccountID][checkerID]) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func (r *Repo) settleRequestLocked(tr *model.TransferRequest, status model.TransferRequestStatus, actorID, note string) {
+	now := time.Now()
+	tr.Status = status
+	tr.UpdatedAt = now
+	tr.Trail = append(tr.Trail, model.ApprovalStep{ActorID: actorID, Status: status, Note: note, At: now})
+}
+
+// ApproveTransferRequest approves a pending request and executes it
+// through Transfer, so the balance is validated at approval time. A
+// request the transfer rejects ends up FAILED with the reason recorded.
+func (r *Repo) ApproveTransferRequest(ctx context.Context, id, checkerID, note string) (*model.TransferRequest, error) {
+	r.store.Mu.Lock()
+	tr, ok := r.transferRequestLocked(ctx, id)
+	if !ok {
+		r.store.Mu.Unlock()
+		return nil, ErrRequestNotFound
+	}
+	if err := r.checkDecisionLocked(tr, checkerID); err != nil {
+		r.store.Mu.Unlock()
+		return nil, err
+	}
+	if checkerID == tr.MakerID {
+		r.store.Mu.Unlock()
+		return nil, ErrSelfApproval
+	}
+	// moving out of PENDING first stops a concurrent approval from
+	// executing the same request twice
+	tr.CheckerID = checkerID
+	r.settleRequestLocked(tr, model.TransferApproved, checkerID, note)
+	r.store.Mu.Unlock()
+
+	out, in, err := r.Transfer(ctx, tr.MakerID, tr.FromAccountID, tr.ToAccountID, tr.Amount, withRequestMeta(tr))
+
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	if err != nil {
+		tr.Error = err.Error()
+		r.settleRequestLocked(tr, model.TransferFailed, SystemActor, err.Error())
+		return tr, nil
+	}
+	tr.WithdrawTxnID = out.ID
+	tr.DepositTxnID = in.ID
+	r.settleRequestLocked(tr, model.TransferExecuted, SystemActor, "")
+	return tr, nil
+}
+
+// RejectTransferRequest rejects a pending request. The maker may reject
+// their own request whatever their role, which withdraws it.
+func (r *Repo) RejectTransferRequest(ctx context.Context, id, checkerID, note string) (*model.TransferRequest, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	tr, ok := r.transferRequestLocked(ctx, id)
+	if !ok {
+		return nil, ErrRequestNotFound
+	}
+	if checkerID == tr.MakerID && allows(r.store.Members[tr.FromAccountID][checkerID], PermView, 0) == nil {
+		if err := r.checkPendingLocked(tr); err != nil {
+			return nil, err
+		}
+		r.settleRequestLocked(tr, model.TransferWithdrawn, checkerID, note)
+		return tr, nil
+	}
+	if err := r.checkDecisionLocked(tr, checkerID); err != nil {
+		return nil, err
+	}
+	tr.CheckerID = checkerID
+	r.settleRequestLocked(tr, model.TransferRejected, checkerID, note)
+	return tr, nil
+}
+
+// ExpireTransferRequests marks pending requests past their expiry as
+// EXPIRED and returns how many were changed.
+func (r *Repo) ExpireTransferRequests(ctx context.Context, now time.Time) int {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	n := 0
+	for _, tr := range r.store.TransferRequests {
+		if tr.Status == model.TransferPending && now.After(tr.ExpiresAt) {
+			r.settleRequestLocked(tr, model.TransferExpired, SystemActor, "")
+			n++
+		}
+	}
+	return n
+}
+
+func withRequestMeta(tr *model.TransferRequest) map[string]interface{} {
+	return WithMeta(tr.Meta, "transfer_request_id", tr.ID)
+}
@@ -0,0 +1,110 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTransferRequestDecisions(t *testing.T) {
+	roles := map[string]model.MemberRole{
+		"co-owner":  model.MemberCoOwner,
+		"initiator": model.MemberInitiator,
+		"viewer":    model.MemberViewer,
+	}
+	tests := []struct {
+		name       string
+		maker      string
+		checker    string
+		reject     bool
+		wantErr    error
+		wantStatus model.TransferRequestStatus
+	}{
+		{"co-owner approves owner's request", "owner", "co-owner", false, nil, model.TransferExecuted},
+		{"owner approves co-owner's request", "co-owner", "owner", false, nil, model.TransferExecuted},
+		{"owner approves initiator's request", "initiator", "owner", false, nil, model.TransferExecuted},
+		{"owner approves own request", "owner", "owner", false, ErrSelfApproval, model.TransferPending},
+		{"co-owner approves own request", "co-owner", "co-owner", false, ErrSelfApproval, model.TransferPending},
+		{"initiator approves own request", "initiator", "initiator", false, ErrForbidden, model.TransferPending},
+		{"initiator approves owner's request", "owner", "initiator", false, ErrForbidden, model.TransferPending},
+		{"viewer approves", "owner", "viewer", false, ErrForbidden, model.TransferPending},
+		{"outsider approves", "owner", "outsider", false, ErrForbidden, model.TransferPending},
+		{"co-owner rejects owner's request", "owner", "co-owner", true, nil, model.TransferRejected},
+		{"owner withdraws own request", "owner", "owner", true, nil, model.TransferWithdrawn},
+		{"initiator withdraws own request", "initiator", "initiator", true, nil, model.TransferWithdrawn},
+		{"initiator rejects owner's request", "owner", "initiator", true, ErrForbidden, model.TransferPending},
+		{"viewer rejects", "owner", "viewer", true, ErrForbidden, model.TransferPending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ctx := newTestRepo(t)
+			users := map[string]*model.User{}
+			for _, name := range []string{"owner", "co-owner", "initiator", "viewer", "outsider"} {
+				users[name] = newTestUser(t, r, ctx, name+"@example.com")
+			}
+			from := newTestAccount(t, r, ctx, users["owner"].ID, 10000)
+			from.Kind = model.AccountBusiness
+			to := newTestAccount(t, r, ctx, users["outsider"].ID, 0)
+			for name, role := range roles {
+				r.addMemberLocked(&model.AccountMember{AccountID: from.ID, UserID: users[name].ID, Role: role, Status: model.MemberActive, Limit: 10000})
+			}
+
+			tr, err := r.CreateTransferRequest(ctx, users[tt.maker].ID, from.ID, to.ID, 2500, nil, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.reject {
+				_, err = r.RejectTransferRequest(ctx, tr.ID, users[tt.checker].ID, "")
+			} else {
+				_, err = r.ApproveTransferRequest(ctx, tr.ID, users[tt.checker].ID, "")
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tr.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", tr.Status, tt.wantStatus)
+			}
+			wantBalance := int64(10000)
+			if tt.wantStatus == model.TransferExecuted {
+				wantBalance -= 2500
+			}
+			if from.Balance != wantBalance {
+				t.Errorf("balance = %d, want %d", from.Balance, wantBalance)
+			}
+		})
+	}
+}
+
+func TestWithdrawNeedsApproval(t *testing.T) {
+	const threshold = 1000
+	tests := []struct {
+		name    string
+		kind    model.AccountKind
+		amount  int64
+		wantErr error
+	}{
+		{"business at the threshold", model.AccountBusiness, threshold, nil},
+		{"business above the threshold", model.AccountBusiness, threshold + 1, ErrApprovalRequired},
+		{"personal above the threshold", model.AccountPersonal, threshold + 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ctx := newTestRepo(t)
+			u := newTestUser(t, r, ctx, "a@example.com")
+			a := newTestAccount(t, r, ctx, u.ID, 5000)
+			a.Kind = tt.kind
+			_, err := r.Withdraw(ctx, a.ID, tt.amount, threshold, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Withdraw = %v, want %v", err, tt.wantErr)
+			}
+			want := int64(5000)
+			if err == nil {
+				want -= tt.amount
+			}
+			if a.Balance != want {
+				t.Errorf("balance = %d, want %d", a.Balance, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"context"
+	"sort"
+	"time"
+)
+
+// BalanceDelta is how much t changed its account's ledger balance. Pot
+// moves only ring-fence money and closing entries carry no amount.
+func BalanceDelta(t *model.Transaction) int64 {
+	switch t.Type {
+	case model.Deposit:
+		return t.Amount
+	case model.Withdraw:
+		return -t.Amount
+	}
+	return 0
+}
+
+// snapshotBeforeLocked returns the latest snapshot of an account taken at
+// or before at, or nil.
+func (r *Repo) snapshotBeforeLocked(accountID string, at time.Time) *model.BalanceSnapshot {
+	snaps := r.store.BalanceSnapshots[accountID]
+	i := sort.Search(len(snaps), func(i int) bool { return snaps[i].At.After(at) })
+	if i == 0 {
+		return nil
+	}
+	return snaps[i-1]
+}
+
+// balanceAtLocked reconstructs an account's balance including every
+// transaction made at or before at, starting from the closest snapshot.
+func (r *Repo) balanceAtLocked(accountID string, at time.Time) int64 {
+	var balance int64
+	var since time.Time
+	if snap := r.snapshotBeforeLocked(accountID, at); snap != nil {
+		balance, since = snap.Balance, snap.At
+	}
+	for _, t := range r.store.Transactions {
+		if t.AccountID != accountID || t.CreatedAt.After(at) {
+			continue
+		}
+		if !since.IsZero() && t.CreatedAt.Before(since) {
+			continue
+		}
+		balance += BalanceDelta(t)
+	}
+	return balance
+}
+
+// BalanceAt returns an account's balance as of at.
+func (r *Repo) BalanceAt(ctx context.Context, accountID string, at time.Time) (*model.BalancePoint, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	if _, ok := r.accountLocked(ctx, accountID); !ok {
+		return nil, ErrNotFound
+	}
+	return &model.BalancePoint{At: at, Balance: r.balanceAtLocked(accountID, at)}, nil
+}
+
+// BalanceHistory returns the balance at from and then at the end of every
+// interval up to to; next advances a point by one interval.
+func (r *Repo) BalanceHistory(ctx context.Context, accountID string, from, to time.Time, next func(time.Time) time.Time) ([]model.BalancePoint, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	if _, ok := r.accountLocked(ctx, accountID); !ok {
+		return nil, ErrNotFound
+	}
+	txns := []*model.Transaction{}
+	for _, t := range r.store.Transactions {
+		if t.AccountID == accountID && t.CreatedAt.After(from) && !t.CreatedAt.After(to) {
+			txns = append(txns, t)
+		}
+	}
+	sort.Slice(txns, func(i, j int) bool { return txns[i].CreatedAt.Before(txns[j].CreatedAt) })
+
+	balance := r.balanceAtLocked(accountID, from)
+	out := []model.BalancePoint{{At: from, Balance: balance}}
+	i := 0
+	for at := next(from); ; at = next(at) {
+		if at.After(to) {
+			at = to
+		}
+		for ; i < len(txns) && !txns[i].CreatedAt.After(at); i++ {
+			balance += BalanceDelta(txns[i])
+		}
+		out = append(out, model.BalancePoint{At: at, Balance: balance})
+		if !at.Before(to) {
+			return out, nil
+		}
+	}
+}
+
+// SnapshotBalances records end-of-day balances for every day that ended
+// at or before through and has no snapshot yet, and returns how many were
+// written. Days before an account was opened are skipped.
+func (r *Repo) SnapshotBalances(ctx context.Context, through time.Time) int {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	through = through.UTC().Truncate(24 * time.Hour)
+
+	// one pass over the ledger, bucketing deltas by account and day
+	deltas := map[string]map[time.Time]int64{}
+	for _, t := range r.store.Transactions {
+		end := t.CreatedAt.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+		if end.After(through) {
+			continue
+		}
+		if deltas[t.AccountID] == nil {
+			deltas[t.AccountID] = map[time.Time]int64{}
+		}
+		deltas[t.AccountID][end] += BalanceDelta(t)
+	}
+
+	n := 0
+	for id, a := range r.store.Accounts {
+		snaps := r.store.BalanceSnapshots[id]
+		var balance int64
+		day := a.CreatedAt.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+		if len(snaps) > 0 {
+			last := snaps[len(snaps)-1]
+			balance, day = last.Balance, last.At.Add(24*time.Hour)
+		}
+		for ; !day.After(through); day = day.Add(24 * time.Hour) {
+			balance += deltas[id][day]
+			snaps = append(snaps, &model.BalanceSnapshot{
+				AccountID: id,
+				Date:      day.Add(-24 * time.Hour).Format("2006-01-02"),
+				Balance:   balance,
+				At:        day,
+			})
+			n++
+		}
+		r.store.BalanceSnapshots[id] = snaps
+	}
+	return n
+}
+
+// Ledger is an account's booked transactions over a period together with
+// the balances either side of it.
+type Ledger struct {
+	Account      *model.Account
+	Opening      int64
+	Closing      int64
+	Transactions []*model.Transaction // oldest first
+}
+
+// LedgerBetween returns the transactions that changed an account's
+// balance in [from, to).
+func (r *Repo) LedgerBetween(ctx context.Context, accountID string, from, to time.Time) (*Ledger, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	a, ok := r.accountLocked(ctx, accountID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	l := &Ledger{Account: a, Opening: r.balanceAtLocked(accountID, from.Add(-time.Nanosecond)), Transactions: []*model.Transaction{}}
+	for _, t := range r.store.Transactions {
+		if t.AccountID == accountID && !t.CreatedAt.Before(from) && t.CreatedAt.Before(to) && BalanceDelta(t) != 0 {
+			l.Transactions = append(l.Transactions, t)
+		}
+	}
+	sort.Slice(l.Transactions, func(i, j int) bool { return l.Transactions[i].CreatedAt.Before(l.Transactions[j].CreatedAt) })
+	l.Closing = l.Opening
+	for _, t := range l.Transactions {
+		l.Closing += BalanceDelta(t)
+	}
+	return l, nil
+}
@@ -0,0 +1,144 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrDuplicatePayee  = errors.New("beneficiary already saved")
+	ErrCoolingOffLimit = errors.New("new beneficiary: transfers during the cooling-off period would exceed its limit")
+)
+
+// maskName keeps the first letter of every word of a name, e.g.
+// "Jane Doe" becomes "J*** D***".
+func maskName(name string) string {
+	words := strings.Fields(name)
+	for i, w := range words {
+		r := []rune(w)
+		words[i] = string(r[0]) + "***"
+	}
+	return strings.Join(words, " ")
+}
+
+// CreateBeneficiary saves accountID as a payee of userID. Until
+// coolingOff has elapsed, userID may send it at most limit in total.
+// Saving a payee removed during its cooling-off restores it as it was.
+func (r *Repo) CreateBeneficiary(ctx context.Context, userID, accountID, nickname string, coolingOff time.Duration, limit int64) (*model.Beneficiary, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	a, ok := r.accountLocked(ctx, accountID)
+	if !ok || a.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	if a.Status == model.AccountClosed {
+		return nil, ErrAccountClosed
+	}
+	now := time.Now()
+	for id, b := range r.store.Beneficiaries {
+		if b.UserID != userID || b.AccountID != accountID {
+			continue
+		}
+		if b.DeletedAt == nil {
+			return nil, ErrDuplicatePayee
+		}
+		if !now.Before(b.CoolingOffUntil) {
+			delete(r.store.Beneficiaries, id)
+			continue
+		}
+		// saving a removed payee again picks up its cooling-off where it
+		// was left
+		b.DeletedAt = nil
+		b.Nickname = nickname
+		return b, nil
+	}
+	owner := ""
+	if u, ok := r.store.Users[a.UserID]; ok {
+		owner = maskName(u.Name)
+	}
+	b := &model.Beneficiary{
+		ID:              uuid.NewString(),
+		UserID:          userID,
+		AccountID:       accountID,
+		Nickname:        nickname,
+		OwnerName:       owner,
+		Currency:        a.Currency,
+		CoolingOffUntil: now.Add(coolingOff),
+		CoolingOffLimit: limit,
+		CreatedAt:       now,
+	}
+	r.store.Beneficiaries[b.ID] = b
+	return b, nil
+}
+
+// GetBeneficiary returns a payee saved by userID.
+func (r *Repo) GetBeneficiary(ctx context.Context, userID, id string) (*model.Beneficiary, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	b, ok := r.store.Beneficiaries[id]
+	if !ok || b.UserID != userID || b.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	return b, nil
+}
+
+func (r *Repo) ListBeneficiaries(ctx context.Context, userID string) ([]*model.Beneficiary, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	out := []*model.Beneficiary{}
+	for _, b := range r.store.Beneficiaries {
+		if b.UserID == userID && b.DeletedAt == nil {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+// DeleteBeneficiary removes a payee of userID. One still cooling off is
+// only marked deleted until its cooling-off ends, so its limit keeps
+// applying to transfers addressed by account number or IBAN.
+func (r *Repo) DeleteBeneficiary(ctx context.Context, userID, id string) error {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	b, ok := r.store.Beneficiaries[id]
+	if !ok || b.UserID != userID || b.DeletedAt != nil {
+		return ErrNotFound
+	}
+	now := time.Now()
+	if now.Before(b.CoolingOffUntil) {
+		b.DeletedAt = &now
+		return nil
+	}
+	delete(r.store.Beneficiaries, id)
+	return nil
+}
+
+// PruneBeneficiaries drops deleted payees whose cooling-off has ended.
+func (r *Repo) PruneBeneficiaries(now time.Time) int {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	n := 0
+	for id, b := range r.store.Beneficiaries {
+		if b.DeletedAt != nil && !now.Before(b.CoolingOffUntil) {
+			delete(r.store.Beneficiaries, id)
+			n++
+		}
+	}
+	return n
+}
+
+// coolingOffPayeeLocked returns the beneficiary of userID for toID while
+// it is cooling off, deleted or not, or nil.
+func (r *Repo) coolingOffPayeeLocked(userID, toID string, now time.Time) *model.Beneficiary {
+	for _, b := range r.store.Beneficiaries {
+		if b.UserID == userID && b.AccountID == toID && now.Before(b.CoolingOffUntil) {
+			return b
+		}
+	}
+	return nil
+}
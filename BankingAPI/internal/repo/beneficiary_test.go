@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCoolingOffLimit(t *testing.T) {
+	type step struct {
+		op      string // send, delete or save
+		amount  int64
+		wantErr error
+	}
+	tests := []struct {
+		name       string
+		coolingOff time.Duration
+		steps      []step
+	}{
+		{"up to the limit", time.Hour, []step{
+			{op: "send", amount: 6000},
+			{op: "send", amount: 4000},
+			{op: "send", amount: 1, wantErr: ErrCoolingOffLimit},
+		}},
+		{"single transfer above the limit", time.Hour, []step{
+			{op: "send", amount: 10001, wantErr: ErrCoolingOffLimit},
+			{op: "send", amount: 10000},
+		}},
+		{"deleted payee keeps its limit", time.Hour, []step{
+			{op: "send", amount: 6000},
+			{op: "delete"},
+			{op: "send", amount: 5000, wantErr: ErrCoolingOffLimit},
+			{op: "send", amount: 4000},
+		}},
+		{"saved again after deleting", time.Hour, []step{
+			{op: "send", amount: 6000},
+			{op: "delete"},
+			{op: "save"},
+			{op: "send", amount: 5000, wantErr: ErrCoolingOffLimit},
+		}},
+		{"cooling-off over", -time.Second, []step{
+			{op: "send", amount: 20000},
+			{op: "delete"},
+			{op: "save"},
+			{op: "send", amount: 20000, wantErr: ErrCoolingOffLimit},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ctx := newTestRepo(t)
+			u := newTestUser(t, r, ctx, "a@example.com")
+			payee := newTestUser(t, r, ctx, "b@example.com")
+			from := newTestAccount(t, r, ctx, u.ID, 100000)
+			to := newTestAccount(t, r, ctx, payee.ID, 0)
+			b, err := r.CreateBeneficiary(ctx, u.ID, to.ID, "b", tt.coolingOff, 10000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, s := range tt.steps {
+				switch s.op {
+				case "send":
+					_, _, err = r.Transfer(ctx, u.ID, from.ID, to.ID, s.amount, nil)
+				case "delete":
+					err = r.DeleteBeneficiary(ctx, u.ID, b.ID)
+				case "save":
+					b, err = r.CreateBeneficiary(ctx, u.ID, to.ID, "b", time.Hour, 10000)
+				}
+				if !errors.Is(err, s.wantErr) {
+					t.Fatalf("step %d: %s = %v, want %v", i, s.op, err, s.wantErr)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,477 @@
+package repo
+
+import (
+	"BankingAPI/internal/card"
+	"BankingAPI/internal/model"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrCardNotFound          = errors.New("card not found")
+	ErrCardCancelled         = errors.New("card is cancelled")
+	ErrAuthorizationNotFound = errors.New("authorization not found")
+	ErrAuthorizationSettled  = errors.New("authorization is no longer pending")
+	ErrCaptureTooLarge       = errors.New("capture exceeds the authorized amount")
+)
+
+// Reasons a card authorization is declined.
+const (
+	DeclineExpired            = "card_expired"
+	DeclineInvalidCVV         = "invalid_cvv"
+	DeclineCardFrozen         = "card_frozen"
+	DeclineCardCancelled      = "card_cancelled"
+	DeclineMCCNotAllowed      = "mcc_not_allowed"
+	DeclineTransactionLimit   = "transaction_limit_exceeded"
+	DeclineDailyLimit         = "daily_limit_exceeded"
+	DeclineInsufficientFunds  = "insufficient_funds"
+	DeclineAccountUnavailable = "account_unavailable"
+	DeclineCurrencyMismatch   = "currency_mismatch"
+)
+
+const (
+	// cardValidityYears is how long a newly issued card stays valid.
+	cardValidityYears = 3
+	// maxCVVFailures wrong CVVs in a row freeze a card.
+	maxCVVFailures = 3
+)
+
+// CardSettings are the adjustable controls of a card. Nil fields are left
+// unchanged on update.
+type CardSettings struct {
+	TransactionLimit *int64    `json:"transaction_limit,omitempty"`
+	DailyLimit       *int64    `json:"daily_limit,omitempty"`
+	AllowedMCCs      *[]string `json:"allowed_mccs,omitempty"`
+	BlockedMCCs      *[]string `json:"blocked_mccs,omitempty"`
+}
+
+func (s CardSettings) apply(c *model.Card) error {
+	if s.TransactionLimit != nil {
+		if *s.TransactionLimit < 0 {
+			return errors.New("transaction_limit must not be negative")
+		}
+		c.TransactionLimit = *s.TransactionLimit
+	}
+	if s.DailyLimit != nil {
+		if *s.DailyLimit < 0 {
+			return errors.New("daily_limit must not be negative")
+		}
+		c.DailyLimit = *s.DailyLimit
+	}
+	if s.AllowedMCCs != nil {
+		c.AllowedMCCs = *s.AllowedMCCs
+	}
+	if s.BlockedMCCs != nil {
+		c.BlockedMCCs = *s.BlockedMCCs
+	}
+	return nil
+}
+
+// IssuedCard is a new card with the details that are only shown once.
+type IssuedCard struct {
+	*model.Card
+	PAN string `json:"pan"`
+	CVV string `json:"cvv"`
+}
+
+func randomDigits(n int) string {
+	v, err := rand.Int(rand.Reader, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil))
+	if err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%0*d", n, v)
+}
+
+// IssueCard issues a virtual card on an account for holderID, who must be
+// allowed to initiate payments from it. Deleted, frozen and closed
+// accounts get no new cards.
+func (r *Repo) IssueCard(ctx context.Context, accountID, holderID, actorID string, settings CardSettings) (*IssuedCard, error) {
+	cvv := randomDigits(3)
+	cvvHash, err := bcrypt.GenerateFromPassword([]byte(cvv), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	a, ok := r.accountLocked(ctx, accountID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if a.DeletedAt != nil {
+		return nil, ErrAccountInactive
+	}
+	switch a.Status {
+	case model.AccountClosed:
+		return nil, ErrAccountClosed
+	case model.AccountFrozen:
+		return nil, ErrAccountFrozen
+	}
+	if err := allows(r.store.Members[accountID][holderID], PermInitiate, 0); err != nil {
+		return nil, ErrNotMember
+	}
+	var pan string
+	for {
+		pan = card.PAN(card.TestBIN + randomDigits(9))
+		if _, taken := r.store.PANIndex[HashToken(pan)]; !taken {
+			break
+		}
+	}
+	now := time.Now()
+	exp := now.AddDate(cardValidityYears, 0, 0)
+	c := &model.Card{
+		ID:        uuid.NewString(),
+		TenantID:  a.TenantID,
+		AccountID: a.ID,
+		HolderID:  holderID,
+		MaskedPAN: card.Mask(pan),
+		PANHash:   HashToken(pan),
+		CVVHash:   string(cvvHash),
+		ExpMonth:  int(exp.Month()),
+		ExpYear:   exp.Year(),
+		Status:    model.CardActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := settings.apply(c); err != nil {
+		return nil, err
+	}
+	r.store.Cards[c.ID] = c
+	r.store.PANIndex[c.PANHash] = c.ID
+	r.auditLocked(actorID, "card.issued", "card", c.ID, "", map[string]interface{}{"account_id": a.ID, "holder_id": holderID})
+	return &IssuedCard{Card: c, PAN: pan, CVV: cvv}, nil
+}
+
+// cardLocked returns a card of an account visible in ctx's tenant.
+func (r *Repo) cardLocked(ctx context.Context, accountID, cardID string) (*model.Card, bool) {
+	if _, ok := r.accountLocked(ctx, accountID); !ok {
+		return nil, false
+	}
+	c, ok := r.store.Cards[cardID]
+	if !ok || c.AccountID != accountID {
+		return nil, false
+	}
+	return c, true
+}
+
+// ListCards returns the cards issued on an account.
+func (r *Repo) ListCards(ctx context.Context, accountID string) ([]*model.Card, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	if _, ok := r.accountLocked(ctx, accountID); !ok {
+		return nil, ErrNotFound
+	}
+	out := []*model.Card{}
+	for _, c := range r.store.Cards {
+		if c.AccountID == accountID {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// UpdateCard changes a card's limits and merchant category restrictions.
+func (r *Repo) UpdateCard(ctx context.Context, accountID, cardID, actorID string, settings CardSettings) (*model.Card, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	c, ok := r.cardLocked(ctx, accountID, cardID)
+	if !ok {
+		return nil, ErrCardNotFound
+	}
+	if c.Status == model.CardCancelled {
+		return nil, ErrCardCancelled
+	}
+	// validate on a copy so a bad field leaves the card untouched
+	updated := *c
+	if err := settings.apply(&updated); err != nil {
+		return nil, err
+	}
+	updated.UpdatedAt = time.Now()
+	*c = updated
+	r.auditLocked(actorID, "card.updated", "card", c.ID, "", nil)
+	return c, nil
+}
+
+// SetCardStatus freezes, unfreezes or cancels a card. Cancelling is final.
+func (r *Repo) SetCardStatus(ctx context.Context, accountID, cardID, actorID string, status model.CardStatus) (*model.Card, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	c, ok := r.cardLocked(ctx, accountID, cardID)
+	if !ok {
+		return nil, ErrCardNotFound
+	}
+	if c.Status == model.CardCancelled {
+		return nil, ErrCardCancelled
+	}
+	c.Status = status
+	c.CVVFailures = 0
+	c.UpdatedAt = time.Now()
+	r.auditLocked(actorID, "card.status_changed", "card", c.ID, "", map[string]interface{}{"status": status})
+	return c, nil
+}
+
+// cancelCardsLocked cancels every card of an account.
+func (r *Repo) cancelCardsLocked(accountID string) {
+	now := time.Now()
+	for _, c := range r.store.Cards {
+		if c.AccountID == accountID && c.Status != model.CardCancelled {
+			c.Status = model.CardCancelled
+			c.UpdatedAt = now
+		}
+	}
+}
+
+// CardAuthRequest is an authorization request as sent by a card network.
+type CardAuthRequest struct {
+	PAN      string `json:"pan"`
+	ExpMonth int    `json:"exp_month"`
+	ExpYear  int    `json:"exp_year"`
+	CVV      string `json:"cvv"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	Merchant string `json:"merchant"`
+	MCC      string `json:"mcc"`
+}
+
+// AuthorizeCard decides on a card payment. An approved authorization puts
+// a hold of the amount on the account until it is captured, released or
+// expires after ttl; declined ones are recorded with the reason. Only an
+// unknown card number is reported as an error. Wrong CVVs are counted so
+// a card cannot be guessed at: too many in a row freeze it.
+func (r *Repo) AuthorizeCard(ctx context.Context, req CardAuthRequest, ttl time.Duration) (*model.CardAuthorization, error) {
+	if req.Amount <= 0 {
+		return nil, ErrAmountNotPositive
+	}
+	panHash := HashToken(card.Normalize(req.PAN))
+	r.store.Mu.RLock()
+	c, ok := r.store.Cards[r.store.PANIndex[panHash]]
+	ok = ok && c.TenantID == TenantFrom(ctx)
+	var cvvHash string
+	if ok {
+		cvvHash = c.CVVHash
+	}
+	r.store.Mu.RUnlock()
+	if !ok {
+		return nil, ErrCardNotFound
+	}
+	// bcrypt is slow; compare before taking the write lock
+	cvvOK := bcrypt.CompareHashAndPassword([]byte(cvvHash), []byte(req.CVV)) == nil
+
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	now := time.Now()
+	auth := &model.CardAuthorization{
+		ID:        uuid.NewString(),
+		TenantID:  c.TenantID,
+		CardID:    c.ID,
+		AccountID: c.AccountID,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+		Merchant:  req.Merchant,
+		MCC:       req.MCC,
+		Status:    model.AuthorizationApproved,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.store.CardAuthorizations[auth.ID] = auth
+	a := r.store.Accounts[c.AccountID]
+	reason := r.declineReasonLocked(c, a, req, cvvOK, now)
+	switch {
+	case reason == DeclineInvalidCVV:
+		c.CVVFailures++
+		if c.CVVFailures >= maxCVVFailures {
+			c.Status = model.CardFrozen
+			c.UpdatedAt = now
+			r.auditLocked(SystemActor, "card.status_changed", "card", c.ID, "too many wrong CVVs", map[string]interface{}{"status": model.CardFrozen})
+		}
+	case cvvOK:
+		c.CVVFailures = 0
+	}
+	if reason != "" {
+		auth.Status = model.AuthorizationDeclined
+		auth.DeclineReason = reason
+		return auth, nil
+	}
+	a.Reserved += req.Amount
+	a.UpdatedAt = now
+	a.LastActivityAt = now
+	return auth, nil
+}
+
+func (r *Repo) declineReasonLocked(c *model.Card, a *model.Account, req CardAuthRequest, cvvOK bool, now time.Time) string {
+	switch c.Status {
+	case model.CardCancelled:
+		return DeclineCardCancelled
+	case model.CardFrozen:
+		return DeclineCardFrozen
+	}
+	// a card is valid until the end of its expiry month
+	if req.ExpMonth != c.ExpMonth || req.ExpYear != c.ExpYear || !now.Before(time.Date(c.ExpYear, time.Month(c.ExpMonth)+1, 1, 0, 0, 0, 0, time.UTC)) {
+		return DeclineExpired
+	}
+	if !cvvOK {
+		return DeclineInvalidCVV
+	}
+	if contains(c.BlockedMCCs, req.MCC) || (len(c.AllowedMCCs) > 0 && !contains(c.AllowedMCCs, req.MCC)) {
+		return DeclineMCCNotAllowed
+	}
+	if c.TransactionLimit > 0 && req.Amount > c.TransactionLimit {
+		return DeclineTransactionLimit
+	}
+	if c.DailyLimit > 0 && r.cardSpentSinceLocked(c.ID, now.Add(-24*time.Hour))+req.Amount > c.DailyLimit {
+		return DeclineDailyLimit
+	}
+	if a == nil || debitErr(a) != nil {
+		return DeclineAccountUnavailable
+	}
+	if req.Currency != "" && req.Currency != a.Currency {
+		return DeclineCurrencyMismatch
+	}
+	// the holder's member limit still applies to card spending
+	if err := allows(r.store.Members[a.ID][c.HolderID], PermInitiate, req.Amount); err != nil {
+		return DeclineTransactionLimit
+	}
+	if a.Available() < req.Amount {
+		return DeclineInsufficientFunds
+	}
+	return ""
+}
+
+// cardSpentSinceLocked sums a card's held and settled amounts since t.
+func (r *Repo) cardSpentSinceLocked(cardID string, t time.Time) int64 {
+	var sum int64
+	for _, auth := range r.store.CardAuthorizations {
+		if auth.CardID != cardID || auth.CreatedAt.Before(t) {
+			continue
+		}
+		switch auth.Status {
+		case model.AuthorizationApproved:
+			sum += auth.Amount
+		case model.AuthorizationSettled:
+			sum += auth.SettledAmount
+		}
+	}
+	return sum
+}
+
+func contains(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// pendingAuthorizationLocked returns an authorization of ctx's tenant
+// that still holds funds.
+func (r *Repo) pendingAuthorizationLocked(ctx context.Context, id string) (*model.CardAuthorization, error) {
+	auth, ok := r.store.CardAuthorizations[id]
+	if !ok || auth.TenantID != TenantFrom(ctx) {
+		return nil, ErrAuthorizationNotFound
+	}
+	if auth.Status != model.AuthorizationApproved {
+		return nil, ErrAuthorizationSettled
+	}
+	return auth, nil
+}
+
+// CaptureAuthorization settles a hold into a withdrawal of amount, which
+// may be less than the authorized amount (0 captures it all). The rest of
+// the hold is released.
+func (r *Repo) CaptureAuthorization(ctx context.Context, id string, amount int64) (*model.CardAuthorization, *model.Transaction, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	auth, err := r.pendingAuthorizationLocked(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if amount == 0 {
+		amount = auth.Amount
+	}
+	if amount < 0 {
+		return nil, nil, ErrAmountNotPositive
+	}
+	if amount > auth.Amount {
+		return nil, nil, ErrCaptureTooLarge
+	}
+	// the funds were held at authorization, so a later freeze does not
+	// stop the merchant being paid
+	a := r.store.Accounts[auth.AccountID]
+	now := time.Now()
+	a.Reserved -= auth.Amount
+	a.Balance -= amount
+	a.UpdatedAt = now
+	t := newTransaction(a, model.Withdraw, amount, map[string]interface{}{
+		"card_id":          auth.CardID,
+		"authorization_id": auth.ID,
+		"merchant":         auth.Merchant,
+		"mcc":              auth.MCC,
+	})
+	r.store.Transactions[t.ID] = t
+	auth.Status = model.AuthorizationSettled
+	auth.SettledAmount = amount
+	auth.TransactionID = t.ID
+	auth.UpdatedAt = now
+	r.notifyLocked(a, t)
+	return auth, t, nil
+}
+
+// ReleaseAuthorization drops a hold without moving money.
+func (r *Repo) ReleaseAuthorization(ctx context.Context, id string) (*model.CardAuthorization, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	auth, err := r.pendingAuthorizationLocked(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	r.releaseHoldLocked(auth, model.AuthorizationReleased)
+	return auth, nil
+}
+
+func (r *Repo) releaseHoldLocked(auth *model.CardAuthorization, status model.CardAuthorizationStatus) {
+	now := time.Now()
+	a := r.store.Accounts[auth.AccountID]
+	a.Reserved -= auth.Amount
+	a.UpdatedAt = now
+	auth.Status = status
+	auth.UpdatedAt = now
+}
+
+// ExpireAuthorizations releases holds past their expiry and returns how
+// many were released.
+func (r *Repo) ExpireAuthorizations(ctx context.Context, now time.Time) int {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	n := 0
+	for _, auth := range r.store.CardAuthorizations {
+		if auth.Status == model.AuthorizationApproved && now.After(auth.ExpiresAt) {
+			r.releaseHoldLocked(auth, model.AuthorizationExpired)
+			n++
+		}
+	}
+	return n
+}
+
+// ListCardAuthorizations returns the authorizations of a card.
+func (r *Repo) ListCardAuthorizations(ctx context.Context, accountID, cardID string) ([]*model.CardAuthorization, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	if _, ok := r.cardLocked(ctx, accountID, cardID); !ok {
+		return nil, ErrCardNotFound
+	}
+	out := []*model.CardAuthorization{}
+	for _, auth := range r.store.CardAuthorizations {
+		if auth.CardID == cardID {
+			out = append(out, auth)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,143 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"errors"
+	"testing"
+	"time"
+)
+
+// cardStep is one call made against the latest authorization of a card.
+type cardStep struct {
+	op          string // authorize, capture, release or expire
+	amount      int64
+	badCVV      bool
+	wantErr     error
+	wantDecline string
+}
+
+func TestCardHolds(t *testing.T) {
+	tests := []struct {
+		name         string
+		steps        []cardStep
+		wantBalance  int64
+		wantReserved int64
+	}{
+		{"hold", []cardStep{{op: "authorize", amount: 2000}}, 5000, 2000},
+		{"full capture", []cardStep{{op: "authorize", amount: 2000}, {op: "capture"}}, 3000, 0},
+		{"partial capture", []cardStep{{op: "authorize", amount: 2000}, {op: "capture", amount: 1500}}, 3500, 0},
+		{"release", []cardStep{{op: "authorize", amount: 2000}, {op: "release"}}, 5000, 0},
+		{"expiry", []cardStep{{op: "authorize", amount: 2000}, {op: "expire"}}, 5000, 0},
+		{"capture above the hold", []cardStep{
+			{op: "authorize", amount: 2000},
+			{op: "capture", amount: 2500, wantErr: ErrCaptureTooLarge},
+		}, 5000, 2000},
+		{"capture twice", []cardStep{
+			{op: "authorize", amount: 2000},
+			{op: "capture"},
+			{op: "capture", wantErr: ErrAuthorizationSettled},
+		}, 3000, 0},
+		{"release after capture", []cardStep{
+			{op: "authorize", amount: 2000},
+			{op: "capture"},
+			{op: "release", wantErr: ErrAuthorizationSettled},
+		}, 3000, 0},
+		{"capture after release", []cardStep{
+			{op: "authorize", amount: 2000},
+			{op: "release"},
+			{op: "capture", wantErr: ErrAuthorizationSettled},
+		}, 5000, 0},
+		{"more than the balance", []cardStep{{op: "authorize", amount: 6000, wantDecline: DeclineInsufficientFunds}}, 5000, 0},
+		{"held funds are not available", []cardStep{
+			{op: "authorize", amount: 2000},
+			{op: "authorize", amount: 3500, wantDecline: DeclineInsufficientFunds},
+		}, 5000, 2000},
+		{"wrong CVV", []cardStep{{op: "authorize", amount: 2000, badCVV: true, wantDecline: DeclineInvalidCVV}}, 5000, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ctx := newTestRepo(t)
+			u := newTestUser(t, r, ctx, "a@example.com")
+			a := newTestAccount(t, r, ctx, u.ID, 5000)
+			c, err := r.IssueCard(ctx, a.ID, u.ID, u.ID, CardSettings{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var auth *model.CardAuthorization
+			for i, s := range tt.steps {
+				switch s.op {
+				case "authorize":
+					req := CardAuthRequest{PAN: c.PAN, ExpMonth: c.ExpMonth, ExpYear: c.ExpYear, CVV: c.CVV, Amount: s.amount, Currency: "EUR"}
+					if s.badCVV {
+						req.CVV = "x"
+					}
+					auth, err = r.AuthorizeCard(ctx, req, time.Minute)
+					if err == nil && auth.DeclineReason != s.wantDecline {
+						t.Errorf("step %d: decline reason = %q, want %q", i, auth.DeclineReason, s.wantDecline)
+					}
+				case "capture":
+					_, _, err = r.CaptureAuthorization(ctx, auth.ID, s.amount)
+				case "release":
+					_, err = r.ReleaseAuthorization(ctx, auth.ID)
+				case "expire":
+					if n := r.ExpireAuthorizations(ctx, time.Now().Add(2*time.Minute)); n != 1 {
+						t.Errorf("step %d: expired %d holds, want 1", i, n)
+					}
+					err = nil
+				}
+				if !errors.Is(err, s.wantErr) {
+					t.Fatalf("step %d: %s = %v, want %v", i, s.op, err, s.wantErr)
+				}
+			}
+			if a.Balance != tt.wantBalance || a.Reserved != tt.wantReserved {
+				t.Errorf("balance %d reserved %d, want %d and %d", a.Balance, a.Reserved, tt.wantBalance, tt.wantReserved)
+			}
+			if got := a.Available(); got != tt.wantBalance-tt.wantReserved {
+				t.Errorf("available = %d, want %d", got, tt.wantBalance-tt.wantReserved)
+			}
+		})
+	}
+}
+
+func TestIssueCard(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(a *model.Account)
+		tenant  string
+		wantErr error
+	}{
+		{"active account", nil, "t", nil},
+		{"deleted account", func(a *model.Account) { now := time.Now(); a.DeletedAt = &now }, "t", ErrAccountInactive},
+		{"frozen account", func(a *model.Account) { a.Status = model.AccountFrozen }, "t", ErrAccountFrozen},
+		{"closed account", func(a *model.Account) { a.Status = model.AccountClosed }, "t", ErrAccountClosed},
+		{"other tenant", nil, "other", ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ctx := newTestRepo(t)
+			u := newTestUser(t, r, ctx, "a@example.com")
+			a := newTestAccount(t, r, ctx, u.ID, 5000)
+			if tt.setup != nil {
+				tt.setup(a)
+			}
+			callCtx := WithTenant(ctx, tt.tenant)
+			if _, err := r.IssueCard(callCtx, a.ID, u.ID, u.ID, CardSettings{}); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("IssueCard = %v, want %v", err, tt.wantErr)
+			}
+			cards, err := r.ListCards(callCtx, a.ID)
+			if tt.tenant != "t" {
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("ListCards from another tenant = %v, want %v", err, ErrNotFound)
+				}
+				return
+			}
+			want := 0
+			if tt.wantErr == nil {
+				want = 1
+			}
+			if err != nil || len(cards) != want {
+				t.Errorf("ListCards = %d cards, %v; want %d", len(cards), err, want)
+			}
+		})
+	}
+}
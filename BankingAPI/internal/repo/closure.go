@@ -0,0 +1,110 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"context"
+	"time"
+)
+
+// ClosureResult is the outcome of closing an account.
+type ClosureResult struct {
+	Account      *model.Account     `json:"account"`
+	SweepOut     *model.Transaction `json:"sweep_withdraw_txn,omitempty"`
+	SweepIn      *model.Transaction `json:"sweep_deposit_txn,omitempty"`
+	ClosingEntry *model.Transaction `json:"closing_entry"`
+}
+
+// CloseAccount permanently closes an account. A non-zero balance must be
+// swept to sweepToID, which has to be another open account of the same
+// owner and currency. The account becomes read-only once closed.
+func (r *Repo) CloseAccount(ctx context.Context, id, sweepToID, actorID, reason string) (*ClosureResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+
+	a, ok := r.accountLocked(ctx, id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if a.Status == model.AccountClosed {
+		return nil, ErrAccountClosed
+	}
+	if a.AdminFrozen {
+		return nil, ErrAdminFrozen
+	}
+	if !a.Status.CanTransition(model.AccountClosed) {
+		return nil, ErrInvalidStatus
+	}
+	if err := r.pendingItemsLocked(a.ID); err != nil {
+		return nil, err
+	}
+
+	res := &ClosureResult{Account: a}
+	swept := a.Balance
+	var to *model.Account
+	if swept != 0 {
+		var ok bool
+		to, ok = r.accountLocked(ctx, sweepToID)
+		if sweepToID == "" || !ok || to.ID == a.ID || to.UserID != a.UserID || to.Currency != a.Currency {
+			return nil, ErrInvalidSweep
+		}
+		if err := creditErr(to); err != nil {
+			return nil, ErrInvalidSweep
+		}
+		if err := debitErr(a); err != nil {
+			return nil, err
+		}
+	}
+
+	// pots are part of the balance; empty them so everything is swept
+	r.releasePotsLocked(a)
+	if swept != 0 {
+		now := time.Now()
+		meta := map[string]interface{}{"reason": "account closure sweep", "closed_account_id": a.ID}
+		a.Balance = 0
+		a.UpdatedAt = now
+		to.Balance += swept
+		to.UpdatedAt = now
+		res.SweepOut = newTransaction(a, model.Withdraw, swept, meta)
+		r.store.Transactions[res.SweepOut.ID] = res.SweepOut
+		res.SweepIn = newTransaction(to, model.Deposit, swept, meta)
+		r.store.Transactions[res.SweepIn.ID] = res.SweepIn
+		r.notifyLocked(a, res.SweepOut)
+		r.notifyLocked(to, res.SweepIn)
+	}
+
+	if err := r.setStatusLocked(a, model.AccountClosed, actorID, reason); err != nil {
+		return nil, err
+	}
+	r.cancelCardsLocked(a.ID)
+	closing := map[string]interface{}{"closing_balance": 0, "swept_amount": swept, "reason": reason}
+	if swept != 0 {
+		closing["swept_to_account_id"] = sweepToID
+	}
+	res.ClosingEntry = newTransaction(a, model.Closure, 0, closing)
+	r.store.Transactions[res.ClosingEntry.ID] = res.ClosingEntry
+	r.notifyLocked(a, res.ClosingEntry)
+	return res, nil
+}
+
+// pendingItemsLocked returns ErrPendingItems while money is still
+// committed against the account by transfers awaiting approval or card
+// holds.
+func (r *Repo) pendingItemsLocked(accountID string) error {
+	for _, tr := range r.store.TransferRequests {
+		if tr.FromAccountID != accountID {
+			continue
+		}
+		if tr.Status == model.TransferPending || tr.Status == model.TransferApproved {
+			return ErrPendingItems
+		}
+	}
+	for _, auth := range r.store.CardAuthorizations {
+		if auth.AccountID == accountID && auth.Status == model.AuthorizationApproved {
+			return ErrPendingItems
+		}
+	}
+	return nil
+}
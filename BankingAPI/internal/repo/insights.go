@@ -0,0 +1,127 @@
+package repo
+
+import (
+	"BankingAPI/internal/insights"
+	"BankingAPI/internal/model"
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidCategory = errors.New("unknown category")
+	ErrMixedCurrencies = errors.New("accounts use several currencies; pick one")
+)
+
+// Insights builds a spending report over every account userID can view in
+// currency (optional when all those accounts share one).
+func (r *Repo) Insights(ctx context.Context, userID, currency string, from, to time.Time, top int) (*insights.Report, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	tenantID := TenantFrom(ctx)
+	explicit := currency != ""
+	own := map[string]bool{}
+	for id, a := range r.store.Accounts {
+		if a.TenantID != tenantID || allows(r.store.Members[id][userID], PermView, 0) != nil {
+			continue
+		}
+		if currency == "" {
+			currency = a.Currency
+		}
+		if a.Currency != currency {
+			if !explicit {
+				return nil, ErrMixedCurrencies
+			}
+			continue
+		}
+		own[id] = true
+	}
+	entries := []insights.Entry{}
+	for _, t := range r.store.Transactions {
+		if !own[t.AccountID] {
+			continue
+		}
+		var amount int64
+		switch t.Type {
+		case model.Deposit:
+			amount = t.Amount
+		case model.Withdraw:
+			amount = -t.Amount
+		default:
+			// pot moves and closing entries do not change what was spent
+			continue
+		}
+		counterpartyID, _ := t.Meta["counterparty_account_id"].(string)
+		e := insights.Entry{
+			Amount:   amount,
+			Internal: counterpartyID != "" && own[counterpartyID],
+			At:       t.CreatedAt,
+		}
+		e.Category = r.categoryLocked(userID, t, e)
+		e.Counterparty = r.counterpartyLocked(t, counterpartyID)
+		entries = append(entries, e)
+	}
+	return insights.Build(currency, entries, from, to, top), nil
+}
+
+// categoryLocked resolves a transaction's category: the user's override
+// for the transaction, then their override for its merchant, then the
+// rule-based categoriser, then a default by direction.
+func (r *Repo) categoryLocked(userID string, t *model.Transaction, e insights.Entry) string {
+	if c, ok := r.store.TxnCategories[userID][t.ID]; ok {
+		return c
+	}
+	if key := insights.MerchantKey(t.Meta); key != "" {
+		if c, ok := r.store.MerchantCategories[userID][key]; ok {
+			return c
+		}
+	}
+	if c, ok := insights.Categorize(t.Meta); ok {
+		return c
+	}
+	switch {
+	case e.Amount > 0:
+		return insights.Income
+	case t.Meta["counterparty_account_id"] != nil:
+		return insights.Transfers
+	}
+	return insights.Other
+}
+
+// counterpartyLocked names the other side of a transaction: the merchant
+// if given, else the counterparty account's IBAN.
+func (r *Repo) counterpartyLocked(t *model.Transaction, counterpartyID string) string {
+	if m, ok := t.Meta["merchant"].(string); ok && m != "" {
+		return m
+	}
+	if a, ok := r.store.Accounts[counterpartyID]; ok {
+		return a.IBAN
+	}
+	return ""
+}
+
+// SetTransactionCategory overrides the category of a transaction for
+// userID. When the transaction has a merchant the choice is remembered
+// for that merchant's future transactions too.
+func (r *Repo) SetTransactionCategory(ctx context.Context, userID, txnID, category string) (*model.Transaction, error) {
+	if !insights.Valid(category) {
+		return nil, ErrInvalidCategory
+	}
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	t, ok := r.store.Transactions[txnID]
+	if !ok || t.TenantID != TenantFrom(ctx) || allows(r.store.Members[t.AccountID][userID], PermView, 0) != nil {
+		return nil, ErrNotFound
+	}
+	if r.store.TxnCategories[userID] == nil {
+		r.store.TxnCategories[userID] = make(map[string]string)
+	}
+	r.store.TxnCategories[userID][t.ID] = category
+	if key := insights.MerchantKey(t.Meta); key != "" {
+		if r.store.MerchantCategories[userID] == nil {
+			r.store.MerchantCategories[userID] = make(map[string]string)
+		}
+		r.store.MerchantCategories[userID][key] = category
+	}
+	return t, nil
+}
@@ -0,0 +1,230 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/storage"
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LoginPolicy limits password guessing. Each failed login for an email
+// address makes the next attempt wait twice as long as the one before,
+// up to MaxDelay; MaxFailures in a row lock the address for Lockout. A
+// client IP is locked after IPMaxFailures, whichever addresses it tried.
+// Counts reset after Lockout without a failure.
+type LoginPolicy struct {
+	MaxFailures   int
+	IPMaxFailures int
+	Lockout       time.Duration
+	MaxDelay      time.Duration
+}
+
+// loginRetry is how long to wait for an attempt still in flight.
+const loginRetry = time.Second
+
+func emailThrottleKey(tenantID, email string) string {
+	return "email:" + storage.EmailKey(tenantID, email)
+}
+
+func ipThrottleKey(tenantID, ip string) string {
+	return "ip:" + tenantID + "/" + ip
+}
+
+// ReserveLoginAttempt lets a login for email from ip go ahead, or returns
+// how long it must wait. The attempt is reserved under the store lock
+// before the password is checked, so concurrent guesses cannot all get
+// past the throttle before the first failure is counted: an address has
+// one attempt in flight at a time and an IP's attempts in flight count
+// towards its lockout. Unknown addresses are throttled like real ones so
+// the response does not reveal which are registered. A reservation ends
+// with RecordLoginFailure, RecordLoginSuccess or ReleaseLoginAttempt.
+func (r *Repo) ReserveLoginAttempt(ctx context.Context, email, ip string, p LoginPolicy, now time.Time) time.Duration {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	tenantID := TenantFrom(ctx)
+	ek, ik := emailThrottleKey(tenantID, email), ipThrottleKey(tenantID, ip)
+	var wait time.Duration
+	for _, key := range []string{ek, ik} {
+		t, ok := r.store.LoginThrottles[key]
+		if !ok {
+			continue
+		}
+		until := t.NextAttemptAt
+		if t.LockedUntil != nil && t.LockedUntil.After(until) {
+			until = *t.LockedUntil
+		}
+		if d := until.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+	e, i := r.throttleLocked(ek, p, now), r.throttleLocked(ik, p, now)
+	if e.InFlight > 0 || i.Failures+i.InFlight >= p.IPMaxFailures {
+		return loginRetry
+	}
+	e.InFlight++
+	i.InFlight++
+	return 0
+}
+
+// throttleLocked returns the throttle of key, starting the count over
+// when the last failure is older than the lockout period.
+func (r *Repo) throttleLocked(key string, p LoginPolicy, now time.Time) *model.LoginThrottle {
+	t, ok := r.store.LoginThrottles[key]
+	if !ok {
+		t = &model.LoginThrottle{Key: key}
+		r.store.LoginThrottles[key] = t
+	} else if t.Failures > 0 && now.Sub(t.LastFailureAt) > p.Lockout && !locked(t, now) {
+		*t = model.LoginThrottle{Key: key, InFlight: t.InFlight}
+	}
+	return t
+}
+
+// releaseLocked ends an attempt in flight against key, forgetting keys
+// with nothing left to count.
+func (r *Repo) releaseLocked(key string) {
+	t, ok := r.store.LoginThrottles[key]
+	if !ok {
+		return
+	}
+	if t.InFlight > 0 {
+		t.InFlight--
+	}
+	if t.Failures == 0 && t.InFlight == 0 {
+		delete(r.store.LoginThrottles, key)
+	}
+}
+
+// RecordLoginFailure ends a reserved attempt as a failed login and
+// applies p.
+func (r *Repo) RecordLoginFailure(ctx context.Context, email, ip string, p LoginPolicy, now time.Time) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	tenantID := TenantFrom(ctx)
+
+	t := r.failLoginLocked(emailThrottleKey(tenantID, email), p, now)
+	delay := time.Second << min(t.Failures-1, 30)
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	t.NextAttemptAt = now.Add(delay)
+	if t.Failures >= p.MaxFailures && !locked(t, now) {
+		until := now.Add(p.Lockout)
+		t.LockedUntil = &until
+		// lockouts of unregistered addresses are not audited, so the
+		// trail holds no addresses of people who are not customers
+		if userID, ok := r.store.EmailIndex[storage.EmailKey(tenantID, email)]; ok {
+			r.auditLocked(SystemActor, "login.locked", "user", userID, "too many failed logins", map[string]interface{}{"failures": t.Failures, "locked_until": until, "ip": ip})
+		}
+	}
+
+	t = r.failLoginLocked(ipThrottleKey(tenantID, ip), p, now)
+	if t.Failures >= p.IPMaxFailures && !locked(t, now) {
+		until := now.Add(p.Lockout)
+		t.LockedUntil = &until
+		r.auditLocked(SystemActor, "login.locked", "ip", ip, "too many failed logins", map[string]interface{}{"failures": t.Failures, "locked_until": until})
+	}
+}
+
+func locked(t *model.LoginThrottle, now time.Time) bool {
+	return t.LockedUntil != nil && now.Before(*t.LockedUntil)
+}
+
+// failLoginLocked turns an attempt in flight against key into a failure.
+func (r *Repo) failLoginLocked(key string, p LoginPolicy, now time.Time) *model.LoginThrottle {
+	t := r.throttleLocked(key, p, now)
+	if t.InFlight > 0 {
+		t.InFlight--
+	}
+	t.Failures++
+	t.LastFailureAt = now
+	return t
+}
+
+// RecordLoginSuccess ends a reserved attempt as a login and clears the
+// failures of the email address. The IP's count is kept, so a valid login
+// of one's own does not reset guessing at other addresses.
+func (r *Repo) RecordLoginSuccess(ctx context.Context, email, ip string) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	tenantID := TenantFrom(ctx)
+	delete(r.store.LoginThrottles, emailThrottleKey(tenantID, email))
+	r.releaseLocked(ipThrottleKey(tenantID, ip))
+}
+
+// ReleaseLoginAttempt ends a reserved attempt that neither failed nor
+// completed a login, such as a right password still owing its second
+// factor.
+func (r *Repo) ReleaseLoginAttempt(ctx context.Context, email, ip string) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	tenantID := TenantFrom(ctx)
+	r.releaseLocked(emailThrottleKey(tenantID, email))
+	r.releaseLocked(ipThrottleKey(tenantID, ip))
+}
+
+// ListLockouts returns the tenant's email addresses and IPs that are
+// locked out at now.
+func (r *Repo) ListLockouts(ctx context.Context, now time.Time) []*model.LoginThrottle {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	tenantID := TenantFrom(ctx)
+	out := []*model.LoginThrottle{}
+	for key, t := range r.store.LoginThrottles {
+		if (strings.HasPrefix(key, "email:"+tenantID+"/") || strings.HasPrefix(key, "ip:"+tenantID+"/")) && locked(t, now) {
+			out = append(out, t)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LockedUntil.Before(*out[j].LockedUntil) })
+	return out
+}
+
+// UnlockUser lifts a lockout of the user's email address.
+func (r *Repo) UnlockUser(ctx context.Context, userID, actorID string) error {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	u, ok := r.userLocked(ctx, userID)
+	if !ok {
+		return ErrNotFound
+	}
+	key := emailThrottleKey(u.TenantID, u.Email)
+	if _, ok := r.store.LoginThrottles[key]; !ok {
+		return ErrNotFound
+	}
+	delete(r.store.LoginThrottles, key)
+	r.auditLocked(actorID, "login.unlocked", "user", userID, "", nil)
+	return nil
+}
+
+// UnlockIP lifts a lockout of a client IP.
+func (r *Repo) UnlockIP(ctx context.Context, ip, actorID string) error {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	key := ipThrottleKey(TenantFrom(ctx), ip)
+	if _, ok := r.store.LoginThrottles[key]; !ok {
+		return ErrNotFound
+	}
+	delete(r.store.LoginThrottles, key)
+	r.auditLocked(actorID, "login.unlocked", "ip", ip, "", nil)
+	return nil
+}
+
+// PruneLoginThrottles forgets failures that no longer count: the last one
+// is older than lockout, any lockout has ended and no attempt is in
+// flight.
+func (r *Repo) PruneLoginThrottles(now time.Time, lockout time.Duration) int {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	n := 0
+	for key, t := range r.store.LoginThrottles {
+		if now.Sub(t.LastFailureAt) > lockout && !locked(t, now) && t.InFlight == 0 {
+			delete(r.store.LoginThrottles, key)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,145 @@
+package repo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	type step struct {
+		op    string // reserve, fail, ok, release or prune
+		email string // "a", "b" or "c", mapped to a registered or unknown address
+		ip    string
+		at    time.Duration
+		want  time.Duration // reserve: the wait; prune: the count
+	}
+	policy := LoginPolicy{MaxFailures: 3, IPMaxFailures: 5, Lockout: 15 * time.Minute, MaxDelay: 4 * time.Second}
+	tests := []struct {
+		name   string
+		policy func(*LoginPolicy)
+		steps  []step
+	}{
+		{"in-flight reservation", nil, []step{
+			{"reserve", "a", "ip1", 0, 0},
+			{"reserve", "a", "ip2", 0, loginRetry},
+			{"release", "a", "ip1", 0, 0},
+			{"reserve", "a", "ip2", 0, 0},
+		}},
+		{"doubling delay capped at MaxDelay", func(p *LoginPolicy) { p.MaxFailures = 10 }, []step{
+			{"reserve", "a", "ip1", 0, 0},
+			{"fail", "a", "ip1", 0, 0},
+			{"reserve", "a", "ip1", 0, time.Second},
+			{"reserve", "a", "ip1", time.Second, 0},
+			{"fail", "a", "ip1", time.Second, 0},
+			{"reserve", "a", "ip1", time.Second, 2 * time.Second},
+			{"reserve", "a", "ip1", 3 * time.Second, 0},
+			{"fail", "a", "ip1", 3 * time.Second, 0},
+			{"reserve", "a", "ip1", 3 * time.Second, 4 * time.Second},
+			{"reserve", "a", "ip1", 7 * time.Second, 0},
+			{"fail", "a", "ip1", 7 * time.Second, 0},
+			{"reserve", "a", "ip1", 7 * time.Second, 4 * time.Second},
+		}},
+		{"email lockout", nil, []step{
+			{"reserve", "a", "ip1", 0, 0},
+			{"fail", "a", "ip1", 0, 0},
+			{"reserve", "a", "ip1", time.Second, 0},
+			{"fail", "a", "ip1", time.Second, 0},
+			{"reserve", "a", "ip2", 3 * time.Second, 0},
+			{"fail", "a", "ip2", 3 * time.Second, 0},
+			{"reserve", "a", "ip3", 4 * time.Second, 15*time.Minute - time.Second},
+			{"reserve", "b", "ip3", 4 * time.Second, 0},
+			{"release", "b", "ip3", 4 * time.Second, 0},
+			{"reserve", "a", "ip3", 3*time.Second + 15*time.Minute, 0},
+		}},
+		{"IP lockout", func(p *LoginPolicy) { p.IPMaxFailures = 2 }, []step{
+			{"reserve", "a", "ip1", 0, 0},
+			{"fail", "a", "ip1", 0, 0},
+			{"reserve", "b", "ip1", 0, 0},
+			{"fail", "b", "ip1", 0, 0},
+			{"reserve", "b", "ip1", time.Second, 15*time.Minute - time.Second},
+			{"reserve", "b", "ip2", time.Second, 0},
+		}},
+		{"in-flight attempts count towards the IP lockout", func(p *LoginPolicy) { p.IPMaxFailures = 2 }, []step{
+			{"reserve", "a", "ip1", 0, 0},
+			{"reserve", "b", "ip1", 0, 0},
+			{"reserve", "c", "ip1", 0, loginRetry},
+			{"release", "a", "ip1", 0, 0},
+			{"reserve", "c", "ip1", 0, 0},
+		}},
+		{"login clears the email's failures only", func(p *LoginPolicy) { p.IPMaxFailures = 2 }, []step{
+			{"reserve", "a", "ip1", 0, 0},
+			{"fail", "a", "ip1", 0, 0},
+			{"reserve", "a", "ip1", time.Second, 0},
+			{"ok", "a", "ip1", time.Second, 0},
+			{"reserve", "a", "ip1", time.Second, 0},
+			{"fail", "a", "ip1", time.Second, 0},
+			{"reserve", "b", "ip1", 2 * time.Second, 15*time.Minute - time.Second},
+		}},
+		{"PruneLoginThrottles", nil, []step{
+			{"reserve", "a", "ip1", 0, 0},
+			{"fail", "a", "ip1", 0, 0},
+			{"prune", "", "", 10 * time.Minute, 0},
+			{"prune", "", "", 16 * time.Minute, 2},
+			{"reserve", "b", "ip1", 16 * time.Minute, 0},
+			{"prune", "", "", 16 * time.Minute, 0},
+		}},
+		{"lockout outlives pruning", nil, []step{
+			{"reserve", "a", "ip1", 0, 0},
+			{"fail", "a", "ip1", 0, 0},
+			{"reserve", "a", "ip1", time.Second, 0},
+			{"fail", "a", "ip1", time.Second, 0},
+			{"reserve", "a", "ip1", 3 * time.Second, 0},
+			{"fail", "a", "ip1", 3 * time.Minute, 0},
+			{"prune", "", "", 16 * time.Minute, 0},
+			{"reserve", "a", "ip2", 16 * time.Minute, 2 * time.Minute},
+		}},
+	}
+	// every case runs for registered addresses and again for unknown ones,
+	// which must be throttled the same way
+	for _, registered := range []bool{true, false} {
+		for _, tt := range tests {
+			name := tt.name + "/unknown"
+			if registered {
+				name = tt.name + "/registered"
+			}
+			t.Run(name, func(t *testing.T) {
+				r, ctx := newTestRepo(t)
+				addr := func(e string) string {
+					if registered {
+						return e + "@example.com"
+					}
+					return "nobody-" + e + "@example.com"
+				}
+				if registered {
+					for _, e := range []string{"a", "b", "c"} {
+						newTestUser(t, r, ctx, addr(e))
+					}
+				}
+				p := policy
+				if tt.policy != nil {
+					tt.policy(&p)
+				}
+				base := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+				for i, s := range tt.steps {
+					now := base.Add(s.at)
+					switch s.op {
+					case "reserve":
+						if got := r.ReserveLoginAttempt(ctx, addr(s.email), s.ip, p, now); got != s.want {
+							t.Fatalf("step %d: ReserveLoginAttempt(%s, %s) = %v, want %v", i+1, s.email, s.ip, got, s.want)
+						}
+					case "fail":
+						r.RecordLoginFailure(ctx, addr(s.email), s.ip, p, now)
+					case "ok":
+						r.RecordLoginSuccess(ctx, addr(s.email), s.ip)
+					case "release":
+						r.ReleaseLoginAttempt(ctx, addr(s.email), s.ip)
+					case "prune":
+						if got := r.PruneLoginThrottles(now, p.Lockout); got != int(s.want) {
+							t.Fatalf("step %d: PruneLoginThrottles = %d, want %d", i+1, got, s.want)
+						}
+					}
+				}
+			})
+		}
+	}
+}
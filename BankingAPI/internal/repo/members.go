@@ -0,0 +1,206 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/storage"
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrForbidden     = errors.New("forbidden")
+	ErrLimitExceeded = errors.New("amount exceeds member limit")
+	ErrAlreadyMember = errors.New("user already has access to this account")
+	ErrInvalidRole   = errors.New("invalid member role")
+	ErrNotInvited    = errors.New("no pending invitation")
+	ErrPrimaryOwner  = errors.New("the primary owner cannot be removed")
+	ErrNotMember     = errors.New("user is not a member of this account")
+)
+
+// Permission is an action a member may perform on an account.
+type Permission int
+
+const (
+	// PermView allows reading the account, its members and its history.
+	PermView Permission = iota
+	// PermInitiate allows moving money in or out of the account.
+	PermInitiate
+	// PermManage allows changing the account itself and its members.
+	PermManage
+)
+
+// allows reports whether a membership grants perm for amount.
+func allows(m *model.AccountMember, perm Permission, amount int64) error {
+	if m == nil || m.Status != model.MemberActive {
+		return ErrForbidden
+	}
+	switch m.Role {
+	case model.MemberOwner:
+		return nil
+	case model.MemberCoOwner:
+		// managing the account stays with the owner; see MemberCoOwner
+		if perm == PermManage {
+			return ErrForbidden
+		}
+		return nil
+	case model.MemberInitiator:
+		if perm == PermManage {
+			return ErrForbidden
+		}
+		if perm == PermInitiate && amount > m.Limit {
+			return ErrLimitExceeded
+		}
+		return nil
+	case model.MemberViewer:
+		if perm != PermView {
+			return ErrForbidden
+		}
+		return nil
+	}
+	return ErrForbidden
+}
+
+// Authorize loads an account and checks that userID may perform perm on
+// it. amount is only consulted for PermInitiate.
+func (r *Repo) Authorize(ctx context.Context, accountID, userID string, perm Permission, amount int64) (*model.Account, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	a, ok := r.accountLocked(ctx, accountID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := allows(r.store.Members[accountID][userID], perm, amount); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (r *Repo) addMemberLocked(m *model.AccountMember) {
+	if r.store.Members[m.AccountID] == nil {
+		r.store.Members[m.AccountID] = make(map[string]*model.AccountMember)
+	}
+	r.store.Members[m.AccountID][m.UserID] = m
+}
+
+// memberIDsLocked returns the users with active access to an account.
+func (r *Repo) memberIDsLocked(accountID string) []string {
+	out := []string{}
+	for id, m := range r.store.Members[accountID] {
+		if m.Status == model.MemberActive {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// InviteMember invites the user registered under email to an account.
+func (r *Repo) InviteMember(ctx context.Context, accountID, email string, role model.MemberRole, limit int64, invitedBy string) (*model.AccountMember, error) {
+	switch role {
+	case model.MemberCoOwner, model.MemberViewer:
+		limit = 0
+	case model.MemberInitiator:
+		if limit <= 0 {
+			return nil, errors.New("limit must be positive for initiators")
+		}
+	default:
+		return nil, ErrInvalidRole
+	}
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	a, ok := r.accountLocked(ctx, accountID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if a.Status == model.AccountClosed {
+		return nil, ErrAccountClosed
+	}
+	userID, ok := r.store.EmailIndex[storage.EmailKey(a.TenantID, email)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if m := r.store.Members[accountID][userID]; m != nil && m.Status != model.MemberRevoked {
+		return nil, ErrAlreadyMember
+	}
+	now := time.Now()
+	m := &model.AccountMember{
+		AccountID: accountID,
+		UserID:    userID,
+		Role:      role,
+		Limit:     limit,
+		Status:    model.MemberInvited,
+		InvitedBy: invitedBy,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.addMemberLocked(m)
+	r.auditLocked(invitedBy, "account.member_invited", "account", accountID, "", map[string]interface{}{
+		"user_id": userID,
+		"role":    role,
+		"limit":   limit,
+	})
+	return m, nil
+}
+
+// AcceptInvitation activates a pending invitation for userID.
+func (r *Repo) AcceptInvitation(ctx context.Context, accountID, userID string) (*model.AccountMember, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	if _, ok := r.accountLocked(ctx, accountID); !ok {
+		return nil, ErrNotInvited
+	}
+	m := r.store.Members[accountID][userID]
+	if m == nil || m.Status != model.MemberInvited {
+		return nil, ErrNotInvited
+	}
+	m.Status = model.MemberActive
+	m.UpdatedAt = time.Now()
+	r.auditLocked(userID, "account.member_joined", "account", accountID, "", map[string]interface{}{"user_id": userID, "role": m.Role})
+	return m, nil
+}
+
+// RevokeMember removes a member's access. The primary owner cannot be removed.
+func (r *Repo) RevokeMember(ctx context.Context, accountID, userID, actorID string) error {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	a, ok := r.accountLocked(ctx, accountID)
+	if !ok {
+		return ErrNotFound
+	}
+	if a.UserID == userID {
+		return ErrPrimaryOwner
+	}
+	m := r.store.Members[accountID][userID]
+	if m == nil || m.Status == model.MemberRevoked {
+		return ErrNotMember
+	}
+	m.Status = model.MemberRevoked
+	m.UpdatedAt = time.Now()
+	r.auditLocked(actorID, "account.member_revoked", "account", accountID, "", map[string]interface{}{"user_id": userID})
+	return nil
+}
+
+// ListMembers returns every membership of an account, including pending
+// and revoked ones.
+func (r *Repo) ListMembers(ctx context.Context, accountID string) ([]*model.AccountMember, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	out := []*model.AccountMember{}
+	for _, m := range r.store.Members[accountID] {
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// ListInvitations returns the pending invitations addressed to userID.
+func (r *Repo) ListInvitations(ctx context.Context, userID string) ([]*model.AccountMember, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	out := []*model.AccountMember{}
+	for _, members := range r.store.Members {
+		if m := members[userID]; m != nil && m.Status == model.MemberInvited {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
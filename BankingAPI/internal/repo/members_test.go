@@ -0,0 +1,38 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"errors"
+	"testing"
+)
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		role   model.MemberRole
+		status model.MemberStatus
+		perm   Permission
+		amount int64
+		want   error
+	}{
+		{model.MemberOwner, model.MemberActive, PermManage, 0, nil},
+		{model.MemberOwner, model.MemberActive, PermInitiate, 1 << 40, nil},
+		{model.MemberCoOwner, model.MemberActive, PermInitiate, 1 << 40, nil},
+		{model.MemberCoOwner, model.MemberActive, PermView, 0, nil},
+		{model.MemberCoOwner, model.MemberActive, PermManage, 0, ErrForbidden},
+		{model.MemberInitiator, model.MemberActive, PermInitiate, 500, nil},
+		{model.MemberInitiator, model.MemberActive, PermInitiate, 501, ErrLimitExceeded},
+		{model.MemberInitiator, model.MemberActive, PermManage, 0, ErrForbidden},
+		{model.MemberViewer, model.MemberActive, PermView, 0, nil},
+		{model.MemberViewer, model.MemberActive, PermInitiate, 0, ErrForbidden},
+		{model.MemberOwner, model.MemberInvited, PermView, 0, ErrForbidden},
+	}
+	for _, tt := range tests {
+		m := &model.AccountMember{Role: tt.role, Status: tt.status, Limit: 500}
+		if err := allows(m, tt.perm, tt.amount); !errors.Is(err, tt.want) {
+			t.Errorf("%s (%s) perm %d amount %d: %v, want %v", tt.role, tt.status, tt.perm, tt.amount, err, tt.want)
+		}
+	}
+	if err := allows(nil, PermView, 0); !errors.Is(err, ErrForbidden) {
+		t.Errorf("no membership: %v, want %v", err, ErrForbidden)
+	}
+}
@@ -0,0 +1,20 @@
+package repo
+
+import (
+	"context"
+	"time"
+)
+
+// ClaimMessageID records an inbound file's message ID for the tenant and
+// reports whether it was new. Banks reject a resubmitted message ID so a
+// file uploaded twice is not executed twice.
+func (r *Repo) ClaimMessageID(ctx context.Context, msgID string) bool {
+	key := TenantFrom(ctx) + "/" + msgID
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	if _, seen := r.store.MessageIDs[key]; seen {
+		return false
+	}
+	r.store.MessageIDs[key] = time.Now()
+	return true
+}
@@ -0,0 +1,31 @@
+package repo
+
+import (
+	"testing"
+)
+
+func TestClaimMessageID(t *testing.T) {
+	r, ctx := newTestRepo(t)
+	other := WithTenant(ctx, "other")
+	steps := []struct {
+		name  string
+		other bool
+		msgID string
+		want  bool
+	}{
+		{"first submission", false, "MSG-1", true},
+		{"duplicate", false, "MSG-1", false},
+		{"another message", false, "MSG-2", true},
+		{"same ID at another tenant", true, "MSG-1", true},
+		{"duplicate at another tenant", true, "MSG-1", false},
+	}
+	for _, s := range steps {
+		c := ctx
+		if s.other {
+			c = other
+		}
+		if got := r.ClaimMessageID(c, s.msgID); got != s.want {
+			t.Errorf("%s: ClaimMessageID(%q) = %v, want %v", s.name, s.msgID, got, s.want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/totp"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMFAEnabled      = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled  = errors.New("start TOTP enrolment first")
+	ErrMFANotEnabled   = errors.New("two-factor authentication is not enabled")
+	ErrInvalidMFACode  = errors.New("invalid code")
+	ErrInvalidMFAToken = errors.New("invalid or expired MFA token")
+)
+
+const (
+	recoveryCodeCount = 10
+	// maxMFAAttempts is how many wrong codes end a login challenge. Wrong
+	// codes also count against the login throttle, which is what stops
+	// guessing across challenges.
+	maxMFAAttempts = 5
+)
+
+// BeginTOTPEnrolment gives the user a new TOTP secret. It takes effect
+// once ConfirmTOTP has seen a code generated from it.
+func (r *Repo) BeginTOTPEnrolment(ctx context.Context, userID string) (string, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	u, ok := r.userLocked(ctx, userID)
+	if !ok {
+		return "", ErrNotFound
+	}
+	if u.TOTPEnabled {
+		return "", ErrMFAEnabled
+	}
+	u.TOTPSecret = totp.NewSecret()
+	u.TOTPLastStep = 0
+	u.UpdatedAt = time.Now()
+	return u.TOTPSecret, nil
+}
+
+// ConfirmTOTP enables two-factor authentication once code proves the
+// user's authenticator holds the enrolled secret, and returns a fresh set
+// of recovery codes. They are only ever shown here.
+func (r *Repo) ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	u, ok := r.userLocked(ctx, userID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if u.TOTPEnabled {
+		return nil, ErrMFAEnabled
+	}
+	if u.TOTPSecret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+	if !r.checkTOTPLocked(u, code) {
+		return nil, ErrInvalidMFACode
+	}
+	codes := r.newRecoveryCodesLocked(u)
+	u.TOTPEnabled = true
+	u.UpdatedAt = time.Now()
+	r.auditLocked(userID, "user.mfa_enabled", "user", userID, "", nil)
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after a
+// valid second factor.
+func (r *Repo) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	u, ok := r.userLocked(ctx, userID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !u.TOTPEnabled {
+		return nil, ErrMFANotEnabled
+	}
+	if !r.checkSecondFactorLocked(u, code) {
+		return nil, ErrInvalidMFACode
+	}
+	r.auditLocked(userID, "user.recovery_codes_regenerated", "user", userID, "", nil)
+	return r.newRecoveryCodesLocked(u), nil
+}
+
+// DisableTOTP turns two-factor authentication off after a valid second
+// factor.
+func (r *Repo) DisableTOTP(ctx context.Context, userID, code string) error {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	u, ok := r.userLocked(ctx, userID)
+	if !ok {
+		return ErrNotFound
+	}
+	if !u.TOTPEnabled {
+		return ErrMFANotEnabled
+	}
+	if !r.checkSecondFactorLocked(u, code) {
+		return ErrInvalidMFACode
+	}
+	clearTOTP(u)
+	u.UpdatedAt = time.Now()
+	r.auditLocked(userID, "user.mfa_disabled", "user", userID, "", nil)
+	return nil
+}
+
+func clearTOTP(u *model.User) {
+	u.TOTPEnabled = false
+	u.TOTPSecret = ""
+	u.TOTPLastStep = 0
+	u.RecoveryCodes = nil
+}
+
+// NewMFAChallenge opens the second step of a login for userID and returns
+// the token that completes it.
+func (r *Repo) NewMFAChallenge(ctx context.Context, userID string, ttl time.Duration) (string, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	u, ok := r.userLocked(ctx, userID)
+	if !ok {
+		return "", ErrNotFound
+	}
+	token, hash := newOpaqueToken()
+	r.store.MFAChallenges[hash] = &model.MFAChallenge{
+		TokenHash: hash,
+		TenantID:  u.TenantID,
+		UserID:    u.ID,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	return token, nil
+}
+
+// MFAChallengeUser returns the user a live login challenge is for.
+func (r *Repo) MFAChallengeUser(ctx context.Context, token string) (*model.User, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	c, ok := r.store.MFAChallenges[HashToken(token)]
+	if !ok || c.TenantID != TenantFrom(ctx) || time.Now().After(c.ExpiresAt) {
+		return nil, ErrInvalidMFAToken
+	}
+	u, ok := r.userLocked(ctx, c.UserID)
+	if !ok || !u.IsActive || !u.TOTPEnabled {
+		return nil, ErrInvalidMFAToken
+	}
+	return u, nil
+}
+
+// CompleteMFAChallenge checks a TOTP or recovery code against a login
+// challenge and returns the user it was for. A challenge is single use
+// and is dropped after too many wrong codes.
+func (r *Repo) CompleteMFAChallenge(ctx context.Context, token, code string) (*model.User, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	hash := HashToken(token)
+	c, ok := r.store.MFAChallenges[hash]
+	if !ok || c.TenantID != TenantFrom(ctx) {
+		return nil, ErrInvalidMFAToken
+	}
+	if time.Now().After(c.ExpiresAt) {
+		delete(r.store.MFAChallenges, hash)
+		return nil, ErrInvalidMFAToken
+	}
+	u, ok := r.userLocked(ctx, c.UserID)
+	if !ok || !u.IsActive || !u.TOTPEnabled {
+		delete(r.store.MFAChallenges, hash)
+		return nil, ErrInvalidMFAToken
+	}
+	if !r.checkSecondFactorLocked(u, code) {
+		c.Attempts++
+		if c.Attempts >= maxMFAAttempts {
+			delete(r.store.MFAChallenges, hash)
+			r.auditLocked(SystemActor, "user.mfa_challenge_failed", "user", u.ID, "too many wrong codes", nil)
+		}
+		return nil, ErrInvalidMFACode
+	}
+	delete(r.store.MFAChallenges, hash)
+	return u, nil
+}
+
+// PruneMFAChallenges drops expired login challenges.
+func (r *Repo) PruneMFAChallenges(now time.Time) int {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	n := 0
+	for hash, c := range r.store.MFAChallenges {
+		if now.After(c.ExpiresAt) {
+			delete(r.store.MFAChallenges, hash)
+			n++
+		}
+	}
+	return n
+}
+
+// checkTOTPLocked verifies a TOTP code, refusing codes from a time step
+// that was already used.
+func (r *Repo) checkTOTPLocked(u *model.User, code string) bool {
+	step, ok := totp.Verify(u.TOTPSecret, code, time.Now())
+	if !ok || step <= u.TOTPLastStep {
+		return false
+	}
+	u.TOTPLastStep = step
+	return true
+}
+
+// checkSecondFactorLocked accepts a TOTP code or spends a recovery code.
+func (r *Repo) checkSecondFactorLocked(u *model.User, code string) bool {
+	if r.checkTOTPLocked(u, code) {
+		return true
+	}
+	hash := HashToken(normalizeRecoveryCode(code))
+	for i, h := range u.RecoveryCodes {
+		if h == hash {
+			u.RecoveryCodes = append(u.RecoveryCodes[:i:i], u.RecoveryCodes[i+1:]...)
+			r.auditLocked(u.ID, "user.recovery_code_used", "user", u.ID, "", map[string]interface{}{"remaining": len(u.RecoveryCodes)})
+			return true
+		}
+	}
+	return false
+}
+
+// newRecoveryCodesLocked replaces u's recovery codes and returns them in
+// the form shown to the user, e.g. "k3h2m-9xq4p".
+func (r *Repo) newRecoveryCodesLocked(u *model.User) []string {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, recoveryCodeCount)
+	u.RecoveryCodes = make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+		c := strings.ToLower(enc.EncodeToString(b))[:10]
+		codes[i] = c[:5] + "-" + c[5:]
+		u.RecoveryCodes[i] = HashToken(c)
+	}
+	return codes
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
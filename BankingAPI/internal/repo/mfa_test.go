@@ -0,0 +1,51 @@
+package repo
+
+import (
+	"BankingAPI/internal/totp"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTOTPReplay(t *testing.T) {
+	r, ctx := newTestRepo(t)
+	u := newTestUser(t, r, ctx, "a@example.com")
+	secret, err := r.BeginTOTPEnrolment(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := totp.Step(time.Now())
+	code := func(offset int64) string {
+		c, err := totp.Code(secret, step+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	if _, err := r.ConfirmTOTP(ctx, u.ID, code(0)); err != nil {
+		t.Fatalf("ConfirmTOTP: %v", err)
+	}
+
+	// run in order: each accepted code moves the last used step on
+	tests := []struct {
+		name    string
+		code    string
+		wantErr error
+	}{
+		{"code used to enrol", code(0), ErrInvalidMFACode},
+		{"older step", code(-1), ErrInvalidMFACode},
+		{"next step", code(1), nil},
+		{"next step again", code(1), ErrInvalidMFACode},
+		{"step before the last used", code(0), ErrInvalidMFACode},
+	}
+	for _, tt := range tests {
+		token, err := r.NewMFAChallenge(ctx, u.ID, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = r.CompleteMFAChallenge(ctx, token, tt.code)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: CompleteMFAChallenge = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
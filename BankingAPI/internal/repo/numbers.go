@@ -0,0 +1,59 @@
+package repo
+
+import (
+	"BankingAPI/internal/iban"
+	"BankingAPI/internal/model"
+	"context"
+	"fmt"
+	"math/rand/v2"
+)
+
+// assignNumberLocked gives a a unique 10-digit account number (8 random
+// digits plus 2 check digits) and the IBAN built from the tenant's country
+// and bank code.
+func (r *Repo) assignNumberLocked(a *model.Account) error {
+	t, ok := r.store.Tenants[a.TenantID]
+	if !ok {
+		return fmt.Errorf("unknown tenant %q", a.TenantID)
+	}
+	for {
+		number := iban.AccountNumber(fmt.Sprintf("%08d", rand.IntN(100000000)))
+		if _, taken := r.store.NumberIndex[number]; taken {
+			continue
+		}
+		code, err := iban.Generate(t.IBANCountry, t.BankCode+number)
+		if err != nil {
+			return err
+		}
+		if _, taken := r.store.IBANIndex[code]; taken {
+			continue
+		}
+		a.Number = number
+		a.IBAN = code
+		r.store.NumberIndex[number] = a.ID
+		r.store.IBANIndex[code] = a.ID
+		return nil
+	}
+}
+
+// FindAccountByIBAN returns the account with the given normalized IBAN.
+func (r *Repo) FindAccountByIBAN(ctx context.Context, code string) (*model.Account, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	a, ok := r.accountLocked(ctx, r.store.IBANIndex[code])
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return a, nil
+}
+
+// FindAccountByNumber returns the account with the given account number.
+func (r *Repo) FindAccountByNumber(ctx context.Context, number string) (*model.Account, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	a, ok := r.accountLocked(ctx, r.store.NumberIndex[number])
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return a, nil
+}
@@ -0,0 +1,260 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidClient = errors.New("unknown client or bad client credentials")
+	ErrInvalidGrant  = errors.New("invalid, expired or already used authorization code")
+)
+
+// RegisterOAuthClient registers a third-party app. Confidential clients
+// get a secret, returned only here; public clients get none.
+func (r *Repo) RegisterOAuthClient(ctx context.Context, name string, redirectURIs []string, scopes []model.Scope, public bool, actorID string) (*model.OAuthClient, string, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	c := &model.OAuthClient{
+		ID:           uuid.NewString(),
+		TenantID:     TenantFrom(ctx),
+		Name:         name,
+		Public:       public,
+		RedirectURIs: redirectURIs,
+		Scopes:       scopes,
+		CreatedBy:    actorID,
+		CreatedAt:    time.Now(),
+	}
+	var secret string
+	if !public {
+		secret, c.SecretHash = newOpaqueToken()
+	}
+	r.store.OAuthClients[c.ID] = c
+	r.auditLocked(actorID, "oauth.client_registered", "oauth_client", c.ID, "", map[string]interface{}{"name": name, "scopes": scopes})
+	return c, secret, nil
+}
+
+// ListOAuthClients returns the tenant's clients, oldest first.
+func (r *Repo) ListOAuthClients(ctx context.Context) []*model.OAuthClient {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	tenantID := TenantFrom(ctx)
+	out := []*model.OAuthClient{}
+	for _, c := range r.store.OAuthClients {
+		if c.TenantID == tenantID {
+			out = append(out, c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// RevokeOAuthClient disables a client and ends every session it holds.
+func (r *Repo) RevokeOAuthClient(ctx context.Context, id, actorID string) error {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	c, ok := r.store.OAuthClients[id]
+	if !ok || c.TenantID != TenantFrom(ctx) {
+		return ErrNotFound
+	}
+	if c.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	c.RevokedAt = &now
+	for _, s := range r.store.Sessions {
+		if s.ClientID == id {
+			r.revokeSessionLocked(s, now)
+		}
+	}
+	r.auditLocked(actorID, "oauth.client_revoked", "oauth_client", c.ID, "", nil)
+	return nil
+}
+
+// OAuthClient returns an active client of the tenant.
+func (r *Repo) OAuthClient(ctx context.Context, id string) (*model.OAuthClient, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	c, ok := r.store.OAuthClients[id]
+	if !ok || c.TenantID != TenantFrom(ctx) || c.RevokedAt != nil {
+		return nil, ErrInvalidClient
+	}
+	return c, nil
+}
+
+// AuthenticateOAuthClient checks a client's credentials. Public clients
+// must not send a secret; confidential ones must send theirs.
+func (r *Repo) AuthenticateOAuthClient(ctx context.Context, id, secret string) (*model.OAuthClient, error) {
+	c, err := r.OAuthClient(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c.Public {
+		if secret != "" {
+			return nil, ErrInvalidClient
+		}
+		return c, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(HashToken(secret)), []byte(c.SecretHash)) != 1 {
+		return nil, ErrInvalidClient
+	}
+	return c, nil
+}
+
+// NewOAuthCode records the user's consent to give clientID scopes and
+// returns the authorization code to send to redirectURI.
+func (r *Repo) NewOAuthCode(ctx context.Context, clientID, userID, redirectURI string, scopes []model.Scope, codeChallenge string, ttl time.Duration) (string, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	u, ok := r.userLocked(ctx, userID)
+	if !ok || !u.IsActive {
+		return "", ErrNotFound
+	}
+	code, hash := newOpaqueToken()
+	r.store.OAuthCodes[hash] = &model.OAuthCode{
+		CodeHash:      hash,
+		TenantID:      u.TenantID,
+		ClientID:      clientID,
+		UserID:        u.ID,
+		RedirectURI:   redirectURI,
+		Scopes:        scopes,
+		CodeChallenge: codeChallenge,
+		ExpiresAt:     time.Now().Add(ttl),
+	}
+	r.auditLocked(userID, "oauth.consent_granted", "user", userID, "", map[string]interface{}{"client_id": clientID, "scopes": scopes})
+	return code, nil
+}
+
+// ExchangeOAuthCode spends an authorization code issued to clientID and
+// starts the session its tokens belong to. The redirect URI must be the
+// one the code was sent to and verifier must match the PKCE challenge. A
+// code presented twice has leaked, so the session it opened is revoked.
+func (r *Repo) ExchangeOAuthCode(ctx context.Context, clientID, code, redirectURI, verifier string, ttl time.Duration) (*model.Session, string, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	c, ok := r.store.OAuthCodes[HashToken(code)]
+	if !ok || c.TenantID != TenantFrom(ctx) || c.ClientID != clientID {
+		return nil, "", ErrInvalidGrant
+	}
+	now := time.Now()
+	if c.UsedAt != nil {
+		if s, ok := r.store.Sessions[c.SessionID]; ok {
+			r.revokeSessionLocked(s, now)
+		}
+		r.auditLocked(SystemActor, "oauth.code_reused", "user", c.UserID, "authorization code presented twice", map[string]interface{}{"client_id": clientID})
+		return nil, "", ErrInvalidGrant
+	}
+	if now.After(c.ExpiresAt) || c.RedirectURI != redirectURI || !pkceMatches(verifier, c.CodeChallenge) {
+		return nil, "", ErrInvalidGrant
+	}
+	if u, ok := r.store.Users[c.UserID]; !ok || !u.IsActive {
+		return nil, "", ErrInvalidGrant
+	}
+	if client, ok := r.store.OAuthClients[clientID]; !ok || client.RevokedAt != nil {
+		return nil, "", ErrInvalidClient
+	}
+	s := &model.Session{
+		ID:           uuid.NewString(),
+		TenantID:     c.TenantID,
+		UserID:       c.UserID,
+		LastUsedAt:   now,
+		CreatedAt:    now,
+		ClientID:     clientID,
+		Scopes:       c.Scopes,
+		AccessTokens: map[string]time.Time{},
+	}
+	r.store.Sessions[s.ID] = s
+	c.UsedAt = &now
+	c.SessionID = s.ID
+	return s, r.issueRefreshTokenLocked(s, now, ttl), nil
+}
+
+// pkceMatches reports whether verifier hashes to the S256 challenge.
+func pkceMatches(verifier, challenge string) bool {
+	sum := sha256.Sum256([]byte(verifier))
+	got := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(got), []byte(challenge)) == 1
+}
+
+// RefreshTokenSession returns the live session of an unused, unexpired
+// refresh token.
+func (r *Repo) RefreshTokenSession(ctx context.Context, token string) (*model.Session, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	rt, ok := r.store.RefreshTokens[HashToken(token)]
+	if !ok || rt.UsedAt != nil || time.Now().After(rt.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	s, ok := r.store.Sessions[rt.SessionID]
+	if !ok || s.TenantID != TenantFrom(ctx) || s.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	return s, nil
+}
+
+// RevokeAccessToken revokes a single access token until it expires.
+func (r *Repo) RevokeAccessToken(jti string, expires time.Time) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	r.store.RevokedTokens[jti] = expires
+}
+
+// ListOAuthGrants returns the live sessions OAuth clients hold for the
+// user, that is the apps the user has let in.
+func (r *Repo) ListOAuthGrants(ctx context.Context, userID string) []*model.Session {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	tenantID := TenantFrom(ctx)
+	out := []*model.Session{}
+	for _, s := range r.store.Sessions {
+		if s.UserID == userID && s.TenantID == tenantID && s.ClientID != "" && s.RevokedAt == nil {
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// RevokeOAuthGrant withdraws the user's consent for clientID, ending the
+// client's sessions for the user.
+func (r *Repo) RevokeOAuthGrant(ctx context.Context, userID, clientID string) error {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	tenantID := TenantFrom(ctx)
+	now := time.Now()
+	found := false
+	for _, s := range r.store.Sessions {
+		if s.UserID == userID && s.TenantID == tenantID && s.ClientID == clientID && s.RevokedAt == nil {
+			r.revokeSessionLocked(s, now)
+			found = true
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+	r.auditLocked(userID, "oauth.consent_revoked", "user", userID, "", map[string]interface{}{"client_id": clientID})
+	return nil
+}
+
+// PruneOAuthCodes drops expired authorization codes. Used codes are kept
+// until then so a replay can still be detected.
+func (r *Repo) PruneOAuthCodes(now time.Time) int {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	n := 0
+	for hash, c := range r.store.OAuthCodes {
+		if now.After(c.ExpiresAt) {
+			delete(r.store.OAuthCodes, hash)
+			n++
+		}
+	}
+	return n
+}
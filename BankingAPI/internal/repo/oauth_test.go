@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"errors"
+	"testing"
+	"time"
+)
+
+// RFC 7636 appendix B
+const (
+	testVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func TestPKCEMatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{"RFC 7636 example", testVerifier, testChallenge, true},
+		{"other verifier", testVerifier + "x", testChallenge, false},
+		{"empty verifier", "", testChallenge, false},
+		{"challenge sent as verifier", testChallenge, testChallenge, false},
+		{"empty challenge", testVerifier, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pkceMatches(tt.verifier, tt.challenge); got != tt.want {
+				t.Errorf("pkceMatches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchangeOAuthCode(t *testing.T) {
+	const redirect = "https://app.example.com/callback"
+	tests := []struct {
+		name     string
+		ttl      time.Duration
+		otherApp bool // exchange as a different client
+		redirect string
+		verifier string
+		wantErr  error
+	}{
+		{"valid", time.Minute, false, redirect, testVerifier, nil},
+		{"verifier mismatch", time.Minute, false, redirect, "wrong-verifier", ErrInvalidGrant},
+		{"no verifier", time.Minute, false, redirect, "", ErrInvalidGrant},
+		{"challenge sent as verifier", time.Minute, false, redirect, testChallenge, ErrInvalidGrant},
+		{"other redirect URI", time.Minute, false, "https://evil.example.com/", testVerifier, ErrInvalidGrant},
+		{"other client", time.Minute, true, redirect, testVerifier, ErrInvalidGrant},
+		{"expired code", -time.Second, false, redirect, testVerifier, ErrInvalidGrant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ctx := newTestRepo(t)
+			u := newTestUser(t, r, ctx, "a@example.com")
+			scopes := []model.Scope{model.ScopeAccountsRead}
+			app, _, err := r.RegisterOAuthClient(ctx, "app", []string{redirect}, scopes, true, u.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, _, err := r.RegisterOAuthClient(ctx, "other", []string{redirect}, scopes, true, u.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, err := r.NewOAuthCode(ctx, app.ID, u.ID, redirect, scopes, testChallenge, tt.ttl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			clientID := app.ID
+			if tt.otherApp {
+				clientID = other.ID
+			}
+			s, refresh, err := r.ExchangeOAuthCode(ctx, clientID, code, tt.redirect, tt.verifier, time.Hour)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExchangeOAuthCode = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (s.ClientID != app.ID || s.UserID != u.ID || refresh == "") {
+				t.Errorf("session = %+v, refresh %q", s, refresh)
+			}
+		})
+	}
+}
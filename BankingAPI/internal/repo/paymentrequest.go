@@ -0,0 +1,200 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPaymentRequestNotFound = errors.New("payment request not found")
+	ErrPaymentRequestSettled  = errors.New("payment request is no longer open")
+	ErrPaymentRequestExpired  = errors.New("payment request expired")
+	ErrCurrencyMismatch       = errors.New("currency mismatch")
+)
+
+// CreatePaymentRequest records a request to be paid into toAccountID. An
+// empty payerID makes the request payable by anyone holding the link. The
+// returned token is the pay link secret and is not stored.
+func (r *Repo) CreatePaymentRequest(ctx context.Context, requesterID, toAccountID, payerID string, amount int64, note string, ttl time.Duration) (*model.PaymentRequest, string, error) {
+	if amount <= 0 {
+		return nil, "", errors.New("amount must be positive")
+	}
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	a, ok := r.accountLocked(ctx, toAccountID)
+	if !ok {
+		return nil, "", ErrNotFound
+	}
+	if err := creditErr(a); err != nil {
+		return nil, "", err
+	}
+	if payerID != "" {
+		if _, ok := r.userLocked(ctx, payerID); !ok {
+			return nil, "", ErrNotFound
+		}
+	}
+	token, hash := newOpaqueToken()
+	now := time.Now()
+	pr := &model.PaymentRequest{
+		ID:          uuid.NewString(),
+		TenantID:    a.TenantID,
+		RequesterID: requesterID,
+		ToAccountID: a.ID,
+		PayerID:     payerID,
+		Amount:      amount,
+		Currency:    a.Currency,
+		Note:        note,
+		Status:      model.PaymentRequestOpen,
+		TokenHash:   hash,
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	r.store.PaymentRequests[pr.ID] = pr
+	r.store.PayLinkIndex[hash] = pr.ID
+	return pr, token, nil
+}
+
+// paymentRequestLocked returns a request of the tenant in ctx, expiring
+// it first if its time is up.
+func (r *Repo) paymentRequestLocked(ctx context.Context, id string) (*model.PaymentRequest, bool) {
+	pr, ok := r.store.PaymentRequests[id]
+	if !ok || pr.TenantID != TenantFrom(ctx) {
+		return nil, false
+	}
+	if pr.Status == model.PaymentRequestOpen && time.Now().After(pr.ExpiresAt) {
+		pr.Status = model.PaymentRequestExpired
+		pr.UpdatedAt = time.Now()
+	}
+	return pr, true
+}
+
+// visibleTo reports whether userID is a party to the request. Open
+// requests are also visible to whoever holds the link, see GetPaymentRequestByToken.
+func visibleTo(pr *model.PaymentRequest, userID string) bool {
+	return pr.RequesterID == userID || pr.PayerID == userID || pr.PaidBy == userID
+}
+
+// GetPaymentRequest returns a request userID is a party to.
+func (r *Repo) GetPaymentRequest(ctx context.Context, id, userID string) (*model.PaymentRequest, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	pr, ok := r.paymentRequestLocked(ctx, id)
+	if !ok || !visibleTo(pr, userID) {
+		return nil, ErrPaymentRequestNotFound
+	}
+	return pr, nil
+}
+
+// GetPaymentRequestByToken resolves a pay link.
+func (r *Repo) GetPaymentRequestByToken(ctx context.Context, token string) (*model.PaymentRequest, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	pr, ok := r.paymentRequestLocked(ctx, r.store.PayLinkIndex[HashToken(token)])
+	if !ok {
+		return nil, ErrPaymentRequestNotFound
+	}
+	return pr, nil
+}
+
+// ListPaymentRequests returns the requests userID made (outgoing), is
+// asked to pay (incoming), or both when direction is empty.
+func (r *Repo) ListPaymentRequests(ctx context.Context, userID, direction string) ([]*model.PaymentRequest, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	out := []*model.PaymentRequest{}
+	for id := range r.store.PaymentRequests {
+		pr, ok := r.paymentRequestLocked(ctx, id)
+		if !ok {
+			continue
+		}
+		outgoing := pr.RequesterID == userID
+		incoming := pr.PayerID == userID || pr.PaidBy == userID
+		if (direction == "outgoing" && outgoing) || (direction == "incoming" && incoming) || (direction == "" && (outgoing || incoming)) {
+			out = append(out, pr)
+		}
+	}
+	return out, nil
+}
+
+// PayPaymentRequest pays an open request from fromAccountID through
+// Transfer. A request addressed to a specific payer can only be paid by
+// them. If the transfer fails the request stays open.
+func (r *Repo) PayPaymentRequest(ctx context.Context, id, payerID, fromAccountID string) (*model.PaymentRequest, error) {
+	r.store.Mu.Lock()
+	pr, ok := r.paymentRequestLocked(ctx, id)
+	if !ok || (pr.PayerID != "" && pr.PayerID != payerID) {
+		r.store.Mu.Unlock()
+		return nil, ErrPaymentRequestNotFound
+	}
+	if pr.Status == model.PaymentRequestExpired {
+		r.store.Mu.Unlock()
+		return nil, ErrPaymentRequestExpired
+	}
+	if pr.Status != model.PaymentRequestOpen {
+		r.store.Mu.Unlock()
+		return nil, ErrPaymentRequestSettled
+	}
+	from, ok := r.accountLocked(ctx, fromAccountID)
+	if !ok {
+		r.store.Mu.Unlock()
+		return nil, ErrNotFound
+	}
+	if from.Currency != pr.Currency {
+		r.store.Mu.Unlock()
+		return nil, ErrCurrencyMismatch
+	}
+	// PROCESSING keeps a concurrent payment of the same request out
+	pr.Status = model.PaymentRequestProcessing
+	r.store.Mu.Unlock()
+
+	out, _, err := r.Transfer(ctx, payerID, fromAccountID, pr.ToAccountID, pr.Amount, map[string]interface{}{
+		"payment_request_id": pr.ID,
+		"note":               pr.Note,
+	})
+
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	pr.UpdatedAt = time.Now()
+	if err != nil {
+		pr.Status = model.PaymentRequestOpen
+		return nil, err
+	}
+	pr.Status = model.PaymentRequestPaid
+	pr.PaidBy = payerID
+	pr.PaidTxnID = out.ID
+	return pr, nil
+}
+
+// DeclinePaymentRequest lets the addressed payer turn a request down.
+func (r *Repo) DeclinePaymentRequest(ctx context.Context, id, payerID string) (*model.PaymentRequest, error) {
+	return r.settlePaymentRequest(ctx, id, model.PaymentRequestDeclined, func(pr *model.PaymentRequest) bool {
+		return pr.PayerID != "" && pr.PayerID == payerID
+	})
+}
+
+// CancelPaymentRequest lets the requester withdraw a request.
+func (r *Repo) CancelPaymentRequest(ctx context.Context, id, requesterID string) (*model.PaymentRequest, error) {
+	return r.settlePaymentRequest(ctx, id, model.PaymentRequestCancelled, func(pr *model.PaymentRequest) bool {
+		return pr.RequesterID == requesterID
+	})
+}
+
+func (r *Repo) settlePaymentRequest(ctx context.Context, id string, status model.PaymentRequestStatus, allowed func(*model.PaymentRequest) bool) (*model.PaymentRequest, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	pr, ok := r.paymentRequestLocked(ctx, id)
+	if !ok || !allowed(pr) {
+		return nil, ErrPaymentRequestNotFound
+	}
+	if pr.Status != model.PaymentRequestOpen {
+		return nil, ErrPaymentRequestSettled
+	}
+	pr.Status = status
+	pr.UpdatedAt = time.Now()
+	return pr, nil
+}
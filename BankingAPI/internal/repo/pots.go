@@ -0,0 +1,151 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPotNotFound       = errors.New("pot not found")
+	ErrPotInsufficient   = errors.New("insufficient funds in pot")
+	ErrAmountNotPositive = errors.New("amount must be positive")
+)
+
+// CreatePot adds an empty pot to an account.
+func (r *Repo) CreatePot(ctx context.Context, accountID, name string, goal int64, target *time.Time) (*model.Pot, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	a, ok := r.accountLocked(ctx, accountID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if a.Status == model.AccountClosed {
+		return nil, ErrAccountClosed
+	}
+	now := time.Now()
+	p := &model.Pot{
+		ID:         uuid.NewString(),
+		AccountID:  a.ID,
+		Name:       name,
+		GoalAmount: goal,
+		TargetDate: target,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	r.store.Pots[p.ID] = p
+	return p, nil
+}
+
+func (r *Repo) ListPots(ctx context.Context, accountID string) ([]*model.Pot, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	if _, ok := r.accountLocked(ctx, accountID); !ok {
+		return nil, ErrNotFound
+	}
+	out := []*model.Pot{}
+	for _, p := range r.store.Pots {
+		if p.AccountID == accountID {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// potLocked returns a pot of accountID together with the account.
+func (r *Repo) potLocked(ctx context.Context, accountID, potID string) (*model.Account, *model.Pot, error) {
+	a, ok := r.accountLocked(ctx, accountID)
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+	p, ok := r.store.Pots[potID]
+	if !ok || p.AccountID != a.ID {
+		return nil, nil, ErrPotNotFound
+	}
+	return a, p, nil
+}
+
+// MoveToPot sets amount of the account's available balance aside in a pot.
+func (r *Repo) MoveToPot(ctx context.Context, accountID, potID string, amount int64) (*model.Pot, *model.Transaction, error) {
+	if amount <= 0 {
+		return nil, nil, ErrAmountNotPositive
+	}
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	a, p, err := r.potLocked(ctx, accountID, potID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if a.Status == model.AccountClosed {
+		return nil, nil, ErrAccountClosed
+	}
+	if a.Available() < amount {
+		return nil, nil, ErrInsufficient
+	}
+	t := r.movePotLocked(a, p, model.PotIn, amount)
+	return p, t, nil
+}
+
+// MoveFromPot returns amount from a pot to the account's available balance.
+func (r *Repo) MoveFromPot(ctx context.Context, accountID, potID string, amount int64) (*model.Pot, *model.Transaction, error) {
+	if amount <= 0 {
+		return nil, nil, ErrAmountNotPositive
+	}
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	a, p, err := r.potLocked(ctx, accountID, potID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.Balance < amount {
+		return nil, nil, ErrPotInsufficient
+	}
+	t := r.movePotLocked(a, p, model.PotOut, amount)
+	return p, t, nil
+}
+
+// DeletePot empties a pot back into the account and removes it.
+func (r *Repo) DeletePot(ctx context.Context, accountID, potID string) error {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	a, p, err := r.potLocked(ctx, accountID, potID)
+	if err != nil {
+		return err
+	}
+	if p.Balance > 0 {
+		r.movePotLocked(a, p, model.PotOut, p.Balance)
+	}
+	delete(r.store.Pots, p.ID)
+	return nil
+}
+
+// releasePotsLocked empties every pot of a back into its available balance.
+func (r *Repo) releasePotsLocked(a *model.Account) {
+	for _, p := range r.store.Pots {
+		if p.AccountID == a.ID && p.Balance > 0 {
+			r.movePotLocked(a, p, model.PotOut, p.Balance)
+		}
+	}
+}
+
+// movePotLocked records a ledger movement between a and p. The account
+// Balance is unchanged; only the reserved part moves.
+func (r *Repo) movePotLocked(a *model.Account, p *model.Pot, typ model.TransactionType, amount int64) *model.Transaction {
+	now := time.Now()
+	if typ == model.PotIn {
+		p.Balance += amount
+		a.Reserved += amount
+	} else {
+		p.Balance -= amount
+		a.Reserved -= amount
+	}
+	p.UpdatedAt = now
+	a.UpdatedAt = now
+	t := newTransaction(a, typ, amount, map[string]interface{}{"pot_id": p.ID, "pot_name": p.Name})
+	r.store.Transactions[t.ID] = t
+	r.notifyLocked(a, t)
+	return t
+}
@@ -0,0 +1,154 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/storage"
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+var ErrErasureBlocked = errors.New("close or delete the accounts you own before requesting erasure")
+
+// UserExport is everything held about a user, for subject-access requests.
+type UserExport struct {
+	User          *model.User            `json:"user"`
+	Accounts      []*model.Account       `json:"accounts"`
+	Memberships   []*model.AccountMember `json:"memberships"`
+	Transactions  []*model.Transaction   `json:"transactions"`
+	AuditEvents   []*model.AuditEvent    `json:"audit_events"`
+	Beneficiaries []*model.Beneficiary   `json:"beneficiaries"`
+}
+
+// ExportUserData collects the user's profile, the accounts they have or
+// had access to with their transactions (archived ones included), their
+// saved beneficiaries and the audit events they caused or that concern
+// them.
+func (r *Repo) ExportUserData(ctx context.Context, userID string) (*UserExport, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	u, ok := r.userLocked(ctx, userID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	out := &UserExport{
+		User:          u,
+		Accounts:      []*model.Account{},
+		Memberships:   []*model.AccountMember{},
+		Transactions:  []*model.Transaction{},
+		AuditEvents:   []*model.AuditEvent{},
+		Beneficiaries: []*model.Beneficiary{},
+	}
+	accounts := map[string]bool{}
+	for id, members := range r.store.Members {
+		m := members[userID]
+		if m == nil {
+			continue
+		}
+		out.Memberships = append(out.Memberships, m)
+		if a, ok := r.accountLocked(ctx, id); ok && m.Status != model.MemberInvited {
+			out.Accounts = append(out.Accounts, a)
+			accounts[id] = true
+		}
+	}
+	for _, t := range r.store.Transactions {
+		if accounts[t.AccountID] {
+			out.Transactions = append(out.Transactions, t)
+		}
+	}
+	for _, arch := range r.store.ArchivedAccounts {
+		if arch.Account.TenantID == u.TenantID && arch.Account.UserID == userID {
+			out.Accounts = append(out.Accounts, arch.Account)
+			out.Transactions = append(out.Transactions, arch.Transactions...)
+			accounts[arch.Account.ID] = true
+		}
+	}
+	for _, e := range r.store.AuditLog {
+		if e.ActorID == userID || e.Data["user_id"] == userID || e.Data["holder_id"] == userID ||
+			(e.TargetType == "user" && e.TargetID == userID) ||
+			(e.TargetType == "account" && accounts[e.TargetID]) {
+			out.AuditEvents = append(out.AuditEvents, e)
+		}
+	}
+	for _, b := range r.store.Beneficiaries {
+		if b.UserID == userID {
+			out.Beneficiaries = append(out.Beneficiaries, b)
+		}
+	}
+	sort.Slice(out.Accounts, func(i, j int) bool { return out.Accounts[i].CreatedAt.Before(out.Accounts[j].CreatedAt) })
+	sort.Slice(out.Memberships, func(i, j int) bool { return out.Memberships[i].CreatedAt.Before(out.Memberships[j].CreatedAt) })
+	sort.Slice(out.Transactions, func(i, j int) bool { return out.Transactions[i].CreatedAt.Before(out.Transactions[j].CreatedAt) })
+	sort.Slice(out.Beneficiaries, func(i, j int) bool { return out.Beneficiaries[i].CreatedAt.Before(out.Beneficiaries[j].CreatedAt) })
+	return out, nil
+}
+
+// EraseUser removes a user's personal data. Email and name are cleared,
+// the email is freed for registration and the user is signed out, their
+// API keys are revoked and they can no longer log in; access to other
+// people's accounts is revoked, cards they hold are cancelled and their
+// beneficiaries and category preferences are dropped.
+// Accounts, transactions and audit events are kept, as the law requires,
+// and stay linked to the user ID, which carries no personal data. Erasure
+// is refused while the user still owns an account that is open.
+func (r *Repo) EraseUser(ctx context.Context, userID string) error {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	u, ok := r.userLocked(ctx, userID)
+	if !ok || u.ErasedAt != nil {
+		return ErrNotFound
+	}
+	for _, a := range r.store.Accounts {
+		if a.UserID == userID && a.DeletedAt == nil && a.Status != model.AccountClosed {
+			return ErrErasureBlocked
+		}
+	}
+
+	now := time.Now()
+	for accountID, members := range r.store.Members {
+		m := members[userID]
+		if m == nil || m.Status == model.MemberRevoked {
+			continue
+		}
+		if a := r.store.Accounts[accountID]; a == nil || a.UserID == userID {
+			continue
+		}
+		m.Status = model.MemberRevoked
+		m.UpdatedAt = now
+		r.auditLocked(userID, "account.member_revoked", "account", accountID, "user erased", map[string]interface{}{"user_id": userID})
+	}
+	for _, c := range r.store.Cards {
+		if c.HolderID == userID && c.Status != model.CardCancelled {
+			c.Status = model.CardCancelled
+			c.UpdatedAt = now
+		}
+	}
+	for id, b := range r.store.Beneficiaries {
+		if b.UserID == userID {
+			delete(r.store.Beneficiaries, id)
+		}
+	}
+	for hash, t := range r.store.UserTokens {
+		if t.UserID == userID {
+			delete(r.store.UserTokens, hash)
+		}
+	}
+	delete(r.store.TxnCategories, userID)
+	delete(r.store.MerchantCategories, userID)
+
+	key := storage.EmailKey(u.TenantID, u.Email)
+	if r.store.EmailIndex[key] == userID {
+		delete(r.store.EmailIndex, key)
+	}
+	u.Email = ""
+	u.Name = ""
+	u.PasswordHash = ""
+	clearTOTP(u)
+	u.IsActive = false
+	u.ErasedAt = &now
+	u.UpdatedAt = now
+	r.revokeUserSessionsLocked(userID, now)
+	r.revokeUserAPIKeysLocked(userID, now)
+	r.auditLocked(userID, "user.erased", "user", userID, "", nil)
+	return nil
+}
@@ -0,0 +1,255 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/reconcile"
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRecordNotFound = errors.New("external record not found")
+	ErrAlreadyMatched = errors.New("already matched")
+	ErrNotMatched     = errors.New("record is not matched")
+	ErrAmountMismatch = errors.New("record and transaction amounts differ")
+)
+
+// MatchedByAuto is the MatchedBy value of automatic matches.
+const MatchedByAuto = "auto"
+
+// ImportResult summarises an import of external records.
+type ImportResult struct {
+	ImportID    string `json:"import_id"`
+	Source      string `json:"source"`
+	Imported    int    `json:"imported"`
+	Duplicates  int    `json:"duplicates"`
+	AutoMatched int    `json:"auto_matched"`
+}
+
+// sameRecord reports whether two records look like the same external
+// line, so re-importing a file does not duplicate it.
+func sameRecord(a, b *model.ExternalRecord) bool {
+	return a.Date.Equal(b.Date) && a.Amount == b.Amount && a.Reference == b.Reference && a.Description == b.Description
+}
+
+// ImportExternalRecords parses a CSV or camt.053 file of external records
+// for an account, stores the new ones and auto-matches every unmatched
+// record of the account.
+func (r *Repo) ImportExternalRecords(ctx context.Context, accountID string, data []byte, window time.Duration) (*ImportResult, error) {
+	a, err := r.GetAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	recs, source, err := reconcile.Parse(data, a.Currency)
+	if err != nil {
+		return nil, err
+	}
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	res := &ImportResult{ImportID: uuid.NewString(), Source: source}
+	existing := r.recordsLocked(accountID)
+	now := time.Now()
+	for _, rec := range recs {
+		if rec.Currency != a.Currency {
+			return nil, ErrCurrencyNotAllowed
+		}
+	}
+	for _, rec := range recs {
+		// each stored record absorbs one line, so identical lines within
+		// a statement are kept apart while re-importing it adds nothing
+		dup := false
+		for i, e := range existing {
+			if sameRecord(e, rec) {
+				existing = append(existing[:i:i], existing[i+1:]...)
+				dup = true
+				break
+			}
+		}
+		if dup {
+			res.Duplicates++
+			continue
+		}
+		rec.ID = uuid.NewString()
+		rec.AccountID = accountID
+		rec.ImportID = res.ImportID
+		rec.Source = source
+		rec.CreatedAt = now
+		r.store.ExternalRecords[rec.ID] = rec
+		res.Imported++
+	}
+	res.AutoMatched = r.autoMatchLocked(accountID, window)
+	return res, nil
+}
+
+func (r *Repo) recordsLocked(accountID string) []*model.ExternalRecord {
+	out := []*model.ExternalRecord{}
+	for _, rec := range r.store.ExternalRecords {
+		if rec.AccountID == accountID {
+			out = append(out, rec)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Date.Before(out[j].Date) })
+	return out
+}
+
+// ledgerTxnsLocked returns the balance-changing transactions of an account.
+func (r *Repo) ledgerTxnsLocked(accountID string) []*model.Transaction {
+	out := []*model.Transaction{}
+	for _, t := range r.store.Transactions {
+		if t.AccountID == accountID && BalanceDelta(t) != 0 {
+			out = append(out, t)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+func (r *Repo) autoMatchLocked(accountID string, window time.Duration) int {
+	var open []*model.ExternalRecord
+	for _, rec := range r.recordsLocked(accountID) {
+		if rec.TransactionID == "" {
+			open = append(open, rec)
+		}
+	}
+	var candidates []reconcile.Candidate
+	for _, t := range r.ledgerTxnsLocked(accountID) {
+		if _, matched := r.store.ReconciledTxns[t.ID]; !matched {
+			candidates = append(candidates, reconcile.Candidate{Txn: t, Amount: BalanceDelta(t)})
+		}
+	}
+	matches := reconcile.Match(open, candidates, window)
+	for recID, txnID := range matches {
+		r.matchLocked(r.store.ExternalRecords[recID], txnID, MatchedByAuto)
+	}
+	return len(matches)
+}
+
+func (r *Repo) matchLocked(rec *model.ExternalRecord, txnID, by string) {
+	now := time.Now()
+	rec.TransactionID = txnID
+	rec.MatchedBy = by
+	rec.MatchedAt = &now
+	r.store.ReconciledTxns[txnID] = rec.ID
+}
+
+func (r *Repo) externalRecordLocked(ctx context.Context, accountID, recordID string) (*model.ExternalRecord, error) {
+	if _, ok := r.accountLocked(ctx, accountID); !ok {
+		return nil, ErrNotFound
+	}
+	rec, ok := r.store.ExternalRecords[recordID]
+	if !ok || rec.AccountID != accountID {
+		return nil, ErrRecordNotFound
+	}
+	return rec, nil
+}
+
+// MatchRecord manually pairs an external record with a transaction of the
+// same account and amount.
+func (r *Repo) MatchRecord(ctx context.Context, accountID, recordID, txnID, actorID string) (*model.ExternalRecord, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	rec, err := r.externalRecordLocked(ctx, accountID, recordID)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := r.store.Transactions[txnID]
+	if !ok || t.AccountID != accountID || BalanceDelta(t) == 0 {
+		return nil, ErrNotFound
+	}
+	if rec.TransactionID != "" {
+		return nil, ErrAlreadyMatched
+	}
+	if _, matched := r.store.ReconciledTxns[txnID]; matched {
+		return nil, ErrAlreadyMatched
+	}
+	if BalanceDelta(t) != rec.Amount {
+		return nil, ErrAmountMismatch
+	}
+	r.matchLocked(rec, txnID, actorID)
+	r.auditLocked(actorID, "reconciliation.matched", "account", accountID, "", map[string]interface{}{"record_id": rec.ID, "transaction_id": txnID})
+	return rec, nil
+}
+
+// UnmatchRecord undoes a match, automatic or manual. Auto-matching will
+// not pair the record with that transaction again.
+func (r *Repo) UnmatchRecord(ctx context.Context, accountID, recordID, actorID string) (*model.ExternalRecord, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	rec, err := r.externalRecordLocked(ctx, accountID, recordID)
+	if err != nil {
+		return nil, err
+	}
+	if rec.TransactionID == "" {
+		return nil, ErrNotMatched
+	}
+	r.auditLocked(actorID, "reconciliation.unmatched", "account", accountID, "", map[string]interface{}{"record_id": rec.ID, "transaction_id": rec.TransactionID, "matched_by": rec.MatchedBy})
+	delete(r.store.ReconciledTxns, rec.TransactionID)
+	rec.RejectedTxnIDs = append(rec.RejectedTxnIDs, rec.TransactionID)
+	rec.TransactionID = ""
+	rec.MatchedBy = ""
+	rec.MatchedAt = nil
+	return rec, nil
+}
+
+// MatchedPair is a reconciled record and its transaction.
+type MatchedPair struct {
+	Record      *model.ExternalRecord `json:"record"`
+	Transaction *model.Transaction    `json:"transaction"`
+}
+
+// ReconciliationReport is the reconciliation state of an account over a
+// period: what matched and what is left on either side.
+type ReconciliationReport struct {
+	AccountID             string                  `json:"account_id"`
+	From                  time.Time               `json:"from"`
+	To                    time.Time               `json:"to"`
+	Matched               []MatchedPair           `json:"matched"`
+	UnmatchedRecords      []*model.ExternalRecord `json:"unmatched_records"`
+	UnmatchedTransactions []*model.Transaction    `json:"unmatched_transactions"`
+	// UnmatchedRecordTotal and UnmatchedTransactionTotal are the signed
+	// sums of the unmatched items.
+	UnmatchedRecordTotal      int64 `json:"unmatched_record_total"`
+	UnmatchedTransactionTotal int64 `json:"unmatched_transaction_total"`
+}
+
+// Reconciliation reports on the records and transactions dated in
+// [from, to).
+func (r *Repo) Reconciliation(ctx context.Context, accountID string, from, to time.Time) (*ReconciliationReport, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	if _, ok := r.accountLocked(ctx, accountID); !ok {
+		return nil, ErrNotFound
+	}
+	in := func(t time.Time) bool { return !t.Before(from) && t.Before(to) }
+	rep := &ReconciliationReport{
+		AccountID:             accountID,
+		From:                  from,
+		To:                    to,
+		Matched:               []MatchedPair{},
+		UnmatchedRecords:      []*model.ExternalRecord{},
+		UnmatchedTransactions: []*model.Transaction{},
+	}
+	for _, rec := range r.recordsLocked(accountID) {
+		if !in(rec.Date) {
+			continue
+		}
+		if rec.TransactionID == "" {
+			rep.UnmatchedRecords = append(rep.UnmatchedRecords, rec)
+			rep.UnmatchedRecordTotal += rec.Amount
+			continue
+		}
+		rep.Matched = append(rep.Matched, MatchedPair{Record: rec, Transaction: r.store.Transactions[rec.TransactionID]})
+	}
+	for _, t := range r.ledgerTxnsLocked(accountID) {
+		if _, matched := r.store.ReconciledTxns[t.ID]; matched || !in(t.CreatedAt) {
+			continue
+		}
+		rep.UnmatchedTransactions = append(rep.UnmatchedTransactions, t)
+		rep.UnmatchedTransactionTotal += BalanceDelta(t)
+	}
+	return rep, nil
+}
@@ -5,6 +5,7 @@ import (
 	"BankingAPI/internal/storage"
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"sync"
 	"time"
@@ -17,11 +18,28 @@ var (
 	ErrUnauthorized    = errors.New("unauthorized")
 	ErrInsufficient    = errors.New("insufficient funds")
 	ErrAccountInactive = errors.New("account inactive")
+	ErrAccountFrozen   = errors.New("account frozen")
+	ErrAccountDormant  = errors.New("account dormant")
+	ErrAccountClosed   = errors.New("account closed")
+	ErrInvalidStatus   = errors.New("invalid status transition")
+	ErrBalanceNotZero  = errors.New("account balance is not zero")
+	ErrPendingItems    = errors.New("account has pending holds or scheduled payments")
+	ErrInvalidSweep    = errors.New("sweep account must be another open account of the same owner and currency")
 )
 
+// SystemActor is recorded as the actor of changes made by background jobs.
+const SystemActor = "system"
+
+// Notifier receives account events once a change has been applied.
+// Implementations must not block.
+type Notifier interface {
+	Publish(userID, eventType string, data interface{})
+}
+
 type Repo struct {
-	store *storage.InMemoryStore
-	mu    sync.Mutex // to ensure atomic operations across multiple accounts
+	store    *storage.InMemoryStore
+	mu       sync.Mutex // to ensure atomic operations across multiple accounts
+	notifier Notifier
 }
 
 func (r *Repo) HandleFunc(s string, register func(w http.ResponseWriter, r *http.Request)) {
@@ -32,10 +50,35 @@ func NewRepo(s *storage.InMemoryStore) *Repo {
 	return &Repo{store: s}
 }
 
+// SetNotifier registers the receiver of balance and transaction events.
+func (r *Repo) SetNotifier(n Notifier) {
+	r.notifier = n
+}
+
+// notifyLocked publishes the new balance of a and, if non-nil, the
+// transaction that produced it to every member of the account.
+func (r *Repo) notifyLocked(a *model.Account, t *model.Transaction) {
+	if r.notifier == nil {
+		return
+	}
+	balance := model.BalanceUpdate{AccountID: a.ID, Balance: a.Balance, Currency: a.Currency, UpdatedAt: a.UpdatedAt}
+	for _, userID := range r.memberIDsLocked(a.ID) {
+		if t != nil {
+			r.notifier.Publish(userID, "transaction", t)
+		}
+		r.notifier.Publish(userID, "balance", balance)
+	}
+}
+
 func (r *Repo) CreateUser(ctx context.Context, u *model.User) (*model.User, error) {
 	r.store.Mu.Lock()
 	defer r.store.Mu.Unlock()
-	if _, exists := r.store.EmailIndex[u.Email]; exists {
+	u.TenantID = TenantFrom(ctx)
+	if _, ok := r.store.Tenants[u.TenantID]; !ok {
+		return nil, errors.New("unknown tenant")
+	}
+	key := storage.EmailKey(u.TenantID, u.Email)
+	if _, exists := r.store.EmailIndex[key]; exists {
 		return nil, errors.New("email already registered")
 	}
 	u.ID = uuid.NewString()
@@ -43,14 +86,14 @@ func (r *Repo) CreateUser(ctx context.Context, u *model.User) (*model.User, erro
 	u.UpdatedAt = time.Now()
 	u.IsActive = true
 	r.store.Users[u.ID] = u
-	r.store.EmailIndex[u.Email] = u.ID
+	r.store.EmailIndex[key] = u.ID
 	return u, nil
 }
 
 func (r *Repo) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
 	r.store.Mu.RLock()
 	defer r.store.Mu.RUnlock()
-	id, ok := r.store.EmailIndex[email]
+	id, ok := r.store.EmailIndex[storage.EmailKey(TenantFrom(ctx), email)]
 	if !ok {
 		return nil, ErrNotFound
 	}
@@ -61,7 +104,7 @@ func (r *Repo) GetUserByEmail(ctx context.Context, email string) (*model.User, e
 func (r *Repo) GetUserByID(ctx context.Context, id string) (*model.User, error) {
 	r.store.Mu.RLock()
 	defer r.store.Mu.RUnlock()
-	u, ok := r.store.Users[id]
+	u, ok := r.userLocked(ctx, id)
 	if !ok {
 		return nil, ErrNotFound
 	}
@@ -71,20 +114,36 @@ func (r *Repo) GetUserByID(ctx context.Context, id string) (*model.User, error)
 func (r *Repo) CreateAccount(ctx context.Context, a *model.Account) (*model.Account, error) {
 	r.store.Mu.Lock()
 	defer r.store.Mu.Unlock()
+	a.TenantID = TenantFrom(ctx)
+	if !r.currencyAllowedLocked(a.TenantID, a.Currency) {
+		return nil, ErrCurrencyNotAllowed
+	}
 	a.ID = uuid.NewString()
 	a.CreatedAt = time.Now()
 	a.UpdatedAt = time.Now()
-	if !a.IsActive {
-		a.IsActive = true
+	a.LastActivityAt = a.CreatedAt
+	if a.Status == "" {
+		a.Status = model.AccountActive
+	}
+	if err := r.assignNumberLocked(a); err != nil {
+		return nil, err
 	}
 	r.store.Accounts[a.ID] = a
+	r.addMemberLocked(&model.AccountMember{
+		AccountID: a.ID,
+		UserID:    a.UserID,
+		Role:      model.MemberOwner,
+		Status:    model.MemberActive,
+		CreatedAt: a.CreatedAt,
+		UpdatedAt: a.CreatedAt,
+	})
 	return a, nil
 }
 
 func (r *Repo) GetAccount(ctx context.Context, id string) (*model.Account, error) {
 	r.store.Mu.RLock()
 	defer r.store.Mu.RUnlock()
-	a, ok := r.store.Accounts[id]
+	a, ok := r.accountLocked(ctx, id)
 	if !ok {
 		return nil, ErrNotFound
 	}
@@ -95,8 +154,12 @@ func (r *Repo) ListAccountsByUser(ctx context.Context, userID string, currency s
 	r.store.Mu.RLock()
 	defer r.store.Mu.RUnlock()
 	out := []*model.Account{}
+	tenantID := TenantFrom(ctx)
 	for _, a := range r.store.Accounts {
-		if a.UserID != userID || !a.IsActive {
+		if a.TenantID != tenantID {
+			continue
+		}
+		if m := r.store.Members[a.ID][userID]; m == nil || m.Status != model.MemberActive || a.DeletedAt != nil {
 			continue
 		}
 		if currency != "" && a.Currency != currency {
@@ -110,83 +173,229 @@ func (r *Repo) ListAccountsByUser(ctx context.Context, userID string, currency s
 	return out, nil
 }
 
-func (r *Repo) UpdateAccount(ctx context.Context, id string, name *string, isActive *bool) (*model.Account, error) {
+func (r *Repo) UpdateAccount(ctx context.Context, id string, name *string) (*model.Account, error) {
 	r.store.Mu.Lock()
 	defer r.store.Mu.Unlock()
-	a, ok := r.store.Accounts[id]
+	a, ok := r.accountLocked(ctx, id)
 	if !ok {
 		return nil, ErrNotFound
 	}
+	if a.Status == model.AccountClosed {
+		return nil, ErrAccountClosed
+	}
 	if name != nil {
 		a.Name = *name
 	}
-	if isActive != nil {
-		a.IsActive = *isActive
-		if !a.IsActive {
-
-		}
-	}
 	a.UpdatedAt = time.Now()
 	return a, nil
 }
 
-// Soft delete: set IsActive = false
-func (r *Repo) DeleteAccount(ctx context.Context, id string) error {
+// DeleteAccount soft-deletes an empty account. It can be restored with
+// RestoreAccount until the retention job archives it.
+func (r *Repo) DeleteAccount(ctx context.Context, id, actorID string) error {
 	r.store.Mu.Lock()
 	defer r.store.Mu.Unlock()
-	a, ok := r.store.Accounts[id]
-	if !ok {
+	a, ok := r.accountLocked(ctx, id)
+	if !ok || a.DeletedAt != nil {
 		return ErrNotFound
 	}
-	a.IsActive = false
+	if a.Balance != 0 {
+		return ErrBalanceNotZero
+	}
+	now := time.Now()
+	a.DeletedAt = &now
+	a.UpdatedAt = now
+	r.auditLocked(actorID, "account.deleted", "account", a.ID, "", nil)
+	return nil
+}
+
+// SetAccountStatus moves the account to a new status if the transition is
+// allowed, recording the actor and reason in the audit log.
+func (r *Repo) SetAccountStatus(ctx context.Context, id string, to model.AccountStatus, actorID, reason string) (*model.Account, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	a, ok := r.accountLocked(ctx, id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := r.setStatusLocked(a, to, actorID, reason); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (r *Repo) setStatusLocked(a *model.Account, to model.AccountStatus, actorID, reason string) error {
+	if a.AdminFrozen {
+		return ErrAdminFrozen
+	}
+	if !a.Status.CanTransition(to) {
+		return ErrInvalidStatus
+	}
+	if to == model.AccountClosed && a.Balance != 0 {
+		return ErrBalanceNotZero
+	}
+	from := a.Status
+	a.Status = to
 	a.UpdatedAt = time.Now()
+	if to == model.AccountActive {
+		// reactivation counts as activity so the account is not
+		// immediately marked dormant again
+		a.LastActivityAt = a.UpdatedAt
+	}
+	r.auditLocked(actorID, "account.status_changed", "account", a.ID, reason, map[string]interface{}{
+		"from": from,
+		"to":   to,
+	})
 	return nil
 }
 
+// MarkDormant moves active accounts without customer activity since
+// before to DORMANT and returns them. An account that cannot be moved is
+// skipped and its error collected, so one bad account does not hold up
+// the rest.
+func (r *Repo) MarkDormant(ctx context.Context, before time.Time) ([]*model.Account, []error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	out := []*model.Account{}
+	var errs []error
+	for _, a := range r.store.Accounts {
+		if a.Status != model.AccountActive || !a.LastActivityAt.Before(before) {
+			continue
+		}
+		if err := r.setStatusLocked(a, model.AccountDormant, SystemActor, "no activity since "+a.LastActivityAt.Format(time.RFC3339)); err != nil {
+			errs = append(errs, fmt.Errorf("account %s: %w", a.ID, err))
+			continue
+		}
+		out = append(out, a)
+	}
+	return out, errs
+}
+
+// ListAccountAudit returns the audit events recorded against an account,
+// oldest first.
+func (r *Repo) ListAccountAudit(ctx context.Context, accountID string) ([]*model.AuditEvent, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	out := []*model.AuditEvent{}
+	for _, e := range r.store.AuditLog {
+		if e.TargetType == "account" && e.TargetID == accountID {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (r *Repo) auditLocked(actorID, action, targetType, targetID, reason string, data map[string]interface{}) {
+	r.store.AuditLog = append(r.store.AuditLog, &model.AuditEvent{
+		ID:         uuid.NewString(),
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Reason:     reason,
+		Data:       data,
+		CreatedAt:  time.Now(),
+	})
+}
+
+// creditErr returns why a cannot receive funds, or nil.
+func creditErr(a *model.Account) error {
+	if a.DeletedAt != nil {
+		return ErrAccountInactive
+	}
+	if a.Status.CanCredit() {
+		return nil
+	}
+	return ErrAccountClosed
+}
+
+// debitErr returns why money cannot leave a, or nil.
+func debitErr(a *model.Account) error {
+	if a.DeletedAt != nil {
+		return ErrAccountInactive
+	}
+	if a.Status.CanDebit() {
+		return nil
+	}
+	switch a.Status {
+	case model.AccountFrozen:
+		return ErrAccountFrozen
+	case model.AccountDormant:
+		return ErrAccountDormant
+	case model.AccountClosed:
+		return ErrAccountClosed
+	}
+	return ErrAccountInactive
+}
+
 func (r *Repo) Deposit(ctx context.Context, accountID string, amount int64, meta map[string]interface{}) (*model.Transaction, error) {
 	if amount <= 0 {
 		return nil, errors.New("amount must be positive")
 	}
 	r.store.Mu.Lock()
 	defer r.store.Mu.Unlock()
-	a, ok := r.store.Accounts[accountID]
+	a, ok := r.accountLocked(ctx, accountID)
 	if !ok {
 		return nil, ErrNotFound
 	}
-	if !a.IsActive {
-		return nil, ErrAccountInactive
+	if err := creditErr(a); err != nil {
+		return nil, err
 	}
 	a.Balance += amount
 	a.UpdatedAt = time.Now()
-	t := &model.Transaction{ID: uuid.NewString(), AccountID: a.ID, Type: model.Deposit, Amount: amount, Meta: meta, CreatedAt: time.Now()}
+	a.LastActivityAt = a.UpdatedAt
+	t := newTransaction(a, model.Deposit, amount, meta)
 	r.store.Transactions[t.ID] = t
+	r.notifyLocked(a, t)
 	return t, nil
 }
 
-func (r *Repo) Withdraw(ctx context.Context, accountID string, amount int64, meta map[string]interface{}) (*model.Transaction, error) {
+// Withdraw takes amount out of an account. Withdrawals cannot wait for a
+// second user, so one that would need approval above approvalThreshold is
+// refused with ErrApprovalRequired.
+func (r *Repo) Withdraw(ctx context.Context, accountID string, amount, approvalThreshold int64, meta map[string]interface{}) (*model.Transaction, error) {
 	if amount <= 0 {
 		return nil, errors.New("amount must be positive")
 	}
 	r.store.Mu.Lock()
 	defer r.store.Mu.Unlock()
-	a, ok := r.store.Accounts[accountID]
+	a, ok := r.accountLocked(ctx, accountID)
 	if !ok {
 		return nil, ErrNotFound
 	}
-	if !a.IsActive {
-		return nil, ErrAccountInactive
+	if err := debitErr(a); err != nil {
+		return nil, err
+	}
+	if NeedsApproval(a, amount, approvalThreshold) {
+		return nil, ErrApprovalRequired
 	}
-	if a.Balance < amount {
+	if a.Available() < amount {
 		return nil, ErrInsufficient
 	}
 	a.Balance -= amount
 	a.UpdatedAt = time.Now()
-	t := &model.Transaction{ID: uuid.NewString(), AccountID: a.ID, Type: model.Withdraw, Amount: amount, Meta: meta, CreatedAt: time.Now()}
+	a.LastActivityAt = a.UpdatedAt
+	t := newTransaction(a, model.Withdraw, amount, meta)
 	r.store.Transactions[t.ID] = t
+	r.notifyLocked(a, t)
 	return t, nil
 }
 
-func (r *Repo) Transfer(ctx context.Context, fromID, toID string, amount int64, meta map[string]interface{}) (*model.Transaction, *model.Transaction, error) {
+// WithMeta returns a copy of meta with key set, so client-supplied maps
+// are never mutated.
+func WithMeta(meta map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(meta)+1)
+	for k, v := range meta {
+		out[k] = v
+	}
+	out[key] = value
+	return out
+}
+
+// Transfer moves amount between two accounts of the tenant on behalf of
+// initiatorID, who is held to the cooling-off limits of their saved
+// beneficiaries. The outgoing transaction records the initiator.
+func (r *Repo) Transfer(ctx context.Context, initiatorID, fromID, toID string, amount int64, meta map[string]interface{}) (*model.Transaction, *model.Transaction, error) {
 	if amount <= 0 {
 		return nil, nil, errors.New("amount must be positive")
 	}
@@ -197,30 +406,46 @@ func (r *Repo) Transfer(ctx context.Context, fromID, toID string, amount int64,
 	r.store.Mu.Lock()
 	defer r.store.Mu.Unlock()
 
-	from, ok := r.store.Accounts[fromID]
+	from, ok := r.accountLocked(ctx, fromID)
 	if !ok {
 		return nil, nil, ErrNotFound
 	}
-	to, ok := r.store.Accounts[toID]
+	to, ok := r.accountLocked(ctx, toID)
 	if !ok {
 		return nil, nil, ErrNotFound
 	}
-	if !from.IsActive || !to.IsActive {
-		return nil, nil, ErrAccountInactive
+	if err := debitErr(from); err != nil {
+		return nil, nil, err
+	}
+	if err := creditErr(to); err != nil {
+		return nil, nil, err
 	}
-	if from.Balance < amount {
+	if from.Available() < amount {
 		return nil, nil, ErrInsufficient
 	}
+	// transfers are matched to a payee by destination, however it was
+	// addressed
+	if payee := r.coolingOffPayeeLocked(initiatorID, to.ID, time.Now()); payee != nil {
+		if payee.SentDuringCoolingOff+amount > payee.CoolingOffLimit {
+			return nil, nil, ErrCoolingOffLimit
+		}
+		payee.SentDuringCoolingOff += amount
+	}
 
 	from.Balance -= amount
 	to.Balance += amount
 	from.UpdatedAt = time.Now()
 	to.UpdatedAt = time.Now()
+	from.LastActivityAt = from.UpdatedAt
 
-	txnOut := &model.Transaction{ID: uuid.NewString(), AccountID: from.ID, Type: model.Withdraw, Amount: amount, Meta: meta, CreatedAt: time.Now()}
+	outMeta := WithMeta(meta, "counterparty_account_id", to.ID)
+	outMeta["initiated_by"] = initiatorID
+	txnOut := newTransaction(from, model.Withdraw, amount, outMeta)
 	r.store.Transactions[txnOut.ID] = txnOut
-	txnIn := &model.Transaction{ID: uuid.NewString(), AccountID: to.ID, Type: model.Deposit, Amount: amount, Meta: meta, CreatedAt: time.Now()}
+	txnIn := newTransaction(to, model.Deposit, amount, WithMeta(meta, "counterparty_account_id", from.ID))
 	r.store.Transactions[txnIn.ID] = txnIn
+	r.notifyLocked(from, txnOut)
+	r.notifyLocked(to, txnIn)
 	return txnOut, txnIn, nil
 }
 
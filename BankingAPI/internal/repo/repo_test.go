@@ -0,0 +1,40 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/storage"
+	"context"
+	"testing"
+)
+
+// newTestRepo returns a repo over an empty store with one tenant, and a
+// context scoped to it.
+func newTestRepo(t *testing.T) (*Repo, context.Context) {
+	t.Helper()
+	r := NewRepo(storage.NewInMemoryStore())
+	r.PutTenant(&model.Tenant{ID: "t", Name: "Test", IBANCountry: "DE", BankCode: "10010010"})
+	return r, WithTenant(context.Background(), "t")
+}
+
+func newTestUser(t *testing.T, r *Repo, ctx context.Context, email string) *model.User {
+	t.Helper()
+	u, err := r.CreateUser(ctx, &model.User{Email: email, Name: email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func newTestAccount(t *testing.T, r *Repo, ctx context.Context, userID string, balance int64) *model.Account {
+	t.Helper()
+	a, err := r.CreateAccount(ctx, &model.Account{UserID: userID, Name: "main", Currency: "EUR", Kind: model.AccountPersonal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance > 0 {
+		if _, err := r.Deposit(ctx, a.ID, balance, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a
+}
@@ -0,0 +1,131 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	ErrNotDeleted     = errors.New("account is not deleted")
+	ErrRestoreExpired = errors.New("the restore window for this account has passed")
+)
+
+// DeletedAccount is a soft-deleted account and the last moment it can be
+// restored.
+type DeletedAccount struct {
+	*model.Account
+	RestorableUntil time.Time `json:"restorable_until"`
+}
+
+// ListDeletedAccounts returns the soft-deleted accounts userID manages,
+// most recently deleted first.
+func (r *Repo) ListDeletedAccounts(ctx context.Context, userID string, retention time.Duration) ([]DeletedAccount, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	out := []DeletedAccount{}
+	tenantID := TenantFrom(ctx)
+	for id, a := range r.store.Accounts {
+		if a.TenantID != tenantID || a.DeletedAt == nil || allows(r.store.Members[id][userID], PermManage, 0) != nil {
+			continue
+		}
+		out = append(out, DeletedAccount{Account: a, RestorableUntil: a.DeletedAt.Add(retention)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].DeletedAt.After(*out[j].DeletedAt) })
+	return out, nil
+}
+
+// RestoreAccount undoes DeleteAccount within the retention period.
+func (r *Repo) RestoreAccount(ctx context.Context, id, actorID string, retention time.Duration) (*model.Account, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	a, ok := r.accountLocked(ctx, id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if a.DeletedAt == nil {
+		return nil, ErrNotDeleted
+	}
+	if time.Now().After(a.DeletedAt.Add(retention)) {
+		return nil, ErrRestoreExpired
+	}
+	a.DeletedAt = nil
+	a.UpdatedAt = time.Now()
+	r.auditLocked(actorID, "account.restored", "account", a.ID, "", nil)
+	return a, nil
+}
+
+// ArchiveDeletedAccounts moves accounts deleted before cutoff, with their
+// transactions, out of the live store into the archive and returns the
+// IDs archived. Deleted accounts hold no money, so their transactions sum
+// to zero and archiving them leaves the ledger total unchanged; an account
+// whose history does not add up to its balance is left in place and
+// reported instead.
+func (r *Repo) ArchiveDeletedAccounts(ctx context.Context, cutoff time.Time) ([]string, []error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+
+	var archived []string
+	var errs []error
+	for id, a := range r.store.Accounts {
+		if a.DeletedAt == nil || !a.DeletedAt.Before(cutoff) {
+			continue
+		}
+		var txns []*model.Transaction
+		var sum int64
+		for _, t := range r.store.Transactions {
+			if t.AccountID == id {
+				txns = append(txns, t)
+				sum += BalanceDelta(t)
+			}
+		}
+		if sum != a.Balance || a.Balance != 0 || a.Reserved != 0 {
+			errs = append(errs, fmt.Errorf("account %s: ledger sum %d, balance %d, reserved %d", id, sum, a.Balance, a.Reserved))
+			continue
+		}
+		r.store.ArchivedAccounts[id] = &model.ArchivedAccount{Account: a, Transactions: txns, ArchivedAt: time.Now()}
+		for _, t := range txns {
+			delete(r.store.Transactions, t.ID)
+			delete(r.store.ReconciledTxns, t.ID)
+		}
+		r.dropAccountDataLocked(id)
+		// the number, IBAN and card numbers stay in their indexes so they
+		// are never handed to another customer
+		delete(r.store.Accounts, id)
+		r.auditLocked(SystemActor, "account.archived", "account", id, "retention period elapsed", map[string]interface{}{"transactions": len(txns)})
+		archived = append(archived, id)
+	}
+	return archived, errs
+}
+
+// dropAccountDataLocked removes the live records hanging off an account
+// that is being archived.
+func (r *Repo) dropAccountDataLocked(id string) {
+	delete(r.store.Members, id)
+	delete(r.store.BalanceSnapshots, id)
+	for pid, p := range r.store.Pots {
+		if p.AccountID == id {
+			delete(r.store.Pots, pid)
+		}
+	}
+	for cid, c := range r.store.Cards {
+		if c.AccountID == id {
+			delete(r.store.Cards, cid)
+		}
+	}
+	for aid, auth := range r.store.CardAuthorizations {
+		if auth.AccountID == id {
+			delete(r.store.CardAuthorizations, aid)
+		}
+	}
+	for rid, rec := range r.store.ExternalRecords {
+		if rec.AccountID == id {
+			delete(r.store.ExternalRecords, rid)
+		}
+	}
+}
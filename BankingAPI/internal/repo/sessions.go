@@ -0,0 +1,194 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; the session has been revoked")
+	ErrSessionRevoked      = errors.New("session revoked")
+)
+
+// NewSession starts a login session for userID and returns it with its
+// first refresh token.
+func (r *Repo) NewSession(ctx context.Context, userID string, ttl time.Duration) (*model.Session, string, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	u, ok := r.userLocked(ctx, userID)
+	if !ok {
+		return nil, "", ErrNotFound
+	}
+	now := time.Now()
+	s := &model.Session{
+		ID:           uuid.NewString(),
+		TenantID:     u.TenantID,
+		UserID:       u.ID,
+		LastUsedAt:   now,
+		CreatedAt:    now,
+		AccessTokens: map[string]time.Time{},
+	}
+	r.store.Sessions[s.ID] = s
+	return s, r.issueRefreshTokenLocked(s, now, ttl), nil
+}
+
+func (r *Repo) issueRefreshTokenLocked(s *model.Session, now time.Time, ttl time.Duration) string {
+	token, hash := newOpaqueToken()
+	r.store.RefreshTokens[hash] = &model.RefreshToken{
+		TokenHash: hash,
+		SessionID: s.ID,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	return token
+}
+
+// RotateRefreshToken spends a refresh token and returns its session with
+// the refresh token that replaces it. A token that was already spent has
+// leaked, so the whole session is revoked instead. clientID is the OAuth
+// client presenting the token, or empty for the user's own sessions; a
+// token is only accepted from the client it was issued to.
+func (r *Repo) RotateRefreshToken(ctx context.Context, token, clientID string, ttl time.Duration) (*model.Session, string, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	rt, ok := r.store.RefreshTokens[HashToken(token)]
+	if !ok {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	s, ok := r.store.Sessions[rt.SessionID]
+	if !ok || s.TenantID != TenantFrom(ctx) || s.RevokedAt != nil || s.ClientID != clientID {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	now := time.Now()
+	if rt.UsedAt != nil {
+		r.revokeSessionLocked(s, now)
+		r.auditLocked(SystemActor, "session.refresh_reused", "user", s.UserID, "refresh token presented twice", map[string]interface{}{"session_id": s.ID})
+		return nil, "", ErrRefreshTokenReused
+	}
+	if now.After(rt.ExpiresAt) {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	if u, ok := r.store.Users[s.UserID]; !ok || !u.IsActive {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	rt.UsedAt = &now
+	s.LastUsedAt = now
+	return s, r.issueRefreshTokenLocked(s, now, ttl), nil
+}
+
+// TrackAccessToken records an access token issued in a session so that
+// revoking the session revokes it too. If the session was revoked in the
+// meantime the token is revoked straight away.
+func (r *Repo) TrackAccessToken(ctx context.Context, sessionID, jti string, expires time.Time) error {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	s, ok := r.store.Sessions[sessionID]
+	if !ok || s.TenantID != TenantFrom(ctx) || s.RevokedAt != nil {
+		r.store.RevokedTokens[jti] = expires
+		return ErrSessionRevoked
+	}
+	now := time.Now()
+	for id, exp := range s.AccessTokens {
+		if now.After(exp) {
+			delete(s.AccessTokens, id)
+		}
+	}
+	s.AccessTokens[jti] = expires
+	return nil
+}
+
+// RevokeSession ends a session, revoking its refresh token and every
+// access token issued in it.
+func (r *Repo) RevokeSession(ctx context.Context, sessionID string) error {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	s, ok := r.store.Sessions[sessionID]
+	if !ok || s.TenantID != TenantFrom(ctx) {
+		return ErrNotFound
+	}
+	r.revokeSessionLocked(s, time.Now())
+	return nil
+}
+
+// TokenRevoked reports whether the access token jti has been revoked.
+func (r *Repo) TokenRevoked(jti string) bool {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	_, revoked := r.store.RevokedTokens[jti]
+	return revoked
+}
+
+func (r *Repo) revokeSessionLocked(s *model.Session, now time.Time) {
+	if s.RevokedAt == nil {
+		s.RevokedAt = &now
+	}
+	for jti, exp := range s.AccessTokens {
+		r.store.RevokedTokens[jti] = exp
+	}
+	s.AccessTokens = map[string]time.Time{}
+}
+
+// revokeUserSessionsLocked signs a user out everywhere.
+func (r *Repo) revokeUserSessionsLocked(userID string, now time.Time) {
+	for _, s := range r.store.Sessions {
+		if s.UserID == userID {
+			r.revokeSessionLocked(s, now)
+		}
+	}
+}
+
+// ChangePassword replaces a user's password hash and revokes all of their
+// sessions and API keys, so tokens issued under the old password stop
+// working.
+func (r *Repo) ChangePassword(ctx context.Context, userID, passwordHash string) error {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	u, ok := r.userLocked(ctx, userID)
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now()
+	u.PasswordHash = passwordHash
+	u.UpdatedAt = now
+	r.revokeUserSessionsLocked(userID, now)
+	r.revokeUserAPIKeysLocked(userID, now)
+	r.auditLocked(userID, "user.password_changed", "user", userID, "", nil)
+	return nil
+}
+
+// PruneTokens forgets revoked access tokens and refresh tokens that have
+// expired, and sessions left without a refresh token. Access tokens never
+// outlive the refresh token issued with them. It returns the number of
+// entries removed.
+func (r *Repo) PruneTokens(now time.Time) int {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	n := 0
+	for jti, exp := range r.store.RevokedTokens {
+		if now.After(exp) {
+			delete(r.store.RevokedTokens, jti)
+			n++
+		}
+	}
+	live := map[string]bool{}
+	for hash, rt := range r.store.RefreshTokens {
+		if now.After(rt.ExpiresAt) {
+			delete(r.store.RefreshTokens, hash)
+			n++
+			continue
+		}
+		live[rt.SessionID] = true
+	}
+	for id := range r.store.Sessions {
+		if !live[id] {
+			delete(r.store.Sessions, id)
+			n++
+		}
+	}
+	return n
+}
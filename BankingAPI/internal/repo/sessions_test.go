@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		ttl      time.Duration
+		tenantID string
+		clientID string
+		token    string // presented instead of the issued token if set
+		wantErr  error
+	}{
+		{"valid", time.Hour, "t", "", "", nil},
+		{"unknown token", time.Hour, "t", "", "nope", ErrInvalidRefreshToken},
+		{"expired", -time.Second, "t", "", "", ErrInvalidRefreshToken},
+		{"other tenant", time.Hour, "other", "", "", ErrInvalidRefreshToken},
+		{"other client", time.Hour, "t", "app", "", ErrInvalidRefreshToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ctx := newTestRepo(t)
+			u := newTestUser(t, r, ctx, "a@example.com")
+			_, token, err := r.NewSession(ctx, u.ID, tt.ttl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.token != "" {
+				token = tt.token
+			}
+			ctx = WithTenant(context.Background(), tt.tenantID)
+			_, next, err := r.RotateRefreshToken(ctx, token, tt.clientID, time.Hour)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RotateRefreshToken = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (next == "" || next == token) {
+				t.Errorf("RotateRefreshToken returned %q, want a new token", next)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	r, ctx := newTestRepo(t)
+	u := newTestUser(t, r, ctx, "a@example.com")
+	s, first, err := r.NewSession(ctx, u.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.TrackAccessToken(ctx, s.ID, "jti-1", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	_, second, err := r.RotateRefreshToken(ctx, first, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run in order: replaying the spent token revokes the whole session
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"spent token", first, ErrRefreshTokenReused},
+		{"spent token again", first, ErrInvalidRefreshToken},
+		{"its replacement", second, ErrInvalidRefreshToken},
+	}
+	for _, tt := range tests {
+		if _, _, err := r.RotateRefreshToken(ctx, tt.token, "", time.Hour); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: RotateRefreshToken = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+	if s.RevokedAt == nil {
+		t.Error("session not revoked")
+	}
+	if !r.TokenRevoked("jti-1") {
+		t.Error("access token of the session not revoked")
+	}
+	if err := r.TrackAccessToken(ctx, s.ID, "jti-2", time.Now().Add(time.Hour)); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("TrackAccessToken = %v, want %v", err, ErrSessionRevoked)
+	}
+}
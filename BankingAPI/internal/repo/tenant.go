@@ -0,0 +1,107 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrCurrencyNotAllowed = errors.New("currency not offered by this bank")
+
+// WithTenant scopes ctx to a tenant. Every repo lookup made with the
+// returned context only sees that tenant's users, accounts and transactions.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, "tenant_id", tenantID)
+}
+
+// TenantFrom returns the tenant ctx is scoped to, or "" if none.
+func TenantFrom(ctx context.Context) string {
+	id, _ := ctx.Value("tenant_id").(string)
+	return id
+}
+
+// PutTenant registers or replaces a tenant.
+func (r *Repo) PutTenant(t *model.Tenant) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	r.store.Tenants[t.ID] = t
+}
+
+func (r *Repo) GetTenant(ctx context.Context, id string) (*model.Tenant, error) {
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	t, ok := r.store.Tenants[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return t, nil
+}
+
+// TenantByHost returns the tenant serving host (port ignored).
+func (r *Repo) TenantByHost(host string) (*model.Tenant, bool) {
+	if i := strings.LastIndex(host, ":"); i >= 0 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	r.store.Mu.RLock()
+	defer r.store.Mu.RUnlock()
+	for _, t := range r.store.Tenants {
+		for _, h := range t.Hosts {
+			if strings.EqualFold(h, host) {
+				return t, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// accountLocked returns account id if it belongs to the tenant of ctx.
+func (r *Repo) accountLocked(ctx context.Context, id string) (*model.Account, bool) {
+	a, ok := r.store.Accounts[id]
+	if !ok || a.TenantID != TenantFrom(ctx) {
+		return nil, false
+	}
+	return a, true
+}
+
+// userLocked returns user id if it belongs to the tenant of ctx.
+func (r *Repo) userLocked(ctx context.Context, id string) (*model.User, bool) {
+	u, ok := r.store.Users[id]
+	if !ok || u.TenantID != TenantFrom(ctx) {
+		return nil, false
+	}
+	return u, true
+}
+
+// currencyAllowedLocked reports whether the tenant offers currency.
+func (r *Repo) currencyAllowedLocked(tenantID, currency string) bool {
+	t, ok := r.store.Tenants[tenantID]
+	if !ok {
+		return false
+	}
+	if len(t.Currencies) == 0 {
+		return true
+	}
+	for _, c := range t.Currencies {
+		if c == currency {
+			return true
+		}
+	}
+	return false
+}
+
+// newTransaction builds a ledger entry for a, tagged with its tenant.
+func newTransaction(a *model.Account, typ model.TransactionType, amount int64, meta map[string]interface{}) *model.Transaction {
+	return &model.Transaction{
+		ID:        uuid.NewString(),
+		TenantID:  a.TenantID,
+		AccountID: a.ID,
+		Type:      typ,
+		Amount:    amount,
+		Meta:      meta,
+		CreatedAt: time.Now(),
+	}
+}
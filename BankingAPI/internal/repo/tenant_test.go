@@ -0,0 +1,81 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	r, ctx := newTestRepo(t)
+	r.PutTenant(&model.Tenant{ID: "other", Name: "Other", IBANCountry: "DE", BankCode: "20020020"})
+	other := WithTenant(ctx, "other")
+
+	// the same address may register at both tenants
+	victim := newTestUser(t, r, ctx, "a@example.com")
+	victimAcc := newTestAccount(t, r, ctx, victim.ID, 10000)
+	victimAcc.Kind = model.AccountBusiness
+	payee := newTestAccount(t, r, ctx, newTestUser(t, r, ctx, "b@example.com").ID, 0)
+	tr, err := r.CreateTransferRequest(ctx, victim.ID, victimAcc.ID, payee.ID, 2500, nil, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attacker := newTestUser(t, r, other, "a@example.com")
+	attackerAcc := newTestAccount(t, r, other, attacker.ID, 10000)
+
+	// every call is made in the other tenant's context
+	tests := []struct {
+		name    string
+		call    func() error
+		wantErr error
+	}{
+		{"get user", func() error { _, err := r.GetUserByID(other, victim.ID); return err }, ErrNotFound},
+		{"get account", func() error { _, err := r.GetAccount(other, victimAcc.ID); return err }, ErrNotFound},
+		{"find account by IBAN", func() error { _, err := r.FindAccountByIBAN(other, victimAcc.IBAN); return err }, ErrNotFound},
+		{"find account by number", func() error { _, err := r.FindAccountByNumber(other, victimAcc.Number); return err }, ErrNotFound},
+		{"authorize on account", func() error { _, err := r.Authorize(other, victimAcc.ID, victim.ID, PermView, 0); return err }, ErrNotFound},
+		{"rename account", func() error { name := "x"; _, err := r.UpdateAccount(other, victimAcc.ID, &name); return err }, ErrNotFound},
+		{"delete account", func() error { return r.DeleteAccount(other, victimAcc.ID, attacker.ID) }, ErrNotFound},
+		{"deposit", func() error { _, err := r.Deposit(other, victimAcc.ID, 100, nil); return err }, ErrNotFound},
+		{"withdraw", func() error { _, err := r.Withdraw(other, victimAcc.ID, 100, 0, nil); return err }, ErrNotFound},
+		{"transfer out of the account", func() error {
+			_, _, err := r.Transfer(other, victim.ID, victimAcc.ID, attackerAcc.ID, 100, nil)
+			return err
+		}, ErrNotFound},
+		{"transfer into the account", func() error {
+			_, _, err := r.Transfer(other, attacker.ID, attackerAcc.ID, victimAcc.ID, 100, nil)
+			return err
+		}, ErrNotFound},
+		{"request a transfer", func() error {
+			_, err := r.CreateTransferRequest(other, victim.ID, victimAcc.ID, attackerAcc.ID, 100, nil, time.Hour)
+			return err
+		}, ErrNotFound},
+		{"get transfer request", func() error { _, err := r.GetTransferRequest(other, tr.ID, victim.ID); return err }, ErrRequestNotFound},
+		{"approve transfer request", func() error { _, err := r.ApproveTransferRequest(other, tr.ID, attacker.ID, ""); return err }, ErrRequestNotFound},
+		{"reject transfer request", func() error { _, err := r.RejectTransferRequest(other, tr.ID, attacker.ID, ""); return err }, ErrRequestNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if u, err := r.GetUserByEmail(other, "a@example.com"); err != nil || u.ID != attacker.ID {
+		t.Errorf("GetUserByEmail = %v, %v; want the other tenant's own user", u, err)
+	}
+	if list, _ := r.ListAccountsByUser(other, victim.ID, "", nil); len(list) != 0 {
+		t.Errorf("ListAccountsByUser listed %d of another tenant's accounts", len(list))
+	}
+	if list, _ := r.ListTransferRequests(other, victim.ID, ""); len(list) != 0 {
+		t.Errorf("ListTransferRequests listed %d of another tenant's requests", len(list))
+	}
+	if victimAcc.Balance != 10000 || attackerAcc.Balance != 10000 || victimAcc.Name != "main" || victimAcc.DeletedAt != nil {
+		t.Errorf("accounts changed: victim %+v, attacker %+v", victimAcc, attackerAcc)
+	}
+	if tr.Status != model.TransferPending {
+		t.Errorf("transfer request status = %s, want %s", tr.Status, model.TransferPending)
+	}
+}
@@ -0,0 +1,149 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"BankingAPI/internal/storage"
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidUserToken = errors.New("invalid or expired token")
+	ErrAlreadyVerified  = errors.New("email address is already verified")
+	ErrResetTooSoon     = errors.New("a reset email was sent recently")
+)
+
+// IssueUserToken creates a single-use token for userID that expires after
+// ttl. Earlier unused tokens for the same purpose stop working, so only
+// the most recent email is valid.
+func (r *Repo) IssueUserToken(ctx context.Context, userID string, purpose model.UserTokenPurpose, ttl time.Duration) (string, *model.User, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	return r.issueUserTokenLocked(ctx, userID, purpose, ttl)
+}
+
+func (r *Repo) issueUserTokenLocked(ctx context.Context, userID string, purpose model.UserTokenPurpose, ttl time.Duration) (string, *model.User, error) {
+	u, ok := r.userLocked(ctx, userID)
+	if !ok || !u.IsActive {
+		return "", nil, ErrNotFound
+	}
+	if purpose == model.TokenEmailVerification && u.EmailVerifiedAt != nil {
+		return "", nil, ErrAlreadyVerified
+	}
+	now := time.Now()
+	for hash, t := range r.store.UserTokens {
+		if t.UserID == userID && t.Purpose == purpose {
+			delete(r.store.UserTokens, hash)
+		}
+	}
+	token, hash := newOpaqueToken()
+	r.store.UserTokens[hash] = &model.UserToken{
+		TokenHash: hash,
+		TenantID:  u.TenantID,
+		UserID:    u.ID,
+		Purpose:   purpose,
+		Email:     u.Email,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	return token, u, nil
+}
+
+// PasswordResetToken issues a reset token for the user registered under
+// email, unless one was issued less than interval ago. It returns
+// ErrNotFound for unknown addresses and ErrResetTooSoon when the user must
+// wait; callers should not reveal either to the requester.
+func (r *Repo) PasswordResetToken(ctx context.Context, email string, ttl, interval time.Duration) (string, *model.User, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	userID, ok := r.store.EmailIndex[storage.EmailKey(TenantFrom(ctx), email)]
+	if !ok {
+		return "", nil, ErrNotFound
+	}
+	for _, t := range r.store.UserTokens {
+		if t.UserID == userID && t.Purpose == model.TokenPasswordReset && time.Since(t.CreatedAt) < interval {
+			return "", nil, ErrResetTooSoon
+		}
+	}
+	return r.issueUserTokenLocked(ctx, userID, model.TokenPasswordReset, ttl)
+}
+
+// spendUserTokenLocked marks a token used and returns its user. Tokens
+// sent to an address the user no longer has are refused.
+func (r *Repo) spendUserTokenLocked(ctx context.Context, token string, purpose model.UserTokenPurpose) (*model.User, error) {
+	hash := HashToken(token)
+	t, ok := r.store.UserTokens[hash]
+	if !ok || t.Purpose != purpose || t.TenantID != TenantFrom(ctx) || t.UsedAt != nil {
+		return nil, ErrInvalidUserToken
+	}
+	now := time.Now()
+	if now.After(t.ExpiresAt) {
+		delete(r.store.UserTokens, hash)
+		return nil, ErrInvalidUserToken
+	}
+	u, ok := r.userLocked(ctx, t.UserID)
+	if !ok || !u.IsActive || u.Email != t.Email {
+		return nil, ErrInvalidUserToken
+	}
+	t.UsedAt = &now
+	return u, nil
+}
+
+// VerifyEmail spends a verification token and marks the user's email
+// verified.
+func (r *Repo) VerifyEmail(ctx context.Context, token string) (*model.User, error) {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	u, err := r.spendUserTokenLocked(ctx, token, model.TokenEmailVerification)
+	if err != nil {
+		return nil, err
+	}
+	r.markVerifiedLocked(u)
+	return u, nil
+}
+
+// ResetPassword spends a reset token and sets a new password hash. All of
+// the user's sessions and API keys are revoked. Receiving the email also
+// proves the user controls the address, so it counts as verified.
+func (r *Repo) ResetPassword(ctx context.Context, token, passwordHash string) error {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	u, err := r.spendUserTokenLocked(ctx, token, model.TokenPasswordReset)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	u.PasswordHash = passwordHash
+	u.UpdatedAt = now
+	r.revokeUserSessionsLocked(u.ID, now)
+	r.revokeUserAPIKeysLocked(u.ID, now)
+	r.markVerifiedLocked(u)
+	r.auditLocked(u.ID, "user.password_reset", "user", u.ID, "", nil)
+	return nil
+}
+
+func (r *Repo) markVerifiedLocked(u *model.User) {
+	if u.EmailVerifiedAt != nil {
+		return
+	}
+	now := time.Now()
+	u.EmailVerifiedAt = &now
+	u.UpdatedAt = now
+	r.auditLocked(u.ID, "user.email_verified", "user", u.ID, "", nil)
+	r.grantBootstrapAdminLocked(u)
+}
+
+// PruneUserTokens drops used and expired email tokens.
+func (r *Repo) PruneUserTokens(now time.Time) int {
+	r.store.Mu.Lock()
+	defer r.store.Mu.Unlock()
+	n := 0
+	for hash, t := range r.store.UserTokens {
+		if t.UsedAt != nil || now.After(t.ExpiresAt) {
+			delete(r.store.UserTokens, hash)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,143 @@
+package repo
+
+import (
+	"BankingAPI/internal/model"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResetPasswordToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		ttl         time.Duration
+		purpose     model.UserTokenPurpose
+		reissue     bool   // a newer token is issued before the first is spent
+		newEmail    string // the user's address changes before the token is spent
+		tenantID    string
+		spendTwice  bool
+		wantErr     error
+		wantSession bool // the user's session survives
+	}{
+		{name: "valid", ttl: time.Hour, purpose: model.TokenPasswordReset, tenantID: "t"},
+		{name: "used twice", ttl: time.Hour, purpose: model.TokenPasswordReset, tenantID: "t", spendTwice: true, wantErr: ErrInvalidUserToken},
+		{name: "expired", ttl: -time.Second, purpose: model.TokenPasswordReset, tenantID: "t", wantErr: ErrInvalidUserToken, wantSession: true},
+		{name: "superseded by a newer token", ttl: time.Hour, purpose: model.TokenPasswordReset, tenantID: "t", reissue: true, wantErr: ErrInvalidUserToken, wantSession: true},
+		{name: "email changed", ttl: time.Hour, purpose: model.TokenPasswordReset, tenantID: "t", newEmail: "b@example.com", wantErr: ErrInvalidUserToken, wantSession: true},
+		{name: "verification token", ttl: time.Hour, purpose: model.TokenEmailVerification, tenantID: "t", wantErr: ErrInvalidUserToken, wantSession: true},
+		{name: "other tenant", ttl: time.Hour, purpose: model.TokenPasswordReset, tenantID: "other", wantErr: ErrInvalidUserToken, wantSession: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ctx := newTestRepo(t)
+			u := newTestUser(t, r, ctx, "a@example.com")
+			sess, _, err := r.NewSession(ctx, u.ID, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, _, err := r.IssueUserToken(ctx, u.ID, tt.purpose, tt.ttl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.reissue {
+				newer, _, err := r.IssueUserToken(ctx, u.ID, tt.purpose, tt.ttl)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer func() {
+					if err := r.ResetPassword(ctx, newer, "newer"); err != nil {
+						t.Errorf("newer token: ResetPassword = %v", err)
+					}
+				}()
+			}
+			if tt.newEmail != "" {
+				// there is no email change endpoint yet, so change the
+				// stored address directly
+				r.store.Mu.Lock()
+				u.Email = tt.newEmail
+				r.store.Mu.Unlock()
+			}
+			spendCtx := WithTenant(context.Background(), tt.tenantID)
+			err = r.ResetPassword(spendCtx, token, "new")
+			if tt.spendTwice {
+				if err != nil {
+					t.Fatalf("first ResetPassword = %v", err)
+				}
+				err = r.ResetPassword(spendCtx, token, "newer")
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ResetPassword = %v, want %v", err, tt.wantErr)
+			}
+			got, _ := r.GetUserByID(ctx, u.ID)
+			if tt.wantErr == nil && got.PasswordHash != "new" {
+				t.Errorf("PasswordHash = %q, want new", got.PasswordHash)
+			}
+			if tt.wantErr != nil && !tt.spendTwice && got.PasswordHash == "new" {
+				t.Error("password changed by a refused token")
+			}
+			r.store.Mu.RLock()
+			alive := sess.RevokedAt == nil
+			r.store.Mu.RUnlock()
+			if alive != tt.wantSession {
+				t.Errorf("session active = %v, want %v", alive, tt.wantSession)
+			}
+		})
+	}
+}
+
+func TestPasswordResetInterval(t *testing.T) {
+	r, ctx := newTestRepo(t)
+	newTestUser(t, r, ctx, "a@example.com")
+	steps := []struct {
+		name     string
+		email    string
+		interval time.Duration
+		wantErr  error
+	}{
+		{"first request", "a@example.com", 5 * time.Minute, nil},
+		{"again within the interval", "a@example.com", 5 * time.Minute, ErrResetTooSoon},
+		{"address in other case", "A@Example.com", 5 * time.Minute, ErrResetTooSoon},
+		{"interval elapsed", "a@example.com", 0, nil},
+		{"unknown address", "nobody@example.com", 5 * time.Minute, ErrNotFound},
+	}
+	var tokens []string
+	for _, s := range steps {
+		token, _, err := r.PasswordResetToken(ctx, s.email, time.Hour, s.interval)
+		if !errors.Is(err, s.wantErr) {
+			t.Fatalf("%s: PasswordResetToken = %v, want %v", s.name, err, s.wantErr)
+		}
+		if err == nil {
+			tokens = append(tokens, token)
+		}
+	}
+	// only the most recent email works
+	if err := r.ResetPassword(ctx, tokens[0], "x"); !errors.Is(err, ErrInvalidUserToken) {
+		t.Errorf("first token: ResetPassword = %v, want %v", err, ErrInvalidUserToken)
+	}
+	if err := r.ResetPassword(ctx, tokens[1], "x"); err != nil {
+		t.Errorf("latest token: ResetPassword = %v", err)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	r, ctx := newTestRepo(t)
+	u := newTestUser(t, r, ctx, "a@example.com")
+	token, _, err := r.IssueUserToken(ctx, u.ID, model.TokenEmailVerification, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.VerifyEmail(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.EmailVerifiedAt == nil {
+		t.Error("EmailVerifiedAt not set")
+	}
+	if _, err := r.VerifyEmail(ctx, token); !errors.Is(err, ErrInvalidUserToken) {
+		t.Errorf("second VerifyEmail = %v, want %v", err, ErrInvalidUserToken)
+	}
+	if _, _, err := r.IssueUserToken(ctx, u.ID, model.TokenEmailVerification, time.Hour); !errors.Is(err, ErrAlreadyVerified) {
+		t.Errorf("IssueUserToken after verifying = %v, want %v", err, ErrAlreadyVerified)
+	}
+}
@@ -2,23 +2,90 @@ package storage
 
 import (
 	"BankingAPI/internal/model"
+	"strings"
 	"sync"
+	"time"
 )
 
 // InMemoryStore is a thread-safe in-memory store implementation.
 type InMemoryStore struct {
-	Mu           sync.RWMutex
-	Users        map[string]*model.User
-	Accounts     map[string]*model.Account
-	Transactions map[string]*model.Transaction
-	EmailIndex   map[string]string // email -> userID
+	Mu               sync.RWMutex
+	Users            map[string]*model.User
+	Accounts         map[string]*model.Account
+	Transactions     map[string]*model.Transaction
+	Tenants          map[string]*model.Tenant
+	EmailIndex       map[string]string // EmailKey(tenantID, email) -> userID
+	NumberIndex      map[string]string // account number -> accountID
+	IBANIndex        map[string]string // IBAN -> accountID
+	AuditLog         []*model.AuditEvent
+	Members          map[string]map[string]*model.AccountMember // accountID -> userID -> membership
+	Beneficiaries    map[string]*model.Beneficiary
+	TransferRequests map[string]*model.TransferRequest
+	PaymentRequests  map[string]*model.PaymentRequest
+	PayLinkIndex     map[string]string // sha256(token) -> payment request ID
+	Pots             map[string]*model.Pot
+	// category overrides per user: transaction ID or merchant key -> category
+	TxnCategories      map[string]map[string]string
+	MerchantCategories map[string]map[string]string
+	BalanceSnapshots   map[string][]*model.BalanceSnapshot // accountID -> end-of-day snapshots, oldest first
+	Cards              map[string]*model.Card
+	PANIndex           map[string]string // sha256(PAN) -> card ID
+	CardAuthorizations map[string]*model.CardAuthorization
+	MessageIDs         map[string]time.Time // tenantID/message ID -> first seen, for duplicate detection
+	ExternalRecords    map[string]*model.ExternalRecord
+	ReconciledTxns     map[string]string // transaction ID -> external record ID
+	ArchivedAccounts   map[string]*model.ArchivedAccount
+	Sessions           map[string]*model.Session
+	RefreshTokens      map[string]*model.RefreshToken // sha256(token) -> refresh token
+	RevokedTokens      map[string]time.Time           // access token jti -> expiry
+	MFAChallenges      map[string]*model.MFAChallenge // sha256(token) -> challenge
+	UserTokens         map[string]*model.UserToken    // sha256(token) -> reset or verification token
+	LoginThrottles     map[string]*model.LoginThrottle
+	APIKeys            map[string]*model.APIKey // sha256(key) -> API key
+	OAuthClients       map[string]*model.OAuthClient
+	OAuthCodes         map[string]*model.OAuthCode // sha256(code) -> authorization code
 }
 
 func NewInMemoryStore() *InMemoryStore {
 	return &InMemoryStore{
-		Users:        make(map[string]*model.User),
-		Accounts:     make(map[string]*model.Account),
-		Transactions: make(map[string]*model.Transaction),
-		EmailIndex:   make(map[string]string),
+		Users:              make(map[string]*model.User),
+		Accounts:           make(map[string]*model.Account),
+		Transactions:       make(map[string]*model.Transaction),
+		Tenants:            make(map[string]*model.Tenant),
+		EmailIndex:         make(map[string]string),
+		NumberIndex:        make(map[string]string),
+		IBANIndex:          make(map[string]string),
+		Members:            make(map[string]map[string]*model.AccountMember),
+		Beneficiaries:      make(map[string]*model.Beneficiary),
+		TransferRequests:   make(map[string]*model.TransferRequest),
+		PaymentRequests:    make(map[string]*model.PaymentRequest),
+		PayLinkIndex:       make(map[string]string),
+		Pots:               make(map[string]*model.Pot),
+		TxnCategories:      make(map[string]map[string]string),
+		MerchantCategories: make(map[string]map[string]string),
+		BalanceSnapshots:   make(map[string][]*model.BalanceSnapshot),
+		Cards:              make(map[string]*model.Card),
+		PANIndex:           make(map[string]string),
+		CardAuthorizations: make(map[string]*model.CardAuthorization),
+		MessageIDs:         make(map[string]time.Time),
+		ExternalRecords:    make(map[string]*model.ExternalRecord),
+		ReconciledTxns:     make(map[string]string),
+		ArchivedAccounts:   make(map[string]*model.ArchivedAccount),
+		Sessions:           make(map[string]*model.Session),
+		RefreshTokens:      make(map[string]*model.RefreshToken),
+		RevokedTokens:      make(map[string]time.Time),
+		MFAChallenges:      make(map[string]*model.MFAChallenge),
+		UserTokens:         make(map[string]*model.UserToken),
+		LoginThrottles:     make(map[string]*model.LoginThrottle),
+		APIKeys:            make(map[string]*model.APIKey),
+		OAuthClients:       make(map[string]*model.OAuthClient),
+		OAuthCodes:         make(map[string]*model.OAuthCode),
 	}
 }
+
+// EmailKey is the EmailIndex key of an email within a tenant; the same
+// address may be registered once per tenant. Addresses are compared
+// without case and surrounding space.
+func EmailKey(tenantID, email string) string {
+	return tenantID + "/" + strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,128 @@
+package stream
+
+import (
+	"sync"
+	"time"
+)
+
+// Event is a single message pushed to stream subscribers.
+type Event struct {
+	ID        uint64      `json:"id"`
+	Type      string      `json:"type"`
+	Data      interface{} `json:"data"`
+	CreatedAt time.Time   `json:"created_at"`
+	userID    string
+}
+
+// EventReset tells a resuming client that events it missed are no longer
+// retained, so it must refetch its balances instead of relying on replay.
+const EventReset = "reset"
+
+// Subscription receives events for one user. C is closed when the
+// subscriber is removed, either by Unsubscribe or because it fell too far
+// behind (the client is expected to reconnect with its last event id).
+type Subscription struct {
+	C      <-chan Event
+	c      chan Event
+	userID string
+}
+
+// Hub fans out events to subscribers and keeps a bounded history so
+// clients can resume from a Last-Event-ID.
+type Hub struct {
+	mu      sync.Mutex
+	seq     uint64
+	history []Event // ring buffer, oldest first once full
+	next    int
+	full    bool
+	subs    map[*Subscription]struct{}
+	bufSize int
+}
+
+// NewHub creates a hub retaining historySize events and giving every
+// subscriber a buffer of bufSize events before it is dropped.
+func NewHub(historySize, bufSize int) *Hub {
+	return &Hub{
+		history: make([]Event, historySize),
+		subs:    make(map[*Subscription]struct{}),
+		bufSize: bufSize,
+	}
+}
+
+// Publish sends an event to every subscriber of userID. It never blocks:
+// subscribers whose buffer is full are disconnected.
+func (h *Hub) Publish(userID, eventType string, data interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	ev := Event{ID: h.seq, Type: eventType, Data: data, CreatedAt: time.Now(), userID: userID}
+	if len(h.history) > 0 {
+		h.history[h.next] = ev
+		h.next = (h.next + 1) % len(h.history)
+		if h.next == 0 {
+			h.full = true
+		}
+	}
+	for s := range h.subs {
+		if s.userID != userID {
+			continue
+		}
+		select {
+		case s.c <- ev:
+		default:
+			h.removeLocked(s)
+		}
+	}
+}
+
+// Subscribe registers a subscriber for userID and returns the retained
+// events newer than lastEventID that it missed. When some of them have
+// already left the history, or lastEventID is unknown, the replay is a
+// single EventReset instead. Replay and subscription happen under the
+// same lock so no event is lost or duplicated.
+func (h *Hub) Subscribe(userID string, lastEventID uint64) (*Subscription, []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var replay []Event
+	if lastEventID > 0 {
+		retained := h.retainedLocked()
+		oldest := h.seq - uint64(len(retained)) + 1
+		if lastEventID+1 < oldest || lastEventID > h.seq {
+			replay = []Event{{ID: h.seq, Type: EventReset, CreatedAt: time.Now()}}
+			retained = nil
+		}
+		for _, ev := range retained {
+			if ev.userID == userID && ev.ID > lastEventID {
+				replay = append(replay, ev)
+			}
+		}
+	}
+	c := make(chan Event, h.bufSize)
+	s := &Subscription{C: c, c: c, userID: userID}
+	h.subs[s] = struct{}{}
+	return s, replay
+}
+
+// Unsubscribe removes the subscriber. It is safe to call more than once.
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(s)
+}
+
+func (h *Hub) removeLocked(s *Subscription) {
+	if _, ok := h.subs[s]; !ok {
+		return
+	}
+	delete(h.subs, s)
+	close(s.c)
+}
+
+func (h *Hub) retainedLocked() []Event {
+	if !h.full {
+		return h.history[:h.next]
+	}
+	out := make([]Event, 0, len(h.history))
+	out = append(out, h.history[h.next:]...)
+	return append(out, h.history[:h.next]...)
+}
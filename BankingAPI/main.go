@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"BankingAPI/docs"
+	"BankingAPI/internal/config"
 	httpserver "BankingAPI/internal/httpserver"
 
 	httpSwagger "github.com/swaggo/http-swagger"
@@ -19,7 +20,14 @@ import (
 // @in header
 // @name Authorization
 func main() {
-	srv := httpserver.NewServer()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+	srv, err := httpserver.NewServer(cfg)
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
 	docs.SwaggerInfo.BasePath = "/"
 
 	// register swagger endpoint